@@ -0,0 +1,269 @@
+package kubemap
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	mappedResourcesBucket = []byte("mappedResources")
+	namespacesBucket      = []byte("namespaces")
+	//clusterScopedBucket indexes groups without namespace, like standalone nodes. Bucket names cannot be empty.
+	//Namespace names cannot contain '$', so it does not clash with a namespace.
+	clusterScopedBucket = []byte("$cluster")
+)
+
+//BoltStore is a cache.Store of mapped resources persisted in a bbolt database.
+//Mapped resources are read from disk on demand, so store does not need to fit in memory and survives restarts.
+//Use it with NewStoreMapper or NewStoreMapperWithOptions.
+//Store keys grow with members of groups, beyond what bbolt accepts as key. Entries are keyed by hash of store key,
+//and namespace index keeps store key itself.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+//namespaceKeyLister is implemented by stores which index keys by namespace.
+type namespaceKeyLister interface {
+	ListNamespaceKeys(namespace string) []string
+}
+
+//NewBoltStore opens bbolt database at path, creating it when it does not exist.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open bolt store %s - %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(mappedResourcesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(namespacesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Cannot initialize bolt store %s - %v", path, err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+//Close closes underlying database.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+//Add implements cache.Store
+func (s *BoltStore) Add(obj interface{}) error {
+	mappedResource, key, err := boltStoreItem(obj)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(mappedResource)
+	if err != nil {
+		return fmt.Errorf("Cannot encode mapped resource %s - %v", mappedResource.CommonLabel, err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putMappedResource(tx, key, mappedResource.Namespace, value)
+	})
+}
+
+//Update implements cache.Store
+func (s *BoltStore) Update(obj interface{}) error {
+	return s.Add(obj)
+}
+
+//Delete implements cache.Store
+func (s *BoltStore) Delete(obj interface{}) error {
+	mappedResource, key, err := boltStoreItem(obj)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(mappedResourcesBucket).Delete(boltKey(key)); err != nil {
+			return err
+		}
+		if namespaceBucket := tx.Bucket(namespacesBucket).Bucket(namespaceBucketName(mappedResource.Namespace)); namespaceBucket != nil {
+			return namespaceBucket.Delete(boltKey(key))
+		}
+		return nil
+	})
+}
+
+//List implements cache.Store
+func (s *BoltStore) List() []interface{} {
+	var items []interface{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(mappedResourcesBucket).ForEach(func(key, value []byte) error {
+			var mappedResource MappedResource
+			if err := json.Unmarshal(value, &mappedResource); err != nil {
+				return fmt.Errorf("Cannot decode mapped resource with key %s - %v", key, err)
+			}
+			items = append(items, mappedResource)
+			return nil
+		})
+	})
+	if err != nil {
+		utilruntime.HandleError(err)
+	}
+
+	return items
+}
+
+//ListKeys implements cache.Store
+func (s *BoltStore) ListKeys() []string {
+	var keys []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		namespaces := tx.Bucket(namespacesBucket)
+		return namespaces.ForEach(func(name, _ []byte) error {
+			return namespaces.Bucket(name).ForEach(func(_, key []byte) error {
+				keys = append(keys, string(key))
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		utilruntime.HandleError(err)
+	}
+
+	return keys
+}
+
+//ListNamespaceKeys returns keys of mapped resources in given namespace using namespace index.
+func (s *BoltStore) ListNamespaceKeys(namespace string) []string {
+	var keys []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		namespaceBucket := tx.Bucket(namespacesBucket).Bucket(namespaceBucketName(namespace))
+		if namespaceBucket == nil {
+			return nil
+		}
+		return namespaceBucket.ForEach(func(_, key []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+	})
+	if err != nil {
+		utilruntime.HandleError(err)
+	}
+
+	return keys
+}
+
+//Get implements cache.Store
+func (s *BoltStore) Get(obj interface{}) (item interface{}, exists bool, err error) {
+	_, key, err := boltStoreItem(obj)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return s.GetByKey(key)
+}
+
+//GetByKey implements cache.Store
+func (s *BoltStore) GetByKey(key string) (item interface{}, exists bool, err error) {
+	var value []byte
+	err = s.db.View(func(tx *bolt.Tx) error {
+		//Value is only valid during transaction. Copy it.
+		value = append(value, tx.Bucket(mappedResourcesBucket).Get(boltKey(key))...)
+		return nil
+	})
+	if err != nil || value == nil {
+		return nil, false, err
+	}
+
+	var mappedResource MappedResource
+	if err := json.Unmarshal(value, &mappedResource); err != nil {
+		return nil, false, fmt.Errorf("Cannot decode mapped resource with key %s - %v", key, err)
+	}
+
+	return mappedResource, true, nil
+}
+
+//Replace implements cache.Store
+func (s *BoltStore) Replace(items []interface{}, resourceVersion string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{mappedResourcesBucket, namespacesBucket} {
+			if err := tx.DeleteBucket(bucket); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(bucket); err != nil {
+				return err
+			}
+		}
+
+		for _, item := range items {
+			mappedResource, key, err := boltStoreItem(item)
+			if err != nil {
+				return err
+			}
+
+			value, err := json.Marshal(mappedResource)
+			if err != nil {
+				return fmt.Errorf("Cannot encode mapped resource %s - %v", mappedResource.CommonLabel, err)
+			}
+
+			if err := putMappedResource(tx, key, mappedResource.Namespace, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//Resync implements cache.Store. There is nothing to resync for a bolt store.
+func (s *BoltStore) Resync() error {
+	return nil
+}
+
+func putMappedResource(tx *bolt.Tx, key string, namespace string, value []byte) error {
+	if err := tx.Bucket(mappedResourcesBucket).Put(boltKey(key), value); err != nil {
+		return err
+	}
+
+	namespaceBucket, err := tx.Bucket(namespacesBucket).CreateBucketIfNotExists(namespaceBucketName(namespace))
+	if err != nil {
+		return err
+	}
+	return namespaceBucket.Put(boltKey(key), []byte(key))
+}
+
+//boltKey returns fixed size bbolt key of store key.
+func boltKey(key string) []byte {
+	hash := sha256.Sum256([]byte(key))
+	return hash[:]
+}
+
+//namespaceBucketName returns name of bucket indexing groups of namespace.
+func namespaceBucketName(namespace string) []byte {
+	if namespace == "" {
+		return clusterScopedBucket
+	}
+
+	return []byte(namespace)
+}
+
+func boltStoreItem(obj interface{}) (MappedResource, string, error) {
+	mappedResource, ok := obj.(MappedResource)
+	if !ok {
+		return MappedResource{}, "", fmt.Errorf("Bolt store accepts only MappedResource, got %T", obj)
+	}
+
+	key, err := metaResourceKeyFunc(mappedResource)
+	if err != nil {
+		return MappedResource{}, "", err
+	}
+
+	return mappedResource, key, nil
+}
+
+var _ cache.Store = &BoltStore{}
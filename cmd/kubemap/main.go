@@ -0,0 +1,219 @@
+//Command kubemap works with mapped resources written by kubemap library.
+//
+//Usage:
+//
+//	kubemap diff [-o text|json] [-match common-label|stable-id] [-ignore-namespace] OLD NEW
+//
+//OLD and NEW are JSON files holding MappedResources, or snapshots written by Mapper.Snapshot.
+//diff exits with 0 when there is no drift, 1 when there is drift and 2 on error.
+//
+//	kubemap lint [-o text|json] [-fail-on info|warning|error] [-hpa HPAS] FILE
+//
+//FILE holds MappedResources or a snapshot. HPAS is a JSON file holding a HorizontalPodAutoscalerList.
+//lint exits with 0 when there is no finding as severe as -fail-on, 1 when there is and 2 on error.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/apollocse/kubemap"
+	autoscaling_v1 "k8s.io/api/autoscaling/v1"
+)
+
+const (
+	exitOK       = 0
+	exitDrift    = 1
+	exitFindings = 1
+	exitError    = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitError
+	}
+
+	switch args[0] {
+	case "diff":
+		return runDiff(args[1:], stdout, stderr)
+	case "lint":
+		return runLint(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "Unknown command %s\n", args[0])
+		usage(stderr)
+		return exitError
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: kubemap <command> [flags]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  diff    Report drift between two sets of mapped resources")
+	fmt.Fprintln(w, "  lint    Report misconfigurations of mapped resources")
+}
+
+func runDiff(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "text", "Output format, 'text' or 'json'")
+	matchBy := flags.String("match", kubemap.DiffMatchCommonLabel, "Match groups by 'common-label' or 'stable-id'")
+	ignoreNamespace := flags.Bool("ignore-namespace", false, "Match groups of different namespaces")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: kubemap diff [flags] OLD NEW")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitError
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(stderr, "Invalid output format %s. Accepted values are 'text' & 'json'\n", *output)
+		return exitError
+	}
+
+	oldResources, err := readMappedResources(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	newResources, err := readMappedResources(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	report, err := kubemap.Diff(oldResources, newResources, kubemap.DiffOptions{
+		MatchBy:         *matchBy,
+		IgnoreNamespace: *ignoreNamespace,
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if *output == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if report.IsEmpty() {
+		return exitOK
+	}
+	return exitDrift
+}
+
+func runLint(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "text", "Output format, 'text' or 'json'")
+	failOn := flags.String("fail-on", kubemap.LintSeverityWarning, "Exit with 1 on findings as severe as 'info', 'warning' or 'error'")
+	autoscalersFile := flags.String("hpa", "", "JSON file holding a HorizontalPodAutoscalerList to check scale targets of")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: kubemap lint [flags] FILE")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitError
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(stderr, "Invalid output format %s. Accepted values are 'text' & 'json'\n", *output)
+		return exitError
+	}
+
+	if *failOn != kubemap.LintSeverityInfo && *failOn != kubemap.LintSeverityWarning && *failOn != kubemap.LintSeverityError {
+		fmt.Fprintf(stderr, "Invalid severity %s. Accepted values are 'info', 'warning' & 'error'\n", *failOn)
+		return exitError
+	}
+
+	mappedResources, err := readMappedResources(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	options := kubemap.LintOptions{}
+	if *autoscalersFile != "" {
+		content, err := os.ReadFile(*autoscalersFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "Cannot read %s - %v\n", *autoscalersFile, err)
+			return exitError
+		}
+		var autoscalers autoscaling_v1.HorizontalPodAutoscalerList
+		if err := json.Unmarshal(content, &autoscalers); err != nil {
+			fmt.Fprintf(stderr, "Cannot decode %s - %v\n", *autoscalersFile, err)
+			return exitError
+		}
+		options.HorizontalPodAutoscalers = autoscalers.Items
+	}
+
+	report, err := kubemap.Lint(mappedResources, options)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if *output == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if report.HasSeverity(*failOn) {
+		return exitFindings
+	}
+	return exitOK
+}
+
+//readMappedResources reads MappedResources or a snapshot from file.
+func readMappedResources(fileName string) (kubemap.MappedResources, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return kubemap.MappedResources{}, fmt.Errorf("Cannot read %s - %v", fileName, err)
+	}
+
+	var input struct {
+		MappedResource  []kubemap.MappedResource `json:"mappedResource"`
+		MappedResources []kubemap.MappedResource `json:"mappedResources"`
+	}
+	if err := json.Unmarshal(content, &input); err != nil {
+		return kubemap.MappedResources{}, fmt.Errorf("Cannot decode %s - %v", fileName, err)
+	}
+
+	return kubemap.MappedResources{
+		MappedResource: append(input.MappedResource, input.MappedResources...),
+	}, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, "old.json")
+	newFile := filepath.Join(dir, "new.json")
+	assert.Nil(t, os.WriteFile(oldFile, []byte(`{"mappedResource":[{"commonLabel":"app","namespace":"default"}]}`), 0600))
+	assert.Nil(t, os.WriteFile(newFile, []byte(`{"version":1,"mappedResources":[{"commonLabel":"app","namespace":"default"},{"commonLabel":"new","namespace":"default"}]}`), 0600))
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitOK, run([]string{"diff", oldFile, oldFile}, &stdout, &stderr))
+	assert.Equal(t, "No differences\n", stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, exitDrift, run([]string{"diff", "-o", "json", oldFile, newFile}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), `"default/new"`)
+
+	assert.Equal(t, exitError, run([]string{"diff", oldFile}, &stdout, &stderr))
+	assert.Equal(t, exitError, run([]string{"diff", oldFile, filepath.Join(dir, "missing.json")}, &stdout, &stderr))
+	assert.Equal(t, exitError, run([]string{"unknown"}, &stdout, &stderr))
+}
+
+func TestRunLint(t *testing.T) {
+	dir := t.TempDir()
+	cleanFile := filepath.Join(dir, "clean.json")
+	lintFile := filepath.Join(dir, "lint.json")
+	autoscalersFile := filepath.Join(dir, "hpa.json")
+	assert.Nil(t, os.WriteFile(cleanFile, []byte(`{"mappedResource":[{"commonLabel":"app","namespace":"default"}]}`), 0600))
+	assert.Nil(t, os.WriteFile(lintFile, []byte(`{"mappedResource":[{"commonLabel":"app","namespace":"default","kube":{"services":[{"metadata":{"name":"app","namespace":"default"},"spec":{"selector":{"app":"app"}}}]}}]}`), 0600))
+	assert.Nil(t, os.WriteFile(autoscalersFile, []byte(`{"items":[{"metadata":{"name":"app","namespace":"default"},"spec":{"scaleTargetRef":{"kind":"Deployment","name":"app"},"maxReplicas":2}}]}`), 0600))
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitOK, run([]string{"lint", cleanFile}, &stdout, &stderr))
+	assert.Equal(t, "No findings\n", stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, exitFindings, run([]string{"lint", lintFile}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "[warning] service-without-pods")
+
+	stdout.Reset()
+	assert.Equal(t, exitOK, run([]string{"lint", "-fail-on", "error", lintFile}, &stdout, &stderr))
+
+	stdout.Reset()
+	assert.Equal(t, exitFindings, run([]string{"lint", "-o", "json", "-fail-on", "error", "-hpa", autoscalersFile, cleanFile}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), `"missing-scale-target"`)
+
+	assert.Equal(t, exitError, run([]string{"lint", "-fail-on", "fatal", cleanFile}, &stdout, &stderr))
+	assert.Equal(t, exitError, run([]string{"lint", filepath.Join(dir, "missing.json")}, &stdout, &stderr))
+	assert.Equal(t, exitError, run([]string{"lint"}, &stdout, &stderr))
+}
@@ -0,0 +1,250 @@
+package kubemap
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+
+	core_v1 "k8s.io/api/core/v1"
+	ext_v1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/cache"
+)
+
+//Types of Diagnostic
+const (
+	//DiagnosticMissingService is an ingress backend whose service does not exist in namespace.
+	DiagnosticMissingService = "MissingService"
+	//DiagnosticUnknownServicePort is an ingress backend whose servicePort is not a port of service.
+	DiagnosticUnknownServicePort = "UnknownServicePort"
+	//DiagnosticUnknownTargetPort is a service port whose targetPort is not a container port of pods service selects.
+	DiagnosticUnknownTargetPort = "UnknownTargetPort"
+)
+
+//Diagnostic is a dangling reference between members of a group.
+//Diagnostics of a group are evaluated when group is written to store.
+type Diagnostic struct {
+	Type string `json:"type"`
+	//Kind and Name identify object holding the reference, like "ingress" and its name.
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+//diagnoseResults sets diagnostics of groups of results not yet written to store.
+func diagnoseResults(results []MapResult, store cache.Store) error {
+	namespaceServices, err := namespaceServiceNames(results, store)
+	if err != nil {
+		return err
+	}
+
+	for i, result := range results {
+		if !result.IsStoreUpdated && (result.Action == "Added" || result.Action == "Updated") {
+			results[i].MappedResource.Diagnostics = diagnose(result.MappedResource, namespaceServices[result.MappedResource.Namespace])
+		}
+	}
+
+	return nil
+}
+
+//namespaceServiceNames returns names of services in namespaces of results, once results are written to store.
+func namespaceServiceNames(results []MapResult, store cache.Store) (map[string]map[string]bool, error) {
+	replacedKeys := map[string]bool{}
+	namespaceServices := map[string]map[string]bool{}
+	for _, result := range results {
+		if result.IsStoreUpdated {
+			continue
+		}
+		if result.Key != "" {
+			replacedKeys[result.Key] = true
+		}
+		for _, key := range result.DeleteKeys {
+			replacedKeys[key] = true
+		}
+		namespaceServices[result.MappedResource.Namespace] = map[string]bool{}
+	}
+
+	for namespace, services := range namespaceServices {
+		namespaceKeys, err := getNamespaceKeys(namespace, store)
+		if err != nil {
+			return nil, err
+		}
+		for _, namespaceKey := range namespaceKeys {
+			if replacedKeys[namespaceKey] {
+				continue
+			}
+			mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+			if err != nil {
+				return nil, err
+			}
+			for _, service := range mappedResource.Kube.Services {
+				services[service.Name] = true
+			}
+		}
+	}
+
+	for _, result := range results {
+		if result.IsStoreUpdated || (result.Action != "Added" && result.Action != "Updated") {
+			continue
+		}
+		for _, service := range result.MappedResource.Kube.Services {
+			namespaceServices[result.MappedResource.Namespace][service.Name] = true
+		}
+	}
+
+	return namespaceServices, nil
+}
+
+//diagnose returns dangling references of ingress backends to services, and of service target ports to container ports.
+func diagnose(mappedResource MappedResource, namespaceServices map[string]bool) []Diagnostic {
+	var diagnostics []Diagnostic
+	seen := map[Diagnostic]bool{}
+	add := func(diagnostic Diagnostic) {
+		if !seen[diagnostic] {
+			seen[diagnostic] = true
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+
+	services := map[string]core_v1.Service{}
+	for _, service := range mappedResource.Kube.Services {
+		services[service.Name] = service
+	}
+
+	for _, ingress := range mappedResource.Kube.Ingresses {
+		for _, backend := range ingressBackends(ingress) {
+			service, ok := services[backend.ServiceName]
+			if !ok {
+				if !namespaceServices[backend.ServiceName] {
+					add(Diagnostic{
+						Type:    DiagnosticMissingService,
+						Kind:    "ingress",
+						Name:    ingress.Name,
+						Message: fmt.Sprintf("Service %s of ingress %s does not exist", backend.ServiceName, ingress.Name),
+					})
+				}
+				continue
+			}
+
+			if service.Spec.Type != core_v1.ServiceTypeExternalName && !hasServicePort(service, backend.ServicePort) {
+				add(Diagnostic{
+					Type:    DiagnosticUnknownServicePort,
+					Kind:    "ingress",
+					Name:    ingress.Name,
+					Message: fmt.Sprintf("Port %s of service %s used by ingress %s does not exist", backend.ServicePort.String(), service.Name, ingress.Name),
+				})
+			}
+		}
+	}
+
+	for _, service := range mappedResource.Kube.Services {
+		if isSelectorlessService(service) {
+			continue
+		}
+
+		var pods []core_v1.Pod
+		selector := labels.SelectorFromSet(service.Spec.Selector)
+		for _, pod := range mappedResource.Kube.Pods {
+			if selector.Matches(labels.Set(pod.Labels)) {
+				pods = append(pods, pod)
+			}
+		}
+		if len(pods) == 0 {
+			continue
+		}
+
+		for _, port := range service.Spec.Ports {
+			if target := targetPort(port); !hasTargetPort(pods, port) {
+				add(Diagnostic{
+					Type:    DiagnosticUnknownTargetPort,
+					Kind:    "service",
+					Name:    service.Name,
+					Message: fmt.Sprintf("Target port %s of service %s port %d is not a container port of its pods", target.String(), service.Name, port.Port),
+				})
+			}
+		}
+	}
+
+	sort.Slice(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Kind != diagnostics[j].Kind {
+			return diagnostics[i].Kind < diagnostics[j].Kind
+		}
+		if diagnostics[i].Name != diagnostics[j].Name {
+			return diagnostics[i].Name < diagnostics[j].Name
+		}
+		return diagnostics[i].Message < diagnostics[j].Message
+	})
+
+	return diagnostics
+}
+
+//ingressBackends returns default backend and backends of paths of ingress.
+func ingressBackends(ingress ext_v1beta1.Ingress) []ext_v1beta1.IngressBackend {
+	var backends []ext_v1beta1.IngressBackend
+	if ingress.Spec.Backend != nil && ingress.Spec.Backend.ServiceName != "" {
+		backends = append(backends, *ingress.Spec.Backend)
+	}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.ServiceName != "" {
+				backends = append(backends, path.Backend)
+			}
+		}
+	}
+
+	return backends
+}
+
+//hasServicePort returns true when servicePort is name or number of a port of service.
+func hasServicePort(service core_v1.Service, servicePort intstr.IntOrString) bool {
+	for _, port := range service.Spec.Ports {
+		if servicePort.Type == intstr.String && port.Name == servicePort.StrVal {
+			return true
+		}
+		if servicePort.Type == intstr.Int && port.Port == servicePort.IntVal {
+			return true
+		}
+	}
+
+	return false
+}
+
+//targetPort returns target port of service port. It defaults to port itself.
+func targetPort(port core_v1.ServicePort) intstr.IntOrString {
+	if port.TargetPort.Type == intstr.Int && port.TargetPort.IntVal == 0 {
+		return intstr.FromInt(int(port.Port))
+	}
+
+	return port.TargetPort
+}
+
+//hasTargetPort returns true when target port of service port resolves in given pods. Named target port must be
+//declared by every pod. Numbered target port is accepted when pods do not declare container ports, as they are optional.
+func hasTargetPort(pods []core_v1.Pod, port core_v1.ServicePort) bool {
+	target := targetPort(port)
+	isDeclared := false
+
+	for _, pod := range pods {
+		isNamed := false
+		for _, container := range pod.Spec.Containers {
+			for _, containerPort := range container.Ports {
+				isDeclared = true
+				if target.Type == intstr.String && containerPort.Name == target.StrVal {
+					isNamed = true
+				}
+				if target.Type == intstr.Int && containerPort.ContainerPort == target.IntVal {
+					return true
+				}
+			}
+		}
+		if target.Type == intstr.String && !isNamed {
+			return false
+		}
+	}
+
+	return target.Type == intstr.String || !isDeclared
+}
@@ -0,0 +1,366 @@
+package kubemap
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	core_v1 "k8s.io/api/core/v1"
+)
+
+//Group matching modes of DiffOptions.MatchBy
+const (
+	DiffMatchCommonLabel = "common-label"
+	DiffMatchStableID    = "stable-id"
+)
+
+//DiffOptions controls how groups of two MappedResources sets are matched.
+type DiffOptions struct {
+	//MatchBy is DiffMatchCommonLabel (default) or DiffMatchStableID.
+	MatchBy string
+	//IgnoreNamespace matches groups of different namespaces, like when comparing staging and prod namespaces.
+	IgnoreNamespace bool
+}
+
+//DiffReport is drift between two MappedResources sets.
+type DiffReport struct {
+	AddedGroups   []string    `json:"addedGroups,omitempty"`
+	RemovedGroups []string    `json:"removedGroups,omitempty"`
+	ChangedGroups []GroupDiff `json:"changedGroups,omitempty"`
+}
+
+//GroupDiff is drift of a group present in both sets.
+type GroupDiff struct {
+	Group          string        `json:"group"`
+	AddedMembers   []DiffMember  `json:"addedMembers,omitempty"`
+	RemovedMembers []DiffMember  `json:"removedMembers,omitempty"`
+	Changes        []FieldChange `json:"changes,omitempty"`
+}
+
+//DiffMember is a k8s object of a group.
+type DiffMember struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+//FieldChange is a changed image, replica count, port or selector of a member present in both groups.
+type FieldChange struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+//IsEmpty returns true when there is no drift.
+func (r DiffReport) IsEmpty() bool {
+	return len(r.AddedGroups) == 0 && len(r.RemovedGroups) == 0 && len(r.ChangedGroups) == 0
+}
+
+//WriteText writes human-readable form of report to w.
+func (r DiffReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	if r.IsEmpty() {
+		b.WriteString("No differences\n")
+	}
+	for _, group := range r.AddedGroups {
+		fmt.Fprintf(&b, "+ group %s\n", group)
+	}
+	for _, group := range r.RemovedGroups {
+		fmt.Fprintf(&b, "- group %s\n", group)
+	}
+	for _, groupDiff := range r.ChangedGroups {
+		fmt.Fprintf(&b, "~ group %s\n", groupDiff.Group)
+		for _, member := range groupDiff.AddedMembers {
+			fmt.Fprintf(&b, "    + %s %s\n", member.Kind, member.Name)
+		}
+		for _, member := range groupDiff.RemovedMembers {
+			fmt.Fprintf(&b, "    - %s %s\n", member.Kind, member.Name)
+		}
+		for _, change := range groupDiff.Changes {
+			fmt.Fprintf(&b, "    ~ %s %s %s: %s -> %s\n", change.Kind, change.Name, change.Field, change.Old, change.New)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+//Diff compares old and new sets of mapped resources.
+func Diff(oldResources MappedResources, newResources MappedResources, options DiffOptions) (DiffReport, error) {
+	if options.MatchBy == "" {
+		options.MatchBy = DiffMatchCommonLabel
+	}
+	if options.MatchBy != DiffMatchCommonLabel && options.MatchBy != DiffMatchStableID {
+		return DiffReport{}, fmt.Errorf("Cannot diff. Invalid match mode %s provided. Accepted values are '%s' & '%s'", options.MatchBy, DiffMatchCommonLabel, DiffMatchStableID)
+	}
+
+	oldGroups, err := diffGroups(oldResources, options)
+	if err != nil {
+		return DiffReport{}, fmt.Errorf("Cannot diff. Old %v", err)
+	}
+	newGroups, err := diffGroups(newResources, options)
+	if err != nil {
+		return DiffReport{}, fmt.Errorf("Cannot diff. New %v", err)
+	}
+
+	var report DiffReport
+	for _, group := range sortedGroupIDs(newGroups) {
+		if _, ok := oldGroups[group]; !ok {
+			report.AddedGroups = append(report.AddedGroups, group)
+		}
+	}
+
+	for _, group := range sortedGroupIDs(oldGroups) {
+		newGroup, ok := newGroups[group]
+		if !ok {
+			report.RemovedGroups = append(report.RemovedGroups, group)
+			continue
+		}
+
+		groupDiff := diffGroup(group, oldGroups[group], newGroup)
+		if len(groupDiff.AddedMembers) > 0 || len(groupDiff.RemovedMembers) > 0 || len(groupDiff.Changes) > 0 {
+			report.ChangedGroups = append(report.ChangedGroups, groupDiff)
+		}
+	}
+
+	return report, nil
+}
+
+//StableID identifies a group by names of its ingresses, services and deployments.
+//Unlike store key, it does not change when pods or replicasets of group are replaced.
+//Groups with none of them are identified by their replicasets, then pods and then satellites like configmaps.
+func StableID(mappedResource MappedResource) string {
+	var members []string
+	for _, ingress := range mappedResource.Kube.Ingresses {
+		members = append(members, "ingress:"+ingress.Name)
+	}
+	for _, service := range mappedResource.Kube.Services {
+		members = append(members, "service:"+service.Name)
+	}
+	for _, deployment := range mappedResource.Kube.Deployments {
+		members = append(members, "deployment:"+deployment.Name)
+	}
+	if len(members) == 0 {
+		for _, replicaSet := range mappedResource.Kube.ReplicaSets {
+			members = append(members, "replicaset:"+replicaSet.Name)
+		}
+	}
+	if len(members) == 0 {
+		for _, pod := range mappedResource.Kube.Pods {
+			members = append(members, "pod:"+pod.Name)
+		}
+	}
+	if len(members) == 0 {
+		for _, configMap := range mappedResource.Kube.ConfigMaps {
+			members = append(members, "configmap:"+configMap.Name)
+		}
+		for _, secret := range mappedResource.Kube.Secrets {
+			members = append(members, "secret:"+secret.Name)
+		}
+		for _, claim := range mappedResource.Kube.PersistentVolumeClaims {
+			members = append(members, "persistentvolumeclaim:"+claim.Name)
+		}
+		for _, volume := range mappedResource.Kube.PersistentVolumes {
+			members = append(members, "persistentvolume:"+volume.Name)
+		}
+		for _, endpoints := range mappedResource.Kube.Endpoints {
+			members = append(members, "endpoints:"+endpoints.Name)
+		}
+		for _, endpointSlice := range mappedResource.Kube.EndpointSlices {
+			members = append(members, "endpointslice:"+endpointSlice.Name)
+		}
+		for _, networkPolicy := range mappedResource.Kube.NetworkPolicies {
+			members = append(members, "networkpolicy:"+networkPolicy.Name)
+		}
+		for _, budget := range mappedResource.Kube.PodDisruptionBudgets {
+			members = append(members, "poddisruptionbudget:"+budget.Name)
+		}
+		for _, serviceAccount := range mappedResource.Kube.ServiceAccounts {
+			members = append(members, "serviceaccount:"+serviceAccount.Name)
+		}
+		for _, roleBinding := range mappedResource.Kube.RoleBindings {
+			members = append(members, "rolebinding:"+roleBinding.Name)
+		}
+		for _, clusterRoleBinding := range mappedResource.Kube.ClusterRoleBindings {
+			members = append(members, "clusterrolebinding:"+clusterRoleBinding.Name)
+		}
+		for _, role := range mappedResource.Kube.Roles {
+			members = append(members, "role:"+role.Name)
+		}
+		for _, clusterRole := range mappedResource.Kube.ClusterRoles {
+			members = append(members, "clusterrole:"+clusterRole.Name)
+		}
+		for _, node := range mappedResource.Kube.Nodes {
+			members = append(members, "node:"+node.Name)
+		}
+	}
+
+	members = removeDuplicateStrings(members)
+	sort.Strings(members)
+
+	return fmt.Sprintf("%s/%s", mappedResource.Namespace, strings.Join(members, ","))
+}
+
+//diffGroups returns groups keyed by identifier they are matched by. Groups sharing an identifier cannot be matched, so they are an error.
+func diffGroups(mappedResources MappedResources, options DiffOptions) (map[string]MappedResource, error) {
+	groups := map[string]MappedResource{}
+	for _, mappedResource := range mappedResources.MappedResource {
+		groupResource := mappedResource
+		if options.IgnoreNamespace {
+			groupResource.Namespace = ""
+		}
+
+		var group string
+		if options.MatchBy == DiffMatchStableID {
+			group = StableID(groupResource)
+		} else {
+			group = groupResource.CommonLabel
+			if groupResource.Namespace != "" {
+				group = groupResource.Namespace + "/" + group
+			}
+		}
+		if options.IgnoreNamespace {
+			group = strings.TrimPrefix(group, "/")
+		}
+
+		if existing, ok := groups[group]; ok {
+			return nil, fmt.Errorf("groups %s/%s and %s/%s are both identified as %s", existing.Namespace, existing.CommonLabel, mappedResource.Namespace, mappedResource.CommonLabel, group)
+		}
+		groups[group] = mappedResource
+	}
+
+	return groups, nil
+}
+
+func sortedGroupIDs(groups map[string]MappedResource) []string {
+	ids := make([]string, 0, len(groups))
+	for id := range groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func diffGroup(group string, oldResource MappedResource, newResource MappedResource) GroupDiff {
+	groupDiff := GroupDiff{Group: group}
+
+	oldMembers := groupMembers(oldResource)
+	newMembers := groupMembers(newResource)
+	for _, memberKey := range sortedMemberKeys(newMembers) {
+		if _, ok := oldMembers[memberKey]; !ok {
+			groupDiff.AddedMembers = append(groupDiff.AddedMembers, DiffMember{Kind: newMembers[memberKey].Kind, Name: newMembers[memberKey].Name})
+		}
+	}
+	for _, memberKey := range sortedMemberKeys(oldMembers) {
+		if _, ok := newMembers[memberKey]; !ok {
+			groupDiff.RemovedMembers = append(groupDiff.RemovedMembers, DiffMember{Kind: oldMembers[memberKey].Kind, Name: oldMembers[memberKey].Name})
+		}
+	}
+
+	oldFields := memberFields(oldResource)
+	newFields := memberFields(newResource)
+	for _, fieldKey := range sortedFieldKeys(newFields) {
+		oldField, ok := oldFields[fieldKey]
+		if ok && oldField.New != newFields[fieldKey].New {
+			change := newFields[fieldKey]
+			change.Old = oldField.New
+			groupDiff.Changes = append(groupDiff.Changes, change)
+		}
+	}
+
+	return groupDiff
+}
+
+//memberFields returns compared fields of group members keyed by kind/name/field. Value of field is set in New.
+func memberFields(mappedResource MappedResource) map[string]FieldChange {
+	fields := map[string]FieldChange{}
+	add := func(kind string, name string, field string, value string) {
+		fields[kind+"/"+name+"/"+field] = FieldChange{Kind: kind, Name: name, Field: field, New: value}
+	}
+
+	for _, service := range mappedResource.Kube.Services {
+		add("service", service.Name, "selector", formatLabels(service.Spec.Selector))
+		add("service", service.Name, "ports", formatServicePorts(service.Spec.Ports))
+	}
+	for _, deployment := range mappedResource.Kube.Deployments {
+		if deployment.Spec.Replicas != nil {
+			add("deployment", deployment.Name, "replicas", fmt.Sprintf("%d", *deployment.Spec.Replicas))
+		}
+		if deployment.Spec.Selector != nil {
+			add("deployment", deployment.Name, "selector", formatLabels(deployment.Spec.Selector.MatchLabels))
+		}
+		add("deployment", deployment.Name, "images", formatImages(deployment.Spec.Template.Spec))
+		add("deployment", deployment.Name, "ports", formatContainerPorts(deployment.Spec.Template.Spec))
+	}
+	for _, replicaSet := range mappedResource.Kube.ReplicaSets {
+		if replicaSet.Spec.Replicas != nil {
+			add("replicaset", replicaSet.Name, "replicas", fmt.Sprintf("%d", *replicaSet.Spec.Replicas))
+		}
+		if replicaSet.Spec.Selector != nil {
+			add("replicaset", replicaSet.Name, "selector", formatLabels(replicaSet.Spec.Selector.MatchLabels))
+		}
+		add("replicaset", replicaSet.Name, "images", formatImages(replicaSet.Spec.Template.Spec))
+	}
+	for _, pod := range mappedResource.Kube.Pods {
+		add("pod", pod.Name, "images", formatImages(pod.Spec))
+		add("pod", pod.Name, "ports", formatContainerPorts(pod.Spec))
+	}
+
+	return fields
+}
+
+func sortedFieldKeys(fields map[string]FieldChange) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func formatLabels(labels map[string]string) string {
+	var pairs []string
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func formatServicePorts(ports []core_v1.ServicePort) string {
+	var formatted []string
+	for _, port := range ports {
+		formatted = append(formatted, fmt.Sprintf("%s:%d/%s->%s", port.Name, port.Port, port.Protocol, port.TargetPort.String()))
+	}
+
+	return strings.Join(formatted, ",")
+}
+
+func formatImages(podSpec core_v1.PodSpec) string {
+	var images []string
+	for _, container := range podSpec.InitContainers {
+		images = append(images, container.Name+"="+container.Image)
+	}
+	for _, container := range podSpec.Containers {
+		images = append(images, container.Name+"="+container.Image)
+	}
+
+	return strings.Join(images, ",")
+}
+
+func formatContainerPorts(podSpec core_v1.PodSpec) string {
+	var ports []string
+	for _, container := range podSpec.Containers {
+		for _, port := range container.Ports {
+			ports = append(ports, fmt.Sprintf("%s:%s:%d/%s", container.Name, port.Name, port.ContainerPort, port.Protocol))
+		}
+	}
+
+	return strings.Join(ports, ",")
+}
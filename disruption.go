@@ -0,0 +1,248 @@
+package kubemap
+
+import (
+	"sort"
+
+	core_v1 "k8s.io/api/core/v1"
+	policy_v1beta1 "k8s.io/api/policy/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+//DisruptionBudget is state of a PodDisruptionBudget attached to a group.
+type DisruptionBudget struct {
+	Name               string `json:"name"`
+	AllowedDisruptions int32  `json:"allowedDisruptions"`
+	CurrentHealthy     int32  `json:"currentHealthy"`
+	DesiredHealthy     int32  `json:"desiredHealthy"`
+	ExpectedPods       int32  `json:"expectedPods"`
+	//IsComputed is true when status of budget was not observed by disruption controller yet,
+	//and values are computed from mapped pods budget selects.
+	IsComputed bool `json:"isComputed"`
+}
+
+//GroupDisruption is disruption budgets of pods of a group.
+type GroupDisruption struct {
+	Namespace   string             `json:"namespace"`
+	CommonLabel string             `json:"commonLabel"`
+	Budgets     []DisruptionBudget `json:"budgets"`
+}
+
+//DrainViolation is a disruption budget which evicting pods of a node would violate.
+type DrainViolation struct {
+	Namespace          string `json:"namespace"`
+	Budget             string `json:"budget"`
+	AllowedDisruptions int32  `json:"allowedDisruptions"`
+	//Pods are healthy pods of node budget selects. Evicting each of them consumes a disruption.
+	Pods []string `json:"pods"`
+}
+
+//DrainReport tells whether pods of a node can be evicted without violating disruption budgets.
+type DrainReport struct {
+	Node string `json:"node"`
+	//Pods are pods mapped to node, like "namespace/name".
+	Pods       []string         `json:"pods,omitempty"`
+	Violations []DrainViolation `json:"violations,omitempty"`
+}
+
+//IsSafe returns true when drain does not violate any disruption budget.
+func (r DrainReport) IsSafe() bool {
+	return len(r.Violations) == 0
+}
+
+//DisruptionBudgets returns disruption budgets of groups having one, ordered by namespace and common label.
+//Budgets select pods across groups of their namespace, so all groups of budget are evaluated together.
+func DisruptionBudgets(mappedResources MappedResources) []GroupDisruption {
+	pods := namespacePods(mappedResources)
+
+	var groups []GroupDisruption
+	for _, mappedResource := range mappedResources.MappedResource {
+		if len(mappedResource.Kube.PodDisruptionBudgets) == 0 || !hasWorkloads(mappedResource) {
+			continue
+		}
+
+		group := GroupDisruption{Namespace: mappedResource.Namespace, CommonLabel: mappedResource.CommonLabel}
+		for _, budget := range mappedResource.Kube.PodDisruptionBudgets {
+			group.Budgets = append(group.Budgets, evaluateBudget(budget, pods[budget.Namespace]))
+		}
+		groups = append(groups, group)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Namespace != groups[j].Namespace {
+			return groups[i].Namespace < groups[j].Namespace
+		}
+		return groups[i].CommonLabel < groups[j].CommonLabel
+	})
+
+	return groups
+}
+
+//DisruptionBudgets returns disruption budgets of groups in store.
+func (m *Mapper) DisruptionBudgets() []GroupDisruption {
+	return DisruptionBudgets(getAllMappedResources(m.store))
+}
+
+//CheckDrain evaluates eviction of mapped pods whose nodeName is node against disruption budgets selecting them.
+//Only healthy pods consume disruptions, so a budget is violated when more of its healthy pods run on node than it allows.
+func CheckDrain(mappedResources MappedResources, node string) DrainReport {
+	pods := namespacePods(mappedResources)
+	report := DrainReport{Node: node}
+
+	var namespaces []string
+	for namespace := range pods {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		for _, pod := range pods[namespace] {
+			if pod.Spec.NodeName == node {
+				report.Pods = append(report.Pods, namespace+"/"+pod.Name)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, mappedResource := range mappedResources.MappedResource {
+		for _, budget := range mappedResource.Kube.PodDisruptionBudgets {
+			key := budget.Namespace + "/" + budget.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			var drained []string
+			for _, pod := range selectedPods(budget, pods[budget.Namespace]) {
+				if pod.Spec.NodeName == node && isPodReady(pod) {
+					drained = append(drained, pod.Name)
+				}
+			}
+
+			evaluated := evaluateBudget(budget, pods[budget.Namespace])
+			if int32(len(drained)) > evaluated.AllowedDisruptions {
+				report.Violations = append(report.Violations, DrainViolation{
+					Namespace:          budget.Namespace,
+					Budget:             budget.Name,
+					AllowedDisruptions: evaluated.AllowedDisruptions,
+					Pods:               drained,
+				})
+			}
+		}
+	}
+
+	sort.Slice(report.Violations, func(i, j int) bool {
+		if report.Violations[i].Namespace != report.Violations[j].Namespace {
+			return report.Violations[i].Namespace < report.Violations[j].Namespace
+		}
+		return report.Violations[i].Budget < report.Violations[j].Budget
+	})
+
+	return report
+}
+
+//CheckDrain evaluates eviction of pods of node against disruption budgets of groups in store.
+func (m *Mapper) CheckDrain(node string) DrainReport {
+	return CheckDrain(getAllMappedResources(m.store), node)
+}
+
+//evaluateBudget returns status of budget, or computes it from given pods of its namespace when status was not observed.
+func evaluateBudget(budget policy_v1beta1.PodDisruptionBudget, pods []core_v1.Pod) DisruptionBudget {
+	if budget.Status.ObservedGeneration > 0 && budget.Status.ObservedGeneration >= budget.Generation {
+		return DisruptionBudget{
+			Name:               budget.Name,
+			AllowedDisruptions: budget.Status.PodDisruptionsAllowed,
+			CurrentHealthy:     budget.Status.CurrentHealthy,
+			DesiredHealthy:     budget.Status.DesiredHealthy,
+			ExpectedPods:       budget.Status.ExpectedPods,
+		}
+	}
+
+	selected := selectedPods(budget, pods)
+	evaluated := DisruptionBudget{Name: budget.Name, ExpectedPods: int32(len(selected)), IsComputed: true}
+	for _, pod := range selected {
+		if isPodReady(pod) {
+			evaluated.CurrentHealthy++
+		}
+	}
+
+	//policy/v1beta1 defaults minAvailable to 1 when neither minAvailable nor maxUnavailable is set
+	minAvailable := intstr.FromInt(1)
+	switch {
+	case budget.Spec.MaxUnavailable != nil:
+		maxUnavailable, err := intstr.GetValueFromIntOrPercent(budget.Spec.MaxUnavailable, len(selected), true)
+		if err == nil {
+			evaluated.DesiredHealthy = evaluated.ExpectedPods - int32(maxUnavailable)
+		}
+	case budget.Spec.MinAvailable != nil:
+		minAvailable = *budget.Spec.MinAvailable
+		fallthrough
+	default:
+		desiredHealthy, err := intstr.GetValueFromIntOrPercent(&minAvailable, len(selected), true)
+		if err == nil {
+			evaluated.DesiredHealthy = int32(desiredHealthy)
+		}
+	}
+	if evaluated.DesiredHealthy < 0 {
+		evaluated.DesiredHealthy = 0
+	}
+
+	if evaluated.CurrentHealthy > evaluated.DesiredHealthy {
+		evaluated.AllowedDisruptions = evaluated.CurrentHealthy - evaluated.DesiredHealthy
+	}
+
+	return evaluated
+}
+
+//namespacePods returns pods of all groups keyed by namespace. Pods present in several groups are listed once.
+func namespacePods(mappedResources MappedResources) map[string][]core_v1.Pod {
+	pods := map[string][]core_v1.Pod{}
+	seen := map[string]bool{}
+	for _, mappedResource := range mappedResources.MappedResource {
+		for _, pod := range mappedResource.Kube.Pods {
+			key := pod.Namespace + "/" + pod.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			pods[pod.Namespace] = append(pods[pod.Namespace], pod)
+		}
+	}
+
+	return pods
+}
+
+//selectedPods returns pods budget selects. Empty selector of a policy/v1beta1 budget selects no pods.
+func selectedPods(budget policy_v1beta1.PodDisruptionBudget, pods []core_v1.Pod) []core_v1.Pod {
+	if isEmptySelector(budget.Spec.Selector) {
+		return nil
+	}
+	selector, err := meta_v1.LabelSelectorAsSelector(budget.Spec.Selector)
+	if err != nil {
+		return nil
+	}
+
+	var selected []core_v1.Pod
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil && selector.Matches(labels.Set(pod.Labels)) {
+			selected = append(selected, pod)
+		}
+	}
+
+	return selected
+}
+
+func isEmptySelector(selector *meta_v1.LabelSelector) bool {
+	return selector == nil || (len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0)
+}
+
+//isPodReady returns true when Ready condition of pod is true.
+func isPodReady(pod core_v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == core_v1.PodReady {
+			return condition.Status == core_v1.ConditionTrue
+		}
+	}
+
+	return false
+}
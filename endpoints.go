@@ -0,0 +1,379 @@
+package kubemap
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+//ReadyAnnotationPrefix prefixes annotations set on pods backing a service, like "ready.kubemap.io/my-service": "true".
+//Value tells whether pod is ready to serve traffic of service, as reported by Endpoints or EndpointSlices.
+const ReadyAnnotationPrefix = "ready.kubemap.io/"
+
+//endpointSliceServiceLabel names service of an EndpointSlice.
+const endpointSliceServiceLabel = "kubernetes.io/service-name"
+
+//EndpointSlice is a discovery.k8s.io/v1 EndpointSlice. k8s.io/api version kubemap builds with predates discovery/v1,
+//so fields used for mapping are declared here. Resource events of endpoint slices carry *EndpointSlice or *unstructured.Unstructured.
+type EndpointSlice struct {
+	meta_v1.TypeMeta   `json:",inline"`
+	meta_v1.ObjectMeta `json:"metadata,omitempty"`
+	AddressType        string              `json:"addressType"`
+	Endpoints          []Endpoint          `json:"endpoints"`
+	Ports              []EndpointSlicePort `json:"ports,omitempty"`
+}
+
+//Endpoint is an endpoint of EndpointSlice.
+type Endpoint struct {
+	Addresses  []string                 `json:"addresses"`
+	Conditions EndpointConditions       `json:"conditions,omitempty"`
+	Hostname   *string                  `json:"hostname,omitempty"`
+	TargetRef  *core_v1.ObjectReference `json:"targetRef,omitempty"`
+	NodeName   *string                  `json:"nodeName,omitempty"`
+	Zone       *string                  `json:"zone,omitempty"`
+}
+
+//EndpointConditions is state of Endpoint. Unset Ready is interpreted as ready.
+type EndpointConditions struct {
+	Ready       *bool `json:"ready,omitempty"`
+	Serving     *bool `json:"serving,omitempty"`
+	Terminating *bool `json:"terminating,omitempty"`
+}
+
+//EndpointSlicePort is a port of EndpointSlice.
+type EndpointSlicePort struct {
+	Name        *string           `json:"name,omitempty"`
+	Protocol    *core_v1.Protocol `json:"protocol,omitempty"`
+	Port        *int32            `json:"port,omitempty"`
+	AppProtocol *string           `json:"appProtocol,omitempty"`
+}
+
+//DeepCopy returns a deep copy of endpoint slice.
+func (in *EndpointSlice) DeepCopy() *EndpointSlice {
+	if in == nil {
+		return nil
+	}
+
+	out := new(EndpointSlice)
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.AddressType = in.AddressType
+
+	if in.Endpoints != nil {
+		out.Endpoints = make([]Endpoint, len(in.Endpoints))
+		for i, endpoint := range in.Endpoints {
+			out.Endpoints[i] = Endpoint{
+				Addresses:  append([]string(nil), endpoint.Addresses...),
+				Conditions: EndpointConditions{Ready: copyBool(endpoint.Conditions.Ready), Serving: copyBool(endpoint.Conditions.Serving), Terminating: copyBool(endpoint.Conditions.Terminating)},
+				Hostname:   copyString(endpoint.Hostname),
+				TargetRef:  endpoint.TargetRef.DeepCopy(),
+				NodeName:   copyString(endpoint.NodeName),
+				Zone:       copyString(endpoint.Zone),
+			}
+		}
+	}
+
+	if in.Ports != nil {
+		out.Ports = make([]EndpointSlicePort, len(in.Ports))
+		for i, port := range in.Ports {
+			out.Ports[i] = EndpointSlicePort{Name: copyString(port.Name), AppProtocol: copyString(port.AppProtocol)}
+			if port.Protocol != nil {
+				protocol := *port.Protocol
+				out.Ports[i].Protocol = &protocol
+			}
+			if port.Port != nil {
+				portNumber := *port.Port
+				out.Ports[i].Port = &portNumber
+			}
+		}
+	}
+
+	return out
+}
+
+func copyBool(in *bool) *bool {
+	if in == nil {
+		return nil
+	}
+	out := *in
+	return &out
+}
+
+func copyString(in *string) *string {
+	if in == nil {
+		return nil
+	}
+	out := *in
+	return &out
+}
+
+//endpointSliceFromEvent returns endpoint slice carried by a resource event.
+func endpointSliceFromEvent(event interface{}) (EndpointSlice, bool) {
+	switch object := event.(type) {
+	case *EndpointSlice:
+		return *object.DeepCopy(), true
+	case *unstructured.Unstructured:
+		if object.GetKind() != "EndpointSlice" {
+			return EndpointSlice{}, false
+		}
+		var endpointSlice EndpointSlice
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.UnstructuredContent(), &endpointSlice); err != nil {
+			return EndpointSlice{}, false
+		}
+		return endpointSlice, true
+	}
+
+	return EndpointSlice{}, false
+}
+
+//ServiceEndpoint is a pod backing a service of group.
+type ServiceEndpoint struct {
+	Service string `json:"service"`
+	Pod     string `json:"pod"`
+	Ready   bool   `json:"ready"`
+	//IsPodMapped is true when pod is in group. Pods of services without selector usually are not.
+	IsPodMapped bool `json:"isPodMapped"`
+}
+
+//ServiceEndpoints returns pods backing services of mapped resource, as reported by its Endpoints and EndpointSlices.
+//Pods reported by both are listed once. Pod reported not ready by any of them is not ready.
+func ServiceEndpoints(mappedResource MappedResource) []ServiceEndpoint {
+	pods := map[string]bool{}
+	for _, pod := range mappedResource.Kube.Pods {
+		pods[pod.Name] = true
+	}
+
+	var serviceEndpoints []ServiceEndpoint
+	for service, readiness := range podReadiness(mappedResource.Kube) {
+		for pod, ready := range readiness {
+			serviceEndpoints = append(serviceEndpoints, ServiceEndpoint{Service: service, Pod: pod, Ready: ready, IsPodMapped: pods[pod]})
+		}
+	}
+
+	sort.Slice(serviceEndpoints, func(i, j int) bool {
+		if serviceEndpoints[i].Service != serviceEndpoints[j].Service {
+			return serviceEndpoints[i].Service < serviceEndpoints[j].Service
+		}
+		return serviceEndpoints[i].Pod < serviceEndpoints[j].Pod
+	})
+
+	return serviceEndpoints
+}
+
+//podReadiness returns readiness of pods referenced by endpoints of group, keyed by service and pod name.
+func podReadiness(kube Kube) map[string]map[string]bool {
+	readiness := map[string]map[string]bool{}
+	set := func(service string, targetRef *core_v1.ObjectReference, ready bool) {
+		if targetRef == nil || targetRef.Kind != "Pod" || service == "" {
+			return
+		}
+		if readiness[service] == nil {
+			readiness[service] = map[string]bool{}
+		}
+		if isReady, ok := readiness[service][targetRef.Name]; ok {
+			ready = ready && isReady
+		}
+		readiness[service][targetRef.Name] = ready
+	}
+
+	for _, endpoints := range kube.Endpoints {
+		for _, subset := range endpoints.Subsets {
+			for _, address := range subset.Addresses {
+				set(endpoints.Name, address.TargetRef, true)
+			}
+			for _, address := range subset.NotReadyAddresses {
+				set(endpoints.Name, address.TargetRef, false)
+			}
+		}
+	}
+
+	for _, endpointSlice := range kube.EndpointSlices {
+		for _, endpoint := range endpointSlice.Endpoints {
+			set(endpointSlice.Labels[endpointSliceServiceLabel], endpoint.TargetRef, endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready)
+		}
+	}
+
+	return readiness
+}
+
+//annotateResults sets ready annotations on pods of results not yet written to store.
+func annotateResults(results []MapResult) {
+	for i := range results {
+		if !results[i].IsStoreUpdated {
+			annotatePodReadiness(&results[i].MappedResource.Kube)
+		}
+	}
+}
+
+//annotatePodReadiness sets ready annotations of pods in group. Annotations of services which do not report pod anymore are removed.
+//Pods are copied before they are annotated.
+func annotatePodReadiness(kube *Kube) {
+	readiness := podReadiness(*kube)
+
+	for i, pod := range kube.Pods {
+		annotations := map[string]string{}
+		for service, pods := range readiness {
+			if ready, ok := pods[pod.Name]; ok {
+				annotations[ReadyAnnotationPrefix+service] = strconv.FormatBool(ready)
+			}
+		}
+
+		isChanged := false
+		for key, value := range pod.Annotations {
+			if strings.HasPrefix(key, ReadyAnnotationPrefix) && annotations[key] != value {
+				isChanged = true
+			}
+		}
+		for key, value := range annotations {
+			if pod.Annotations[key] != value {
+				isChanged = true
+			}
+		}
+		if !isChanged {
+			continue
+		}
+
+		pod = *pod.DeepCopy()
+		for key := range pod.Annotations {
+			if strings.HasPrefix(key, ReadyAnnotationPrefix) {
+				delete(pod.Annotations, key)
+			}
+		}
+		if len(annotations) > 0 && pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		for key, value := range annotations {
+			pod.Annotations[key] = value
+		}
+		kube.Pods[i] = pod
+	}
+}
+
+//linkEndpointPods merges groups holding pods which endpoints of a service reference into group of service,
+//so that services are linked to pods they route to even when their selector does not match them.
+//Store is updated right away. Results which do not change store are not returned.
+func (m *Mapper) linkEndpointPods(ctx context.Context, namespace string, store cache.Store) ([]MapResult, error) {
+	namespaceKeys, err := getNamespaceKeys(namespace, store)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]MappedResource, len(namespaceKeys))
+	podGroups := map[string]int{}
+	for i, namespaceKey := range namespaceKeys {
+		mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+		if err != nil {
+			return nil, err
+		}
+		groups[i] = mappedResource
+		for _, pod := range mappedResource.Kube.Pods {
+			podGroups[pod.Name] = i
+		}
+	}
+
+	//Groups holding pods endpoints of a group's services reference, and why they are linked.
+	linked := map[int][]int{}
+	reasons := map[int][]string{}
+	for i, mappedResource := range groups {
+		//Endpoints kept standalone, without their service, link nothing.
+		readiness := podReadiness(mappedResource.Kube)
+		var services []string
+		for _, service := range mappedResource.Kube.Services {
+			if _, ok := readiness[service.Name]; ok {
+				services = append(services, service.Name)
+			}
+		}
+		sort.Strings(services)
+
+		for _, service := range services {
+			pods := make([]string, 0, len(readiness[service]))
+			for pod := range readiness[service] {
+				pods = append(pods, pod)
+			}
+			sort.Strings(pods)
+
+			for _, pod := range pods {
+				if j, ok := podGroups[pod]; ok && j != i {
+					linked[i] = append(linked[i], j)
+					linked[j] = append(linked[j], i)
+					reasons[i] = append(reasons[i], fmt.Sprintf("service %s routes to pod %s", service, pod))
+				}
+			}
+		}
+	}
+
+	//Groups linked directly or through others are merged into the first of them holding a linking service.
+	isMerged := make([]bool, len(groups))
+	var results []MapResult
+	for i := range groups {
+		if isMerged[i] || len(reasons[i]) == 0 {
+			continue
+		}
+
+		mappedResource := groups[i]
+		deleteKeys := []string{namespaceKeys[i]}
+		linkReasons := reasons[i]
+		var mergedLabels []string
+		isMerged[i] = true
+		pending := linked[i]
+		for len(pending) > 0 {
+			j := pending[0]
+			pending = pending[1:]
+			if isMerged[j] {
+				continue
+			}
+			isMerged[j] = true
+			mergeMembers(&mappedResource, groups[j])
+			deleteKeys = append(deleteKeys, namespaceKeys[j])
+			mergedLabels = append(mergedLabels, groups[j].CommonLabel)
+			linkReasons = append(linkReasons, reasons[j]...)
+			pending = append(pending, linked[j]...)
+		}
+
+		results = append(results, MapResult{
+			Action:         "Updated",
+			DeleteKeys:     deleteKeys,
+			IsMapped:       true,
+			MappedResource: mappedResource,
+			Message:        fmt.Sprintf("Common Label %s is merged into %s as %s", strings.Join(mergedLabels, ", "), mappedResource.CommonLabel, strings.Join(linkReasons, ", ")),
+		})
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	results, err = attachSatellites(results, store, satelliteKinds)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.updateStore(ctx, results, store); err != nil {
+		return nil, err
+	}
+
+	var linkResults []MapResult
+	for _, result := range results {
+		if result.Action != "Unchanged" {
+			result.IsStoreUpdated = true
+			linkResults = append(linkResults, result)
+		}
+	}
+	return linkResults, nil
+}
+
+//mergeMembers adds ingresses, services and workloads of group to mapped resource. Satellites are attached to merged group afterwards.
+func mergeMembers(mappedResource *MappedResource, group MappedResource) {
+	mappedResource.Kube.Ingresses = append(mappedResource.Kube.Ingresses, group.Kube.Ingresses...)
+	mappedResource.Kube.Services = append(mappedResource.Kube.Services, group.Kube.Services...)
+	mappedResource.Kube.Deployments = append(mappedResource.Kube.Deployments, group.Kube.Deployments...)
+	mappedResource.Kube.ReplicaSets = append(mappedResource.Kube.ReplicaSets, group.Kube.ReplicaSets...)
+	mappedResource.Kube.Pods = append(mappedResource.Kube.Pods, group.Kube.Pods...)
+}
@@ -0,0 +1,53 @@
+package kubemap
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	//ErrUnsupportedKind is returned when resource type of an event cannot be mapped.
+	ErrUnsupportedKind = errors.New("resource type is not supported for mapping")
+	//ErrInvalidEvent is returned when event is not a ResourceEvent or does not carry expected k8s object.
+	ErrInvalidEvent = errors.New("invalid resource event")
+	//ErrStoreInconsistent is returned when store content does not match keys listed by it.
+	ErrStoreInconsistent = errors.New("store is inconsistent")
+	//ErrKeyNotFound is returned when a mapped resource is not present in store.
+	ErrKeyNotFound = errors.New("key not found in store")
+)
+
+//MapError describes failure to map a resource event.
+//Use errors.Is with sentinel errors above to find out the cause.
+type MapError struct {
+	ResourceType string
+	Namespace    string
+	Name         string
+	Err          error
+}
+
+func (e *MapError) Error() string {
+	return fmt.Sprintf("Cannot map %s %s/%s - %v", e.ResourceType, e.Namespace, e.Name, e.Err)
+}
+
+//Unwrap returns cause of the mapping failure.
+func (e *MapError) Unwrap() error {
+	return e.Err
+}
+
+func invalidEventError(obj ResourceEvent) error {
+	return fmt.Errorf("%w: %s event carries %T", ErrInvalidEvent, obj.ResourceType, obj.Event)
+}
+
+//missingSelectorError is returned for workloads without selector, which cannot be linked to their pods.
+func missingSelectorError(obj ResourceEvent) error {
+	return fmt.Errorf("%w: %s %s has no selector", ErrInvalidEvent, obj.ResourceType, obj.Name)
+}
+
+//isPermanentError returns true for errors which will not go away when mapping is retried.
+func isPermanentError(err error) bool {
+	return errors.Is(err, ErrUnsupportedKind) || errors.Is(err, ErrInvalidEvent)
+}
+
+func storeKeyError(key string, err error) error {
+	return fmt.Errorf("%w: cannot decode key %s - %v", ErrStoreInconsistent, key, err)
+}
@@ -0,0 +1,265 @@
+package kubemap
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+//Types of ExternalDependency
+const (
+	//ExternalDependencyDNS is DNS name an ExternalName service points to.
+	ExternalDependencyDNS = "dns"
+	//ExternalDependencyAddress is address of a service without selector which does not reference a pod.
+	ExternalDependencyAddress = "address"
+)
+
+//ExternalDependency is a target outside of cluster which a service of group routes to.
+type ExternalDependency struct {
+	Service string `json:"service"`
+	Type    string `json:"type"`
+	Target  string `json:"target"`
+}
+
+//isSelectorlessService returns true for services which never match labels of workloads.
+//They are linked to groups through ingress backends and pods referenced by their endpoints.
+func isSelectorlessService(service core_v1.Service) bool {
+	return len(service.Spec.Selector) == 0 || service.Spec.Type == core_v1.ServiceTypeExternalName
+}
+
+//mapSelectorlessService adds service to group holding pods its endpoints reference, or to group already holding it.
+//Service is moved to group of its pods only when its current group has no workloads.
+func (m *Mapper) mapSelectorlessService(service core_v1.Service, namespaceKeys []string, store cache.Store) ([]MapResult, error) {
+	groups := make([]MappedResource, len(namespaceKeys))
+	targets := map[string]bool{}
+	serviceIndex := -1
+
+	for i, namespaceKey := range namespaceKeys {
+		mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+		if err != nil {
+			return []MapResult{}, err
+		}
+		groups[i] = mappedResource
+
+		for _, mappedService := range mappedResource.Kube.Services {
+			if mappedService.Name == service.Name {
+				serviceIndex = i
+			}
+		}
+		for pod := range podReadiness(mappedResource.Kube)[service.Name] {
+			targets[pod] = true
+		}
+	}
+
+	podsIndex := -1
+	if service.Spec.Type != core_v1.ServiceTypeExternalName {
+		for i, mappedResource := range groups {
+			for _, pod := range mappedResource.Kube.Pods {
+				if targets[pod.Name] && podsIndex < 0 {
+					podsIndex = i
+				}
+			}
+		}
+	}
+
+	var mappedResource MappedResource
+	var deleteKeys []string
+	var message string
+	action := "Updated"
+
+	switch {
+	case podsIndex >= 0 && podsIndex != serviceIndex && (serviceIndex < 0 || !hasWorkloads(groups[serviceIndex])):
+		mappedResource = groups[podsIndex]
+		deleteKeys = append(deleteKeys, namespaceKeys[podsIndex])
+		if serviceIndex >= 0 {
+			//Ingresses routing to service move along with it
+			for _, ingress := range groups[serviceIndex].Kube.Ingresses {
+				if !hasIngress(mappedResource, ingress.Name) {
+					mappedResource.Kube.Ingresses = append(mappedResource.Kube.Ingresses, ingress)
+				}
+			}
+			deleteKeys = append(deleteKeys, namespaceKeys[serviceIndex])
+		}
+		if len(mappedResource.Kube.Services) < 1 { //Set Common Label to service name.
+			mappedResource.CommonLabel = service.Name
+		}
+		message = fmt.Sprintf("Service %s is added to Common Label %s after matching with endpoints.", service.Name, mappedResource.CommonLabel)
+	case serviceIndex >= 0:
+		mappedResource = groups[serviceIndex]
+		deleteKeys = append(deleteKeys, namespaceKeys[serviceIndex])
+		message = fmt.Sprintf("Service %s is updated in Common Label %s.", service.Name, mappedResource.CommonLabel)
+	default:
+		mappedResource = MappedResource{
+			CommonLabel: service.Name,
+			CurrentType: "service",
+			Namespace:   service.Namespace,
+		}
+		action = "Added"
+		message = fmt.Sprintf("New service %s is added with Common Label %s", service.Name, service.Name)
+	}
+	setService(&mappedResource, service)
+
+	mappedResource, ingressDeleteKeys, err := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+	if err != nil {
+		return []MapResult{}, err
+	}
+	deleteKeys = removeDuplicateStrings(append(deleteKeys, ingressDeleteKeys...))
+
+	return []MapResult{{
+		Action:         action,
+		DeleteKeys:     deleteKeys,
+		IsMapped:       true,
+		MappedResource: mappedResource,
+		Message:        message,
+	}}, nil
+}
+
+//relinkSelectorlessService maps service without selector again after its endpoints change, so that it follows pods they reference.
+//Store is updated right away. Results which do not change store are not returned.
+func (m *Mapper) relinkSelectorlessService(ctx context.Context, obj ResourceEvent, store cache.Store) ([]MapResult, error) {
+	serviceName := obj.Name
+	if obj.ResourceType == "endpointslice" {
+		endpointSlice, ok := endpointSliceFromEvent(obj.Event)
+		if !ok {
+			return nil, nil
+		}
+		serviceName = endpointSlice.Labels[endpointSliceServiceLabel]
+	}
+
+	namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+	if err != nil {
+		return nil, err
+	}
+	for _, namespaceKey := range namespaceKeys {
+		mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, service := range mappedResource.Kube.Services {
+			if service.Name != serviceName || !isSelectorlessService(service) || service.Spec.Type == core_v1.ServiceTypeExternalName {
+				continue
+			}
+
+			results, err := m.mapSelectorlessService(service, namespaceKeys, store)
+			if err != nil {
+				return nil, err
+			}
+			results, err = attachSatellites(results, store, satelliteKinds)
+			if err != nil {
+				return nil, err
+			}
+			if err := m.updateStore(ctx, results, store); err != nil {
+				return nil, err
+			}
+
+			var relinkResults []MapResult
+			for _, result := range results {
+				if result.Action != "Unchanged" {
+					result.IsStoreUpdated = true
+					relinkResults = append(relinkResults, result)
+				}
+			}
+			return relinkResults, nil
+		}
+	}
+
+	return nil, nil
+}
+
+//setService replaces service of same name in group, or adds it.
+func setService(mappedResource *MappedResource, service core_v1.Service) {
+	for i, mappedService := range mappedResource.Kube.Services {
+		if mappedService.Name == service.Name {
+			mappedResource.Kube.Services[i] = service
+			return
+		}
+	}
+
+	mappedResource.Kube.Services = append(mappedResource.Kube.Services, service)
+}
+
+func hasIngress(mappedResource MappedResource, name string) bool {
+	for _, ingress := range mappedResource.Kube.Ingresses {
+		if ingress.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasWorkloads(mappedResource MappedResource) bool {
+	return len(mappedResource.Kube.Deployments) > 0 || len(mappedResource.Kube.ReplicaSets) > 0 || len(mappedResource.Kube.Pods) > 0
+}
+
+//externalDependencyResults sets external dependencies of groups of results not yet written to store.
+func externalDependencyResults(results []MapResult) {
+	for i := range results {
+		if !results[i].IsStoreUpdated {
+			results[i].MappedResource.ExternalDependencies = externalDependencies(results[i].MappedResource.Kube)
+		}
+	}
+}
+
+//externalDependencies returns DNS names of ExternalName services of group and addresses of services without selector
+//which do not reference a pod.
+func externalDependencies(kube Kube) []ExternalDependency {
+	var dependencies []ExternalDependency
+	seen := map[ExternalDependency]bool{}
+	add := func(dependency ExternalDependency) {
+		if dependency.Target != "" && !seen[dependency] {
+			seen[dependency] = true
+			dependencies = append(dependencies, dependency)
+		}
+	}
+
+	selectorless := map[string]bool{}
+	for _, service := range kube.Services {
+		if service.Spec.Type == core_v1.ServiceTypeExternalName {
+			add(ExternalDependency{Service: service.Name, Type: ExternalDependencyDNS, Target: service.Spec.ExternalName})
+		} else if isSelectorlessService(service) {
+			selectorless[service.Name] = true
+		}
+	}
+
+	for _, endpoints := range kube.Endpoints {
+		if !selectorless[endpoints.Name] {
+			continue
+		}
+		for _, subset := range endpoints.Subsets {
+			for _, address := range append(append([]core_v1.EndpointAddress{}, subset.Addresses...), subset.NotReadyAddresses...) {
+				if address.TargetRef == nil {
+					add(ExternalDependency{Service: endpoints.Name, Type: ExternalDependencyAddress, Target: address.IP})
+				}
+			}
+		}
+	}
+	for _, endpointSlice := range kube.EndpointSlices {
+		service := endpointSlice.Labels[endpointSliceServiceLabel]
+		if !selectorless[service] {
+			continue
+		}
+		for _, endpoint := range endpointSlice.Endpoints {
+			if endpoint.TargetRef != nil {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				add(ExternalDependency{Service: service, Type: ExternalDependencyAddress, Target: address})
+			}
+		}
+	}
+
+	sort.Slice(dependencies, func(i, j int) bool {
+		if dependencies[i].Service != dependencies[j].Service {
+			return dependencies[i].Service < dependencies[j].Service
+		}
+		return dependencies[i].Target < dependencies[j].Target
+	})
+
+	return dependencies
+}
@@ -0,0 +1,142 @@
+package kubemap
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+//FilterOptions selects resources which are mapped. Resource events filtered out are rejected with action 'Rejected'.
+type FilterOptions struct {
+	//IncludeNamespaces maps only namespaces matching one of given glob patterns, like "team-*". Empty includes all namespaces.
+	//Cluster scoped objects are filtered by namespace of groups they belong to, like PersistentVolumes by namespace of their claim.
+	//Ones used by groups of any namespace, like Nodes and ClusterRoles, and unbound PersistentVolumes are not filtered by namespace.
+	IncludeNamespaces []string
+	//ExcludeNamespaces skips namespaces matching one of given glob patterns, like "kube-*".
+	ExcludeNamespaces []string
+	//LabelSelector maps only objects with matching labels, like "kubemap.io/ignore!=true".
+	//It is not applied to DELETED events as they do not carry labels. UPDATED events of objects which do not match
+	//anymore are mapped as DELETED events, so that objects mapped before are removed.
+	LabelSelector string
+	//Kinds maps only given resource types, like "service" or "pod". Empty maps all supported kinds.
+	Kinds []string
+}
+
+var filterKinds = []string{"ingress", "service", "deployment", "replicaset", "pod", "configmap", "secret", "persistentvolumeclaim", "persistentvolume", "endpoints", "endpointslice", "networkpolicy", "poddisruptionbudget", "serviceaccount", "rolebinding", "clusterrolebinding", "role", "clusterrole", "node"}
+
+//filter is compiled form of FilterOptions. Zero value accepts all resources.
+type filter struct {
+	includeNamespaces []string
+	excludeNamespaces []string
+	selector          labels.Selector
+	kinds             map[string]bool
+}
+
+func newFilter(options FilterOptions) (filter, error) {
+	f := filter{
+		includeNamespaces: options.IncludeNamespaces,
+		excludeNamespaces: options.ExcludeNamespaces,
+	}
+
+	for _, pattern := range append(append([]string{}, options.IncludeNamespaces...), options.ExcludeNamespaces...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return filter{}, fmt.Errorf("Cannot instantiate Mapper. Invalid namespace pattern %s provided - %v", pattern, err)
+		}
+	}
+
+	if options.LabelSelector != "" {
+		selector, err := labels.Parse(options.LabelSelector)
+		if err != nil {
+			return filter{}, fmt.Errorf("Cannot instantiate Mapper. Invalid label selector %s provided - %v", options.LabelSelector, err)
+		}
+		f.selector = selector
+	}
+
+	if len(options.Kinds) > 0 {
+		f.kinds = map[string]bool{}
+		for _, kind := range options.Kinds {
+			if !isFilterKind(kind) {
+				return filter{}, fmt.Errorf("Cannot instantiate Mapper. Invalid kind %s provided. Accepted values are '%s'", kind, strings.Join(filterKinds, "', '"))
+			}
+			f.kinds[kind] = true
+		}
+	}
+
+	return f, nil
+}
+
+//reject returns reason for which resource event is not mapped, or empty string when it is accepted.
+func (f filter) reject(obj ResourceEvent) string {
+	if f.kinds != nil && !f.kinds[obj.ResourceType] {
+		return fmt.Sprintf("Kind %s is not enabled", obj.ResourceType)
+	}
+
+	if namespace, ok := filterNamespace(obj); ok {
+		if len(f.includeNamespaces) > 0 && !matchesNamespace(f.includeNamespaces, namespace) {
+			return fmt.Sprintf("Namespace %s is not included", namespace)
+		}
+
+		if pattern := matchingNamespacePattern(f.excludeNamespaces, namespace); pattern != "" {
+			return fmt.Sprintf("Namespace %s is excluded by pattern %s", namespace, pattern)
+		}
+	}
+
+	return f.rejectLabels(obj)
+}
+
+//rejectLabels returns reason for which labels of resource event are not mapped, or empty string when they match selector.
+func (f filter) rejectLabels(obj ResourceEvent) string {
+	if f.selector != nil && obj.Event != nil {
+		if !f.selector.Matches(labels.Set(objectMetaData(obj.Event).Labels)) {
+			return fmt.Sprintf("Labels do not match selector %s", f.selector.String())
+		}
+	}
+
+	return ""
+}
+
+//filterNamespace returns namespace which namespace filters apply to for resource event.
+//It returns false for cluster scoped objects which do not belong to groups of a single namespace.
+func filterNamespace(obj ResourceEvent) (string, bool) {
+	satellite, ok := getSatelliteKind(obj.ResourceType)
+	if !ok || !satellite.isClusterScoped() {
+		return obj.Namespace, true
+	}
+	if satellite.namespace == nil || obj.Event == nil {
+		return "", false
+	}
+
+	object, ok := satellite.eventObject(obj.Event)
+	if !ok {
+		return "", false
+	}
+	namespace := satellite.namespace(object)
+
+	return namespace, namespace != ""
+}
+
+func matchesNamespace(patterns []string, namespace string) bool {
+	return matchingNamespacePattern(patterns, namespace) != ""
+}
+
+func matchingNamespacePattern(patterns []string, namespace string) string {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, namespace); matched {
+			return pattern
+		}
+	}
+
+	return ""
+}
+
+func isFilterKind(kind string) bool {
+	for _, filterKind := range filterKinds {
+		if kind == filterKind {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,312 @@
+package kubemap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+//ErrHistoryDisabled is returned by history queries when Mapper was created without HistoryOptions.Enabled.
+var ErrHistoryDisabled = errors.New("history is not enabled")
+
+//HistoryOptions controls recording of store changes made by Mapper.
+type HistoryOptions struct {
+	Enabled bool
+	//Retention compacts entries older than given duration. 0 keeps entries forever.
+	Retention time.Duration
+	//MaxEntries compacts oldest entries when log grows beyond given size. 0 means no limit.
+	//Compaction keeps last state of each group which was not deleted, so log can hold more entries than this.
+	MaxEntries int
+}
+
+//HistoryEntry is a change of a mapped resource (group) in store.
+//Action is 'Added', 'Updated' or 'Deleted'. MappedResource is the state of group after the change.
+type HistoryEntry struct {
+	Time           time.Time
+	Action         string
+	Namespace      string
+	CommonLabel    string
+	MappedResource MappedResource
+}
+
+//MembershipChange is a k8s object joining or leaving a group.
+//Change is 'Joined' or 'Left'.
+type MembershipChange struct {
+	Time        time.Time
+	Namespace   string
+	CommonLabel string
+	Kind        string
+	Name        string
+	Change      string
+}
+
+//history is an append-only log of store changes.
+//Entries dropped by retention are compacted, so state of groups which still exist can be reconstructed.
+type history struct {
+	mu      sync.RWMutex
+	options HistoryOptions
+	entries []HistoryEntry
+}
+
+func newHistory(options HistoryOptions) *history {
+	if !options.Enabled {
+		return nil
+	}
+
+	return &history{options: options}
+}
+
+//record appends change made to store by a map result.
+//removed are mapped resources deleted from store while applying it.
+func (h *history) record(result MapResult, removed []MappedResource) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	//Time is taken under lock so entries stay ordered.
+	now := time.Now()
+
+	for _, mappedResource := range removed {
+		if result.Action != "Deleted" && isSameGroup(mappedResource, result.MappedResource) {
+			continue
+		}
+		h.entries = append(h.entries, HistoryEntry{
+			Time:           now,
+			Action:         "Deleted",
+			Namespace:      mappedResource.Namespace,
+			CommonLabel:    mappedResource.CommonLabel,
+			MappedResource: mappedResource,
+		})
+	}
+
+	if result.Action == "Added" || result.Action == "Updated" {
+		h.entries = append(h.entries, HistoryEntry{
+			Time:           now,
+			Action:         result.Action,
+			Namespace:      result.MappedResource.Namespace,
+			CommonLabel:    result.MappedResource.CommonLabel,
+			MappedResource: historyMappedResource(result.MappedResource),
+		})
+	}
+
+	h.prune(now)
+}
+
+//prune compacts entries dropped by retention to the last state of each group still present in store.
+func (h *history) prune(now time.Time) {
+	cut := 0
+	if h.options.Retention > 0 {
+		horizon := now.Add(-h.options.Retention)
+		cut = sort.Search(len(h.entries), func(i int) bool {
+			return !h.entries[i].Time.Before(horizon)
+		})
+	}
+	if h.options.MaxEntries > 0 && len(h.entries)-cut > h.options.MaxEntries {
+		cut = len(h.entries) - h.options.MaxEntries
+	}
+	if cut == 0 {
+		return
+	}
+
+	//Entries of a group before cut are replaced with its last one, unless group was deleted.
+	lastEntries := map[string]int{}
+	for i, entry := range h.entries[:cut] {
+		lastEntries[historyGroupKey(entry.Namespace, entry.CommonLabel)] = i
+	}
+
+	var entries []HistoryEntry
+	for i, entry := range h.entries[:cut] {
+		if lastEntries[historyGroupKey(entry.Namespace, entry.CommonLabel)] == i && entry.Action != "Deleted" {
+			entries = append(entries, entry)
+		}
+	}
+	h.entries = append(entries, h.entries[cut:]...)
+}
+
+//groupEntries returns entries of a group in order of time.
+//Empty commonLabel matches all groups of namespace and empty namespace matches all namespaces.
+func (h *history) groupEntries(namespace string, commonLabel string) []HistoryEntry {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var entries []HistoryEntry
+	for _, entry := range h.entries {
+		if (namespace == "" || entry.Namespace == namespace) && (commonLabel == "" || entry.CommonLabel == commonLabel) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+//MappedResourceAt returns group with given common label as it was at given time.
+//ErrKeyNotFound is returned when group did not exist at that time.
+func (m *Mapper) MappedResourceAt(namespace string, commonLabel string, at time.Time) (MappedResource, error) {
+	if m.history == nil {
+		return MappedResource{}, ErrHistoryDisabled
+	}
+
+	var state *HistoryEntry
+	entries := m.history.groupEntries(namespace, commonLabel)
+	for i := range entries {
+		if entries[i].Time.After(at) {
+			break
+		}
+		state = &entries[i]
+	}
+
+	if state == nil || state.Action == "Deleted" {
+		return MappedResource{}, fmt.Errorf("%w: Group %s in namespace %s did not exist at %s", ErrKeyNotFound, commonLabel, namespace, at.Format(time.RFC3339))
+	}
+
+	return state.MappedResource, nil
+}
+
+//MembershipChanges lists k8s objects which joined or left groups between from and to, both inclusive.
+//Empty commonLabel lists changes of all groups of namespace and empty namespace lists changes of all namespaces.
+func (m *Mapper) MembershipChanges(namespace string, commonLabel string, from time.Time, to time.Time) ([]MembershipChange, error) {
+	if m.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+
+	var changes []MembershipChange
+	members := map[string]map[string]MembershipChange{}
+	for _, entry := range m.history.groupEntries(namespace, commonLabel) {
+		if entry.Time.After(to) {
+			break
+		}
+
+		groupKey := historyGroupKey(entry.Namespace, entry.CommonLabel)
+		current := map[string]MembershipChange{}
+		if entry.Action != "Deleted" {
+			current = groupMembers(entry.MappedResource)
+		}
+		previous := members[groupKey]
+		members[groupKey] = current
+
+		if entry.Time.Before(from) {
+			continue
+		}
+
+		for _, memberKey := range sortedMemberKeys(current) {
+			if _, ok := previous[memberKey]; !ok {
+				changes = append(changes, membershipChange(entry, current[memberKey], "Joined"))
+			}
+		}
+		for _, memberKey := range sortedMemberKeys(previous) {
+			if _, ok := current[memberKey]; !ok {
+				changes = append(changes, membershipChange(entry, previous[memberKey], "Left"))
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+//groupMembers returns kind and name of objects in mapped resource keyed by kind/name.
+func groupMembers(mappedResource MappedResource) map[string]MembershipChange {
+	members := map[string]MembershipChange{}
+	add := func(kind string, name string) {
+		members[kind+"/"+name] = MembershipChange{Kind: kind, Name: name}
+	}
+
+	for _, ingress := range mappedResource.Kube.Ingresses {
+		add("ingress", ingress.Name)
+	}
+	for _, service := range mappedResource.Kube.Services {
+		add("service", service.Name)
+	}
+	for _, deployment := range mappedResource.Kube.Deployments {
+		add("deployment", deployment.Name)
+	}
+	for _, replicaSet := range mappedResource.Kube.ReplicaSets {
+		add("replicaset", replicaSet.Name)
+	}
+	for _, pod := range mappedResource.Kube.Pods {
+		add("pod", pod.Name)
+	}
+	for _, configMap := range mappedResource.Kube.ConfigMaps {
+		add("configmap", configMap.Name)
+	}
+	for _, secret := range mappedResource.Kube.Secrets {
+		add("secret", secret.Name)
+	}
+	for _, claim := range mappedResource.Kube.PersistentVolumeClaims {
+		add("persistentvolumeclaim", claim.Name)
+	}
+	for _, volume := range mappedResource.Kube.PersistentVolumes {
+		add("persistentvolume", volume.Name)
+	}
+	for _, endpoints := range mappedResource.Kube.Endpoints {
+		add("endpoints", endpoints.Name)
+	}
+	for _, endpointSlice := range mappedResource.Kube.EndpointSlices {
+		add("endpointslice", endpointSlice.Name)
+	}
+	for _, networkPolicy := range mappedResource.Kube.NetworkPolicies {
+		add("networkpolicy", networkPolicy.Name)
+	}
+	for _, budget := range mappedResource.Kube.PodDisruptionBudgets {
+		add("poddisruptionbudget", budget.Name)
+	}
+	for _, serviceAccount := range mappedResource.Kube.ServiceAccounts {
+		add("serviceaccount", serviceAccount.Name)
+	}
+	for _, roleBinding := range mappedResource.Kube.RoleBindings {
+		add("rolebinding", roleBinding.Name)
+	}
+	for _, clusterRoleBinding := range mappedResource.Kube.ClusterRoleBindings {
+		add("clusterrolebinding", clusterRoleBinding.Name)
+	}
+	for _, role := range mappedResource.Kube.Roles {
+		add("role", role.Name)
+	}
+	for _, clusterRole := range mappedResource.Kube.ClusterRoles {
+		add("clusterrole", clusterRole.Name)
+	}
+	for _, node := range mappedResource.Kube.Nodes {
+		add("node", node.Name)
+	}
+
+	return members
+}
+
+func sortedMemberKeys(members map[string]MembershipChange) []string {
+	keys := make([]string, 0, len(members))
+	for key := range members {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func membershipChange(entry HistoryEntry, member MembershipChange, change string) MembershipChange {
+	member.Time = entry.Time
+	member.Namespace = entry.Namespace
+	member.CommonLabel = entry.CommonLabel
+	member.Change = change
+
+	return member
+}
+
+//historyMappedResource copies mapped resource so entries do not share k8s objects with store.
+func historyMappedResource(mappedResource MappedResource) MappedResource {
+	copiedMappedResource := copyMappedResource(mappedResource)
+	copiedMappedResource.EventType = mappedResource.EventType
+
+	return copiedMappedResource
+}
+
+func isSameGroup(a MappedResource, b MappedResource) bool {
+	return a.Namespace == b.Namespace && a.CommonLabel == b.CommonLabel
+}
+
+func historyGroupKey(namespace string, commonLabel string) string {
+	return namespace + "$" + commonLabel
+}
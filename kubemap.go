@@ -1,8 +1,8 @@
 package kubemap
 
 import (
+	"context"
 	"fmt"
-	"log"
 
 	"k8s.io/client-go/tools/cache"
 
@@ -25,22 +25,7 @@ func NewMapper() *Mapper {
 
 //NewMapperWithOptions creates a Mapper to map interlinked K8s resources with custom options
 func NewMapperWithOptions(options MapOptions) (*Mapper, error) {
-	store := cache.NewStore(metaResourceKeyFunc)
-	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-
-	zapLogger, zapErr := getZapLogger(options.Logging.LogLevel)
-	if zapErr != nil {
-		return nil, zapErr
-	}
-
-	return &Mapper{
-		store: store,
-		queue: queue,
-		log: Logger{
-			enabled: options.Logging.Enabled,
-			logger:  zapLogger,
-		},
-	}, nil
+	return newMapper(cache.NewStore(metaResourceKeyFunc), options)
 }
 
 //NewStoreMapper created a mapper that works with existing store.
@@ -52,23 +37,58 @@ func NewStoreMapper(store cache.Store) *Mapper {
 
 //NewStoreMapperWithOptions created a mapper that works with existing store.
 func NewStoreMapperWithOptions(store cache.Store, options MapOptions) (*Mapper, error) {
-	zapLogger, zapErr := getZapLogger(options.Logging.LogLevel)
-	if zapErr != nil {
-		return nil, zapErr
+	return newMapper(store, options)
+}
+
+//newMapper validates options and creates a Mapper working with given store.
+func newMapper(store cache.Store, options MapOptions) (*Mapper, error) {
+	logger, logErr := newLogger(options.Logging)
+	if logErr != nil {
+		return nil, logErr
 	}
 
+	if options.Workers < 0 {
+		return nil, fmt.Errorf("Cannot instantiate Mapper. Invalid number of workers %d provided", options.Workers)
+	}
+
+	projection, projectionErr := newProjection(options.Projection)
+	if projectionErr != nil {
+		return nil, projectionErr
+	}
+
+	filter, filterErr := newFilter(options.Filter)
+	if filterErr != nil {
+		return nil, filterErr
+	}
+
+	if options.History.Retention < 0 || options.History.MaxEntries < 0 {
+		return nil, fmt.Errorf("Cannot instantiate Mapper. Invalid history retention %s or max entries %d provided", options.History.Retention, options.History.MaxEntries)
+	}
+
+	options.Metrics.observeStore(store)
+
 	return &Mapper{
-		store: store,
-		log: Logger{
-			enabled: options.Logging.Enabled,
-			logger:  zapLogger,
-		},
+		store:      store,
+		queue:      newMapQueue(options.Metrics),
+		workers:    options.Workers,
+		metrics:    options.Metrics,
+		tracer:     newTracer(options.TracerProvider),
+		history:    newHistory(options.History),
+		projection: projection,
+		redaction:  newRedaction(options.Redaction),
+		filter:     filter,
+		log:        logger,
 	}, nil
 }
 
 //StoreMap gets a resources and maps it with exiting resources in store
 func (m *Mapper) StoreMap(obj interface{}) ([]MapResult, error) {
-	mapResults, err := m.kubemapper(obj, m.store)
+	return m.StoreMapContext(context.Background(), obj)
+}
+
+//StoreMapContext is StoreMap which gives up if ctx is done before resource is mapped.
+func (m *Mapper) StoreMapContext(ctx context.Context, obj interface{}) ([]MapResult, error) {
+	mapResults, err := m.kubemapper(ctx, obj, m.store)
 	if err != nil {
 		return []MapResult{}, err
 	}
@@ -78,9 +98,9 @@ func (m *Mapper) StoreMap(obj interface{}) ([]MapResult, error) {
 
 //StoreMapObj gets a resources and maps it with exiting resources in store
 func (m *Mapper) StoreMapObj(obj interface{}) ([]MapResult, error) {
-	mapResults, err := m.kubemapper(obj, m.store)
+	mapResults, err := m.kubemapper(context.Background(), obj, m.store)
 	if err != nil {
-		m.error(fmt.Sprintf("Cannot map resources - %v", err))
+		m.error("Cannot map resources", "error", err)
 		return []MapResult{}, err
 	}
 
@@ -89,67 +109,138 @@ func (m *Mapper) StoreMapObj(obj interface{}) ([]MapResult, error) {
 
 //Map accepts collection different k8s resources.
 //They will be mapped to respective common label and returned
+//With MapOptions.Workers greater than 1, namespaces are mapped in parallel.
 func (m *Mapper) Map(resources KubeResources) (MappedResources, error) {
-	addResourcesForMapping(resources, m.queue)
+	return m.MapContext(context.Background(), resources)
+}
 
-	mappedResources := m.runMap(m.queue, m.store)
+//MapContext is Map which stops mapping once ctx is done.
+//Resources mapped till then are returned along with ctx.Err().
+func (m *Mapper) MapContext(ctx context.Context, resources KubeResources) (MappedResources, error) {
+	if m.workers > 1 {
+		return m.parallelMap(ctx, resources, m.workers)
+	}
+
+	if err := addResourcesForMapping(resources, m.queue); err != nil {
+		return MappedResources{}, err
+	}
 
-	return mappedResources, nil
+	return m.runMap(ctx, m.queue, m.store)
 }
 
 //RunMap starts mapper controller
-func (m *Mapper) runMap(queue workqueue.RateLimitingInterface, store cache.Store) MappedResources {
+func (m *Mapper) runMap(ctx context.Context, queue workqueue.RateLimitingInterface, store cache.Store) (MappedResources, error) {
 	defer utilruntime.HandleCrash()
 	defer queue.ShutDown()
 
-	m.runMapWorker(queue, store)
+	err := m.runMapWorker(ctx, queue, store)
 
-	return getAllMappedResources(store)
+	return getAllMappedResources(store), err
 }
 
-func (m *Mapper) runMapWorker(queue workqueue.RateLimitingInterface, store cache.Store) {
-	for { // Process until there are no messages in queue.
-		if queue.Len() > 0 {
-			m.processNextItemToMap(queue, store)
-		} else {
+//runMapWorker maps items of queue until each of them is mapped or forgotten, or ctx is done.
+//Queue is shut down only then, so items waiting to be retried after a failure are not lost.
+//Error of first item forgotten after too many retries is returned. Others are logged.
+func (m *Mapper) runMapWorker(ctx context.Context, queue workqueue.RateLimitingInterface, store cache.Store) error {
+	pending := queue.Len()
+	if pending == 0 {
+		queue.ShutDown()
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			queue.ShutDown()
+		case <-stop:
+		}
+	}()
+
+	var dropErr error
+	for {
+		if err := ctx.Err(); err != nil {
+			m.warn("Mapping stopped", "pending", pending, "error", err)
+			return err
+		}
+
+		obj, quit := queue.Get()
+		if quit {
 			break
 		}
+
+		isRetried, err := m.processNextItemToMap(ctx, queue, obj, store)
+		if err != nil && dropErr == nil {
+			dropErr = err
+		}
+		if !isRetried {
+			pending--
+			if pending == 0 {
+				queue.ShutDown()
+			}
+		}
 	}
-}
 
-func (m *Mapper) processNextItemToMap(queue workqueue.RateLimitingInterface, store cache.Store) bool {
-	obj, quit := queue.Get()
-	if quit {
-		return false
+	if err := ctx.Err(); err != nil {
+		m.warn("Mapping stopped", "pending", pending, "error", err)
+		return err
 	}
+
+	return dropErr
+}
+
+//processNextItemToMap maps item taken from queue. It returns true when item is put back in queue to be retried,
+//and error of item when it is forgotten after too many retries.
+func (m *Mapper) processNextItemToMap(ctx context.Context, queue workqueue.RateLimitingInterface, obj interface{}, store cache.Store) (bool, error) {
 	defer queue.Done(obj)
-	err := m.processK8sItem(obj, store)
+	err := m.processK8sItem(ctx, obj, store)
 	if err == nil {
 		// No error, reset the ratelimit counters
 		queue.Forget(obj)
+	} else if ctx.Err() != nil {
+		// Mapping is cancelled. Item is not retried.
+		queue.Forget(obj)
+	} else if isPermanentError(err) {
+		// Retrying will not help
+		queue.Forget(obj)
+		utilruntime.HandleError(err)
 	} else if queue.NumRequeues(obj) < maxRetries {
 		queue.AddRateLimited(obj)
+		return true, nil
 	} else {
 		// err != nil and too many retries
 		queue.Forget(obj)
 		utilruntime.HandleError(err)
+		m.metrics.eventDropped(obj)
 
-		m.warn(fmt.Sprintf("\nToo many retries. Forgetting message from queue.\n"))
+		m.warn("Too many retries. Forgetting message from queue", resourceEventLogFields(obj, "error", err)...)
+		return false, err
 	}
 
-	return true
+	return false, nil
 }
 
-func (m *Mapper) processK8sItem(obj interface{}, store cache.Store) error {
-	_, err := m.kubemapper(obj, store)
+func (m *Mapper) processK8sItem(ctx context.Context, obj interface{}, store cache.Store) error {
+	_, err := m.kubemapper(ctx, obj, store)
 	if err != nil {
-		m.error(fmt.Sprintf("\nCannot map resources - %v\n", err))
+		m.error("Cannot map resources", resourceEventLogFields(obj, "error", err)...)
 		return err
 	}
 
 	return nil
 }
 
+//newMapQueue creates workqueue for mapping. With metrics, queue is named so that client-go reports its metrics.
+func newMapQueue(metrics *Metrics) workqueue.RateLimitingInterface {
+	if metrics == nil {
+		return workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	}
+
+	//Provider can be set only once per process. First Metrics passed to a Mapper wins.
+	workqueue.SetProvider(metrics)
+	return workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), workqueueName)
+}
+
 func getAllMappedResources(store cache.Store) MappedResources {
 	var mappedResources MappedResources
 	keys := store.ListKeys()
@@ -162,40 +253,186 @@ func getAllMappedResources(store cache.Store) MappedResources {
 	return mappedResources
 }
 
-func addResourcesForMapping(resources KubeResources, queue workqueue.RateLimitingInterface) {
+func addResourcesForMapping(resources KubeResources, queue workqueue.RateLimitingInterface) error {
+	var events []ResourceEvent
+
 	//Add ingresses
 	for _, ingress := range resources.Ingresses {
-		queue.Add(gerResourceEvent(ingress.DeepCopy(), "ingress"))
+		event, err := gerResourceEvent(ingress.DeepCopy(), "ingress")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
 	}
 
 	//Add services
 	for _, service := range resources.Services {
-		queue.Add(gerResourceEvent(service.DeepCopy(), "service"))
+		event, err := gerResourceEvent(service.DeepCopy(), "service")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
 	}
 
 	//Add deployments
 	for _, deployment := range resources.Deployments {
-		queue.Add(gerResourceEvent(deployment.DeepCopy(), "deployment"))
+		event, err := gerResourceEvent(deployment.DeepCopy(), "deployment")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
 	}
 
 	//Add replica sets
 	for _, replicaSet := range resources.ReplicaSets {
-		queue.Add(gerResourceEvent(replicaSet.DeepCopy(), "replicaset"))
+		event, err := gerResourceEvent(replicaSet.DeepCopy(), "replicaset")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
 	}
 
 	//Add pods
 	for _, pod := range resources.Pods {
-		queue.Add(gerResourceEvent(pod.DeepCopy(), "pod"))
+		event, err := gerResourceEvent(pod.DeepCopy(), "pod")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	//Add config maps, secrets, storage, endpoints, network policies, disruption budgets and RBAC after workloads, so that they are attached to groups using them right away
+	for _, configMap := range resources.ConfigMaps {
+		event, err := gerResourceEvent(configMap.DeepCopy(), "configmap")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, secret := range resources.Secrets {
+		event, err := gerResourceEvent(secret.DeepCopy(), "secret")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
 	}
+
+	for _, claim := range resources.PersistentVolumeClaims {
+		event, err := gerResourceEvent(claim.DeepCopy(), "persistentvolumeclaim")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, volume := range resources.PersistentVolumes {
+		event, err := gerResourceEvent(volume.DeepCopy(), "persistentvolume")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, endpoints := range resources.Endpoints {
+		event, err := gerResourceEvent(endpoints.DeepCopy(), "endpoints")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, endpointSlice := range resources.EndpointSlices {
+		event, err := gerResourceEvent(endpointSlice.DeepCopy(), "endpointslice")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, networkPolicy := range resources.NetworkPolicies {
+		event, err := gerResourceEvent(networkPolicy.DeepCopy(), "networkpolicy")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, budget := range resources.PodDisruptionBudgets {
+		event, err := gerResourceEvent(budget.DeepCopy(), "poddisruptionbudget")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, serviceAccount := range resources.ServiceAccounts {
+		event, err := gerResourceEvent(serviceAccount.DeepCopy(), "serviceaccount")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, roleBinding := range resources.RoleBindings {
+		event, err := gerResourceEvent(roleBinding.DeepCopy(), "rolebinding")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, clusterRoleBinding := range resources.ClusterRoleBindings {
+		event, err := gerResourceEvent(clusterRoleBinding.DeepCopy(), "clusterrolebinding")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, role := range resources.Roles {
+		event, err := gerResourceEvent(role.DeepCopy(), "role")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, clusterRole := range resources.ClusterRoles {
+		event, err := gerResourceEvent(clusterRole.DeepCopy(), "clusterrole")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	for _, node := range resources.Nodes {
+		event, err := gerResourceEvent(node.DeepCopy(), "node")
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+
+	//Queue only after all events are built, so that a bad resource does not leave queue half filled.
+	for _, event := range events {
+		queue.Add(event)
+	}
+
+	return nil
 }
 
-func gerResourceEvent(obj interface{}, resourceType string) ResourceEvent {
+func gerResourceEvent(obj interface{}, resourceType string) (ResourceEvent, error) {
 	var newResourceEvent ResourceEvent
-	var err error
+
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return ResourceEvent{}, fmt.Errorf("%w: Can't get key for %s - %v", ErrInvalidEvent, resourceType, err)
+	}
 
 	objMeta := objectMetaData(obj)
 	newResourceEvent.UID = string(objMeta.UID)
-	newResourceEvent.Key, err = cache.MetaNamespaceKeyFunc(obj)
+	newResourceEvent.Key = key
 	newResourceEvent.EventType = "ADDED"
 	newResourceEvent.ResourceType = resourceType
 	newResourceEvent.Namespace = objMeta.Namespace
@@ -203,9 +440,5 @@ func gerResourceEvent(obj interface{}, resourceType string) ResourceEvent {
 	newResourceEvent.Event = obj
 	//newResourceEvent.RawObj = obj
 
-	if err != nil {
-		log.Fatalf("Can't get key for store")
-	}
-
-	return newResourceEvent
+	return newResourceEvent, nil
 }
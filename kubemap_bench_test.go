@@ -0,0 +1,159 @@
+package kubemap
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestParallelMapMatchesSingleWorker(t *testing.T) {
+	kubeResources := helperGenerateK8sResources(6, 5)
+
+	singleMapper := NewMapper()
+	singleMappedResources, _ := singleMapper.Map(kubeResources)
+
+	parallelMapper, err := NewMapperWithOptions(MapOptions{Workers: 4})
+	assert.Nil(t, err)
+	parallelMappedResources, _ := parallelMapper.Map(kubeResources)
+
+	assert.Len(t, singleMappedResources.MappedResource, 30)
+	assert.ElementsMatch(t, singleMapper.store.ListKeys(), parallelMapper.store.ListKeys())
+	assert.Len(t, parallelMappedResources.MappedResource, len(singleMappedResources.MappedResource))
+}
+
+func TestParallelMapStoreError(t *testing.T) {
+	store := &helperFailingStore{Store: cache.NewStore(metaResourceKeyFunc), failures: -1}
+	parallelMapper, err := NewStoreMapperWithOptions(store, MapOptions{Workers: 4})
+	assert.Nil(t, err)
+
+	_, err = parallelMapper.Map(helperGenerateK8sResources(2, 1))
+	assert.True(t, errors.Is(err, errHelperDiskFull))
+}
+
+func TestMapRetriesStoreError(t *testing.T) {
+	kubeResources := helperGenerateK8sResources(2, 2)
+	singleMapper := NewMapper()
+	_, err := singleMapper.Map(kubeResources)
+	assert.Nil(t, err)
+
+	//Events failing once wait in queue to be retried. Mapping does not stop before they are.
+	for _, workers := range []int{1, 4} {
+		store := &helperFailingStore{Store: cache.NewStore(metaResourceKeyFunc), failures: 3}
+		mapper, err := NewStoreMapperWithOptions(store, MapOptions{Workers: workers})
+		assert.Nil(t, err)
+
+		_, err = mapper.Map(kubeResources)
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, singleMapper.store.ListKeys(), store.ListKeys())
+	}
+}
+
+func TestParallelMapMergesStoredGroups(t *testing.T) {
+	kubeResources := helperGenerateK8sResources(4, 2)
+	singleMapper := NewMapper()
+	_, err := singleMapper.Map(kubeResources)
+	assert.Nil(t, err)
+
+	//Groups mapped before are merged with ones of later resources, not overwritten by them.
+	parallelMapper, err := NewMapperWithOptions(MapOptions{Workers: 4})
+	assert.Nil(t, err)
+	_, err = parallelMapper.Map(KubeResources{Services: kubeResources.Services, Deployments: kubeResources.Deployments})
+	assert.Nil(t, err)
+	_, err = parallelMapper.Map(KubeResources{Ingresses: kubeResources.Ingresses, ReplicaSets: kubeResources.ReplicaSets, Pods: kubeResources.Pods})
+	assert.Nil(t, err)
+
+	assert.ElementsMatch(t, singleMapper.store.ListKeys(), parallelMapper.store.ListKeys())
+}
+
+var errHelperDiskFull = errors.New("disk is full")
+
+//helperFailingStore is a store which cannot add mapped resources given number of times, or ever when it is negative.
+type helperFailingStore struct {
+	cache.Store
+	mu       sync.Mutex
+	failures int
+}
+
+func (s *helperFailingStore) Add(obj interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures != 0 {
+		s.failures--
+		return errHelperDiskFull
+	}
+
+	return s.Store.Add(obj)
+}
+
+func BenchmarkMapSingleWorker(b *testing.B) {
+	helperBenchmarkMap(b, 1)
+}
+
+func BenchmarkMapParallelWorkers(b *testing.B) {
+	helperBenchmarkMap(b, 8)
+}
+
+func helperBenchmarkMap(b *testing.B, workers int) {
+	kubeResources := helperGenerateK8sResources(20, 10)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mapper, _ := NewMapperWithOptions(MapOptions{Workers: workers})
+		mapper.Map(kubeResources)
+	}
+}
+
+//helperGenerateK8sResources builds a synthetic cluster out of test fixtures.
+//Each app has an ingress, service, deployment, replica set and pod linked together.
+func helperGenerateK8sResources(namespaces int, appsPerNamespace int) KubeResources {
+	fixtures := helperGetK8sResources()
+	var kubeResources KubeResources
+
+	for n := 0; n < namespaces; n++ {
+		namespace := fmt.Sprintf("namespace-%d", n)
+
+		for a := 0; a < appsPerNamespace; a++ {
+			app := fmt.Sprintf("app-%d", a)
+			rsName := fmt.Sprintf("%s-644c5c58fc", app)
+			labels := map[string]string{"test": app}
+			podLabels := map[string]string{"test": app, "pod-template-hash": "644c5c58fc"}
+
+			ingress := *fixtures.Ingresses[0].DeepCopy()
+			ingress.Name, ingress.Namespace = app, namespace
+			ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName = app
+			kubeResources.Ingresses = append(kubeResources.Ingresses, ingress)
+
+			service := *fixtures.Services[0].DeepCopy()
+			service.Name, service.Namespace = app, namespace
+			service.Spec.Selector = labels
+			kubeResources.Services = append(kubeResources.Services, service)
+
+			deployment := *fixtures.Deployments[0].DeepCopy()
+			deployment.Name, deployment.Namespace = app, namespace
+			deployment.Spec.Selector = &meta_v1.LabelSelector{MatchLabels: labels}
+			deployment.Spec.Template.Labels = labels
+			kubeResources.Deployments = append(kubeResources.Deployments, deployment)
+
+			replicaSet := *fixtures.ReplicaSets[0].DeepCopy()
+			replicaSet.Name, replicaSet.Namespace = rsName, namespace
+			replicaSet.OwnerReferences[0].Name = app
+			replicaSet.Spec.Selector = &meta_v1.LabelSelector{MatchLabels: podLabels}
+			replicaSet.Spec.Template.Labels = podLabels
+			kubeResources.ReplicaSets = append(kubeResources.ReplicaSets, replicaSet)
+
+			pod := *fixtures.Pods[0].DeepCopy()
+			pod.Name, pod.Namespace = fmt.Sprintf("%s-ggdmn", rsName), namespace
+			pod.Labels = podLabels
+			pod.OwnerReferences[0].Name = rsName
+			kubeResources.Pods = append(kubeResources.Pods, pod)
+		}
+	}
+
+	return kubeResources
+}
@@ -1,16 +1,38 @@
 package kubemap
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr/funcr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	apps_v1beta2 "k8s.io/api/apps/v1beta2"
+	autoscaling_v1 "k8s.io/api/autoscaling/v1"
 	core_v1 "k8s.io/api/core/v1"
 	ext_v1beta1 "k8s.io/api/extensions/v1beta1"
+	networking_v1 "k8s.io/api/networking/v1"
+	policy_v1beta1 "k8s.io/api/policy/v1beta1"
+	rbac_v1 "k8s.io/api/rbac/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
 
@@ -19,7 +41,8 @@ func TestAddResourcesForMapping(t *testing.T) {
 	assert.NotNil(t, kubeResources)
 
 	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-	addResourcesForMapping(kubeResources, queue)
+	err := addResourcesForMapping(kubeResources, queue)
+	assert.Nil(t, err)
 
 	t.Logf("Queue Msg count is - %d\n", queue.Len())
 	assert.NotZero(t, queue.Len())
@@ -51,6 +74,1719 @@ func TestNewMapperWithOptions(t *testing.T) {
 	assert.NotNil(t, mappedResources)
 }
 
+func TestServiceDeleteSplitsMappedResource(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+
+	mapper := NewMapper()
+	mappedResources, _ := mapper.Map(kubeResources)
+	assert.Len(t, mappedResources.MappedResource, 1)
+
+	service := kubeResources.Services[0]
+	mapResults, err := mapper.StoreMap(ResourceEvent{
+		EventType:    "DELETED",
+		ResourceType: "service",
+		Name:         service.Name,
+		Namespace:    service.Namespace,
+	})
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 2)
+	assert.Equal(t, "Updated", mapResults[0].Action)
+	assert.Equal(t, "Added", mapResults[1].Action)
+
+	mappedResources = getAllMappedResources(mapper.store)
+	assert.Len(t, mappedResources.MappedResource, 2)
+	for _, mappedResource := range mappedResources.MappedResource {
+		assert.Empty(t, mappedResource.Kube.Services)
+		if len(mappedResource.Kube.Ingresses) > 0 {
+			assert.Empty(t, mappedResource.Kube.Pods)
+		} else {
+			assert.Len(t, mappedResource.Kube.Deployments, 1)
+			assert.Len(t, mappedResource.Kube.ReplicaSets, 1)
+			assert.Len(t, mappedResource.Kube.Pods, 1)
+		}
+	}
+}
+
+func TestDeploymentDeleteKeepsLinkedMembers(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+
+	mapper := NewMapper()
+	mapper.Map(kubeResources)
+
+	deployment := kubeResources.Deployments[0]
+	mapResults, err := mapper.StoreMap(ResourceEvent{
+		EventType:    "DELETED",
+		ResourceType: "deployment",
+		Name:         deployment.Name,
+		Namespace:    deployment.Namespace,
+	})
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Updated", mapResults[0].Action)
+
+	mappedResources := getAllMappedResources(mapper.store)
+	assert.Len(t, mappedResources.MappedResource, 1)
+	assert.Empty(t, mappedResources.MappedResource[0].Kube.Deployments)
+}
+
+func TestConcurrentStoreMap(t *testing.T) {
+	//Make sure events really run in parallel even on single CPU machines.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	kubeResources := helperGenerateK8sResources(2, 25)
+
+	mapper := NewMapper()
+
+	//Services create a group per app. Rest of the events race to update those groups.
+	for _, service := range kubeResources.Services {
+		_, err := mapper.StoreMap(helperGetResourceEvent(service.DeepCopy(), "service"))
+		assert.Nil(t, err)
+	}
+
+	var events []ResourceEvent
+	for _, ingress := range kubeResources.Ingresses {
+		events = append(events, helperGetResourceEvent(ingress.DeepCopy(), "ingress"))
+	}
+	for _, deployment := range kubeResources.Deployments {
+		events = append(events, helperGetResourceEvent(deployment.DeepCopy(), "deployment"))
+	}
+	for _, replicaSet := range kubeResources.ReplicaSets {
+		events = append(events, helperGetResourceEvent(replicaSet.DeepCopy(), "replicaset"))
+	}
+	for _, pod := range kubeResources.Pods {
+		events = append(events, helperGetResourceEvent(pod.DeepCopy(), "pod"))
+	}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, len(events))
+	for _, event := range events {
+		wg.Add(1)
+		go func(event ResourceEvent) {
+			defer wg.Done()
+			<-start
+			if _, err := mapper.StoreMap(event); err != nil {
+				errs <- err
+			}
+		}(event)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	mappedResources := getAllMappedResources(mapper.store)
+	assert.Len(t, mappedResources.MappedResource, len(kubeResources.Services))
+	for _, mappedResource := range mappedResources.MappedResource {
+		assert.Len(t, mappedResource.Kube.Ingresses, 1, mappedResource.CommonLabel)
+		assert.Len(t, mappedResource.Kube.Services, 1, mappedResource.CommonLabel)
+		assert.Len(t, mappedResource.Kube.Deployments, 1, mappedResource.CommonLabel)
+		assert.Len(t, mappedResource.Kube.ReplicaSets, 1, mappedResource.CommonLabel)
+		assert.Len(t, mappedResource.Kube.Pods, 1, mappedResource.CommonLabel)
+	}
+}
+
+func TestConcurrentNodeAndPodEvents(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	kubeResources := helperGenerateK8sResources(4, 10)
+	nodes := []string{"node-a", "node-b", "node-c"}
+	for i := range kubeResources.Pods {
+		kubeResources.Pods[i].Spec.NodeName = nodes[i%len(nodes)]
+	}
+	for _, node := range nodes {
+		kubeResources.Nodes = append(kubeResources.Nodes, core_v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: node, Labels: map[string]string{"topology.kubernetes.io/zone": "zone-a"}}})
+	}
+
+	mapper := NewMapper()
+	_, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+
+	//Pods move to next node while nodes move to another zone. Node events change groups of every namespace.
+	var events []ResourceEvent
+	for i, pod := range kubeResources.Pods {
+		moved := pod.DeepCopy()
+		moved.Spec.NodeName = nodes[(i+1)%len(nodes)]
+		event := helperGetResourceEvent(moved, "pod")
+		event.EventType = "UPDATED"
+		events = append(events, event)
+	}
+	for _, node := range kubeResources.Nodes {
+		relabeled := node.DeepCopy()
+		relabeled.Labels["topology.kubernetes.io/zone"] = "zone-b"
+		event := helperGetResourceEvent(relabeled, "node")
+		event.EventType = "UPDATED"
+		events = append(events, event)
+	}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, len(events))
+	for _, event := range events {
+		wg.Add(1)
+		go func(event ResourceEvent) {
+			defer wg.Done()
+			<-start
+			if _, err := mapper.StoreMap(event); err != nil {
+				errs <- err
+			}
+		}(event)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	//Groups of apps and standalone groups of nodes
+	mappedResources := getAllMappedResources(mapper.store)
+	assert.Len(t, mappedResources.MappedResource, len(kubeResources.Services)+len(nodes))
+	for _, topology := range Topology(mappedResources) {
+		assert.Equal(t, 1, topology.Pods, topology.CommonLabel)
+		assert.Equal(t, []TopologyDomain{{Name: "zone-b", Pods: 1}}, topology.Zones, topology.CommonLabel)
+	}
+}
+
+func TestMapContextCancelled(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mapper := NewMapper()
+	mappedResources, err := mapper.MapContext(ctx, kubeResources)
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, mappedResources.MappedResource)
+	assert.True(t, mapper.queue.ShuttingDown())
+
+	_, err = mapper.StoreMapContext(ctx, helperGetResourceEvent(kubeResources.Pods[0].DeepCopy(), "pod"))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestStoreMapInvalidEvent(t *testing.T) {
+	mapper := NewMapper()
+
+	_, err := mapper.StoreMap("not an event")
+	assert.True(t, errors.Is(err, ErrInvalidEvent))
+
+	pod := helperGetK8sResources().Pods[0]
+	_, err = mapper.StoreMap(ResourceEvent{
+		EventType:    "ADDED",
+		ResourceType: "service",
+		Name:         pod.Name,
+		Namespace:    pod.Namespace,
+		Event:        pod.DeepCopy(),
+	})
+	assert.True(t, errors.Is(err, ErrInvalidEvent))
+
+	var mapErr *MapError
+	assert.True(t, errors.As(err, &mapErr))
+	assert.Equal(t, "service", mapErr.ResourceType)
+	assert.Equal(t, pod.Name, mapErr.Name)
+
+	_, err = mapper.StoreMap(ResourceEvent{
+		EventType:    "ADDED",
+		ResourceType: "cronjob",
+	})
+	assert.True(t, errors.Is(err, ErrUnsupportedKind))
+
+	//Workloads without selector cannot be linked to their pods.
+	kubeResources := helperGetK8sResources()
+	deployment := kubeResources.Deployments[0].DeepCopy()
+	deployment.Spec.Selector = nil
+	_, err = mapper.StoreMap(helperGetResourceEvent(deployment, "deployment"))
+	assert.True(t, errors.Is(err, ErrInvalidEvent))
+	replicaSet := kubeResources.ReplicaSets[0].DeepCopy()
+	replicaSet.Spec.Selector = nil
+	_, err = mapper.StoreMap(helperGetResourceEvent(replicaSet, "replicaset"))
+	assert.True(t, errors.Is(err, ErrInvalidEvent))
+
+	//Groups holding them, like ones restored from a snapshot, still get a key.
+	_, err = metaResourceKeyFunc(MappedResource{Kube: Kube{Deployments: []apps_v1beta2.Deployment{*deployment}, ReplicaSets: []ext_v1beta1.ReplicaSet{*replicaSet}}})
+	assert.Nil(t, err)
+}
+
+func TestMapperMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	registry := prometheus.NewPedanticRegistry()
+	assert.Nil(t, registry.Register(metrics))
+
+	mapper, err := NewMapperWithOptions(MapOptions{Metrics: metrics})
+	assert.Nil(t, err)
+
+	_, err = mapper.Map(helperGetK8sResources())
+	assert.Nil(t, err)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.eventsProcessed.WithLabelValues("pod", "ADDED", "success")))
+	assert.Zero(t, testutil.ToFloat64(metrics.workqueueRetries.WithLabelValues(workqueueName)))
+
+	values := helperGatherGauges(t, registry)
+	assert.Equal(t, float64(1), values["kubemap_store_groups"])
+	assert.Equal(t, float64(5), values["kubemap_store_objects"])
+	assert.Contains(t, values, "kubemap_workqueue_depth")
+
+	//Store size follows updates of store without reading it on scrape.
+	var snapshot bytes.Buffer
+	assert.Nil(t, mapper.Snapshot(&snapshot))
+	pod := helperGetK8sResources().Pods[0]
+	_, err = mapper.StoreMap(ResourceEvent{Name: pod.Name, Namespace: pod.Namespace, ResourceType: "pod", EventType: "DELETED"})
+	assert.Nil(t, err)
+	values = helperGatherGauges(t, registry)
+	assert.Equal(t, float64(1), values["kubemap_store_groups"])
+	assert.Equal(t, float64(4), values["kubemap_store_objects"])
+
+	assert.Nil(t, mapper.Restore(&snapshot))
+	values = helperGatherGauges(t, registry)
+	assert.Equal(t, float64(1), values["kubemap_store_groups"])
+	assert.Equal(t, float64(5), values["kubemap_store_objects"])
+
+	//Mapped resources already in store are counted once it is observed.
+	storeMetrics := NewMetrics()
+	storeRegistry := prometheus.NewPedanticRegistry()
+	assert.Nil(t, storeRegistry.Register(storeMetrics))
+	_, err = NewStoreMapperWithOptions(mapper.store, MapOptions{Metrics: storeMetrics})
+	assert.Nil(t, err)
+	values = helperGatherGauges(t, storeRegistry)
+	assert.Equal(t, float64(1), values["kubemap_store_groups"])
+	assert.Equal(t, float64(5), values["kubemap_store_objects"])
+}
+
+func TestNewMapperWithLogHandler(t *testing.T) {
+	var logs bytes.Buffer
+	handler := slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})
+
+	mapper, err := NewMapperWithOptions(MapOptions{
+		Logging: LoggingOptions{
+			Handler: handler,
+		},
+	})
+	assert.Nil(t, err)
+
+	mapper.Map(helperGetK8sResources())
+	assert.Contains(t, logs.String(), `"msg":"Processing object","kind":"pod","name":"kube-map-644c5c58fc-ggdmn","namespace":"test-namespace"`)
+
+	var logrLines []string
+	mapper, err = NewMapperWithOptions(MapOptions{
+		Logging: LoggingOptions{
+			Logger: funcr.New(func(prefix, args string) {
+				logrLines = append(logrLines, args)
+			}, funcr.Options{Verbosity: 4}),
+		},
+	})
+	assert.Nil(t, err)
+
+	mapper.Map(helperGetK8sResources())
+	assert.NotEmpty(t, logrLines)
+
+	_, err = NewMapperWithOptions(MapOptions{
+		Logging: LoggingOptions{
+			Enabled:  true,
+			LogLevel: "trace",
+		},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestStoreMapTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	mapper, err := NewMapperWithOptions(MapOptions{TracerProvider: tracerProvider})
+	assert.Nil(t, err)
+
+	ctx, parentSpan := tracerProvider.Tracer("test").Start(context.Background(), "parent")
+	service := helperGetK8sResources().Services[0]
+	_, err = mapper.StoreMapContext(ctx, helperGetResourceEvent(service.DeepCopy(), "service"))
+	assert.Nil(t, err)
+	parentSpan.End()
+
+	spans := exporter.GetSpans()
+	spansByName := map[string]tracetest.SpanStub{}
+	for _, span := range spans {
+		spansByName[span.Name] = span
+	}
+	assert.Len(t, spans, 3)
+
+	mapSpan := spansByName["kubemap.Map"]
+	assert.Equal(t, parentSpan.SpanContext().SpanID(), mapSpan.Parent.SpanID())
+	assert.Contains(t, mapSpan.Attributes, kindAttribute.String("service"))
+	assert.Contains(t, mapSpan.Attributes, nameAttribute.String(service.Name))
+	assert.Contains(t, mapSpan.Attributes, eventTypeAttribute.String("ADDED"))
+	assert.Contains(t, mapSpan.Attributes, groupsAttribute.StringSlice([]string{service.Name}))
+	assert.Contains(t, mapSpan.Attributes, actionsAttribute.StringSlice([]string{"Added"}))
+	assert.Contains(t, mapSpan.Attributes, deletedKeysAttribute.Int(0))
+
+	storeSpan := spansByName["kubemap.updateStore"]
+	assert.Equal(t, mapSpan.SpanContext.SpanID(), storeSpan.Parent.SpanID())
+	assert.Contains(t, storeSpan.Attributes, actionAttribute.String("Added"))
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+
+	mapper := NewMapper()
+	mapper.Map(kubeResources)
+
+	var snapshot bytes.Buffer
+	assert.Nil(t, mapper.Snapshot(&snapshot))
+
+	restoredMapper := NewMapper()
+	assert.Nil(t, restoredMapper.Restore(&snapshot))
+	assert.ElementsMatch(t, mapper.store.ListKeys(), restoredMapper.store.ListKeys())
+
+	//Resync of already mapped resources does not change anything.
+	mapResults, err := restoredMapper.StoreMap(helperGetResourceEvent(kubeResources.Pods[0].DeepCopy(), "pod"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Unchanged", mapResults[0].Action)
+	assert.ElementsMatch(t, mapper.store.ListKeys(), restoredMapper.store.ListKeys())
+
+	err = restoredMapper.Restore(strings.NewReader(`{"version":99}`))
+	assert.NotNil(t, err)
+}
+
+func TestBoltStore(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	storePath := filepath.Join(t.TempDir(), "kubemap.db")
+
+	boltStore, err := NewBoltStore(storePath)
+	assert.Nil(t, err)
+
+	boltMapper := NewStoreMapper(boltStore)
+	for _, service := range kubeResources.Services {
+		_, err := boltMapper.StoreMap(helperGetResourceEvent(service.DeepCopy(), "service"))
+		assert.Nil(t, err)
+	}
+	for _, pod := range kubeResources.Pods {
+		_, err := boltMapper.StoreMap(helperGetResourceEvent(pod.DeepCopy(), "pod"))
+		assert.Nil(t, err)
+	}
+	keys := boltStore.ListKeys()
+	assert.NotEmpty(t, keys)
+	assert.ElementsMatch(t, keys, boltStore.ListNamespaceKeys("test-namespace"))
+	assert.Empty(t, boltStore.ListNamespaceKeys("other-namespace"))
+	assert.Nil(t, boltStore.Close())
+
+	//Mapped resources survive reopening store.
+	boltStore, err = NewBoltStore(storePath)
+	assert.Nil(t, err)
+	defer boltStore.Close()
+	assert.ElementsMatch(t, keys, boltStore.ListKeys())
+
+	boltMapper = NewStoreMapper(boltStore)
+	mapResults, err := boltMapper.StoreMap(helperGetResourceEvent(kubeResources.Pods[0].DeepCopy(), "pod"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Unchanged", mapResults[0].Action)
+
+	mapResults, err = boltMapper.StoreMap(ResourceEvent{
+		Name:         kubeResources.Pods[0].Name,
+		Namespace:    kubeResources.Pods[0].Namespace,
+		ResourceType: "pod",
+		EventType:    "DELETED",
+	})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, mapResults)
+
+	//Groups without namespace are indexed too.
+	node := core_v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: "node-a"}}
+	_, err = boltMapper.StoreMap(helperGetResourceEvent(&node, "node"))
+	assert.Nil(t, err)
+	assert.Len(t, boltStore.ListNamespaceKeys(""), 1)
+
+	assert.Nil(t, boltStore.Replace([]interface{}{}, ""))
+	assert.Empty(t, boltStore.ListKeys())
+	assert.Empty(t, boltStore.ListNamespaceKeys("test-namespace"))
+	assert.Empty(t, boltStore.ListNamespaceKeys(""))
+}
+
+func TestBoltStoreLargeGroup(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	for i := 1; i < 200; i++ {
+		pod := kubeResources.Pods[0].DeepCopy()
+		pod.Name = fmt.Sprintf("%s-%d", pod.Name, i)
+		kubeResources.Pods = append(kubeResources.Pods, *pod)
+	}
+
+	boltStore, err := NewBoltStore(filepath.Join(t.TempDir(), "kubemap.db"))
+	assert.Nil(t, err)
+	defer boltStore.Close()
+
+	//Store key of group grows with each pod, far beyond what bbolt accepts as key.
+	boltMapper := NewStoreMapper(boltStore)
+	_, err = boltMapper.StoreMap(helperGetResourceEvent(kubeResources.Services[0].DeepCopy(), "service"))
+	assert.Nil(t, err)
+	for _, pod := range kubeResources.Pods {
+		_, err := boltMapper.StoreMap(helperGetResourceEvent(pod.DeepCopy(), "pod"))
+		assert.Nil(t, err)
+	}
+
+	mappedResources := getAllMappedResources(boltStore)
+	assert.Len(t, mappedResources.MappedResource, 1)
+	assert.Len(t, mappedResources.MappedResource[0].Kube.Pods, 200)
+	assert.Len(t, boltStore.ListNamespaceKeys("test-namespace"), 1)
+}
+
+func TestBoltStoreDeleteRoundTrip(t *testing.T) {
+	boltStore, err := NewBoltStore(filepath.Join(t.TempDir(), "kubemap.db"))
+	assert.Nil(t, err)
+	defer boltStore.Close()
+
+	//Empty selector is read back from store as nil, which must not change key of group.
+	service := core_v1.Service{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "external-db", Namespace: "test-namespace"},
+		Spec:       core_v1.ServiceSpec{Selector: map[string]string{}},
+	}
+	boltMapper := NewStoreMapper(boltStore)
+	_, err = boltMapper.StoreMap(helperGetResourceEvent(service.DeepCopy(), "service"))
+	assert.Nil(t, err)
+	assert.Len(t, boltStore.ListKeys(), 1)
+
+	_, err = boltMapper.StoreMap(ResourceEvent{
+		Name:         service.Name,
+		Namespace:    service.Namespace,
+		ResourceType: "service",
+		EventType:    "DELETED",
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, boltStore.ListKeys())
+	assert.Empty(t, boltStore.ListNamespaceKeys("test-namespace"))
+}
+
+func TestStoreKeyDecodeError(t *testing.T) {
+	//Store keys not written by kubemap are reported, not read as groups of the empty namespace.
+	foreignStore := cache.NewStore(func(obj interface{}) (string, error) {
+		return "not base64", nil
+	})
+	assert.Nil(t, foreignStore.Add(MappedResource{}))
+	keys, err := getNamespaceKeys("", foreignStore)
+	assert.Empty(t, keys)
+	assert.True(t, errors.Is(err, ErrStoreInconsistent))
+	keys, err = getAllKeys(foreignStore)
+	assert.Empty(t, keys)
+	assert.True(t, errors.Is(err, ErrStoreInconsistent))
+}
+
+func TestHistory(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+
+	mapper, err := NewMapperWithOptions(MapOptions{
+		History: HistoryOptions{Enabled: true, Retention: time.Hour},
+	})
+	assert.Nil(t, err)
+
+	start := time.Now()
+	mappedResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+	mapped := time.Now()
+	assert.Len(t, mappedResources.MappedResource, 1)
+	group := mappedResources.MappedResource[0]
+
+	_, err = mapper.StoreMap(ResourceEvent{
+		Name:         kubeResources.Pods[0].Name,
+		Namespace:    kubeResources.Pods[0].Namespace,
+		ResourceType: "pod",
+		EventType:    "DELETED",
+	})
+	assert.Nil(t, err)
+
+	mappedResource, err := mapper.MappedResourceAt(group.Namespace, group.CommonLabel, mapped)
+	assert.Nil(t, err)
+	assert.Len(t, mappedResource.Kube.Pods, 1)
+
+	mappedResource, err = mapper.MappedResourceAt(group.Namespace, group.CommonLabel, time.Now())
+	assert.Nil(t, err)
+	assert.Empty(t, mappedResource.Kube.Pods)
+
+	_, err = mapper.MappedResourceAt(group.Namespace, group.CommonLabel, start.Add(-time.Second))
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	changes, err := mapper.MembershipChanges(group.Namespace, group.CommonLabel, mapped, time.Now())
+	assert.Nil(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, "pod", changes[0].Kind)
+	assert.Equal(t, kubeResources.Pods[0].Name, changes[0].Name)
+	assert.Equal(t, "Left", changes[0].Change)
+
+	changes, err = mapper.MembershipChanges("", "", start, mapped)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, changes)
+	for _, change := range changes {
+		assert.Equal(t, "Joined", change.Change)
+	}
+
+	_, err = NewMapper().MappedResourceAt(group.Namespace, group.CommonLabel, mapped)
+	assert.True(t, errors.Is(err, ErrHistoryDisabled))
+}
+
+func TestHistoryRetention(t *testing.T) {
+	h := newHistory(HistoryOptions{Enabled: true, MaxEntries: 2})
+	h.record(MapResult{Action: "Added", MappedResource: MappedResource{Namespace: "default", CommonLabel: "a"}}, nil)
+	h.record(MapResult{Action: "Updated", MappedResource: MappedResource{Namespace: "default", CommonLabel: "a"}}, nil)
+	h.record(MapResult{Action: "Added", MappedResource: MappedResource{Namespace: "default", CommonLabel: "b"}}, nil)
+	h.record(MapResult{Action: "Deleted"}, []MappedResource{{Namespace: "default", CommonLabel: "b"}})
+	h.record(MapResult{Action: "Added", MappedResource: MappedResource{Namespace: "default", CommonLabel: "c"}}, nil)
+	h.record(MapResult{Action: "Added", MappedResource: MappedResource{Namespace: "default", CommonLabel: "d"}}, nil)
+
+	//Deleted group is dropped and only last state of other compacted groups is kept.
+	var entries []string
+	for _, entry := range h.groupEntries("default", "") {
+		entries = append(entries, entry.CommonLabel+"/"+entry.Action)
+	}
+	assert.Equal(t, []string{"a/Updated", "c/Added", "d/Added"}, entries)
+}
+
+func TestDiff(t *testing.T) {
+	mapper := NewMapper()
+	oldResources, err := mapper.Map(helperGetK8sResources())
+	assert.Nil(t, err)
+	assert.Len(t, oldResources.MappedResource, 1)
+
+	changed := copyMappedResource(oldResources.MappedResource[0])
+	changed.Kube.Deployments[0].Spec.Template.Spec.Containers[0].Image = "nginx:changed"
+	changed.Kube.Pods = nil
+	added := MappedResource{
+		CommonLabel: "other",
+		Namespace:   changed.Namespace,
+		Kube:        Kube{Services: []core_v1.Service{{ObjectMeta: meta_v1.ObjectMeta{Name: "other"}}}},
+	}
+	newResources := MappedResources{MappedResource: []MappedResource{changed, added}}
+
+	report, err := Diff(oldResources, oldResources, DiffOptions{})
+	assert.Nil(t, err)
+	assert.True(t, report.IsEmpty())
+
+	for _, matchBy := range []string{DiffMatchCommonLabel, DiffMatchStableID} {
+		report, err = Diff(oldResources, newResources, DiffOptions{MatchBy: matchBy})
+		assert.Nil(t, err)
+		assert.Len(t, report.AddedGroups, 1)
+		assert.Empty(t, report.RemovedGroups)
+		assert.Len(t, report.ChangedGroups, 1)
+
+		groupDiff := report.ChangedGroups[0]
+		assert.Equal(t, []DiffMember{{Kind: "pod", Name: oldResources.MappedResource[0].Kube.Pods[0].Name}}, groupDiff.RemovedMembers)
+		assert.Len(t, groupDiff.Changes, 1)
+		assert.Equal(t, "images", groupDiff.Changes[0].Field)
+		assert.Contains(t, groupDiff.Changes[0].New, "nginx:changed")
+	}
+
+	//Groups of other namespace match only when namespace is ignored.
+	renamed := copyMappedResource(oldResources.MappedResource[0])
+	renamed.Namespace = "prod"
+	prodResources := MappedResources{MappedResource: []MappedResource{renamed}}
+	report, err = Diff(oldResources, prodResources, DiffOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, report.AddedGroups, 1)
+	assert.Len(t, report.RemovedGroups, 1)
+	report, err = Diff(oldResources, prodResources, DiffOptions{MatchBy: DiffMatchStableID, IgnoreNamespace: true})
+	assert.Nil(t, err)
+	assert.True(t, report.IsEmpty())
+
+	//Groups sharing an identifier cannot be matched.
+	bothResources := MappedResources{MappedResource: []MappedResource{oldResources.MappedResource[0], renamed}}
+	_, err = Diff(bothResources, prodResources, DiffOptions{IgnoreNamespace: true})
+	assert.EqualError(t, err, "Cannot diff. Old groups test-namespace/kube-map and prod/kube-map are both identified as kube-map")
+	_, err = Diff(bothResources, prodResources, DiffOptions{})
+	assert.Nil(t, err)
+
+	var text bytes.Buffer
+	report, _ = Diff(oldResources, newResources, DiffOptions{})
+	assert.Nil(t, report.WriteText(&text))
+	assert.Contains(t, text.String(), "+ group test-namespace/other")
+
+	_, err = Diff(oldResources, newResources, DiffOptions{MatchBy: "name"})
+	assert.NotNil(t, err)
+}
+
+func TestProjection(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	kubeResources.Pods[0].Annotations = map[string]string{
+		"kubectl.kubernetes.io/last-applied-configuration": "{}",
+		"team": "payments",
+	}
+	kubeResources.Pods[0].Status.ContainerStatuses = []core_v1.ContainerStatus{{Name: "nginx", Ready: true}}
+
+	mapper := NewMapper()
+	fullResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+
+	identityMapper, err := NewMapperWithOptions(MapOptions{
+		Projection: ProjectionOptions{Profile: ProjectionIdentity},
+	})
+	assert.Nil(t, err)
+	identityResources, err := identityMapper.Map(kubeResources)
+	assert.Nil(t, err)
+
+	//Projection does not change how resources are grouped.
+	assert.ElementsMatch(t, mapper.store.ListKeys(), identityMapper.store.ListKeys())
+	pod := identityResources.MappedResource[0].Kube.Pods[0]
+	assert.Equal(t, kubeResources.Pods[0].Name, pod.Name)
+	assert.Equal(t, kubeResources.Pods[0].Labels, pod.Labels)
+	assert.Equal(t, kubeResources.Pods[0].Spec.Containers[0].Ports, pod.Spec.Containers[0].Ports)
+	assert.Empty(t, pod.Spec.Containers[0].Image)
+	assert.Empty(t, pod.Annotations)
+	assert.Equal(t, fullResources.MappedResource[0].Kube.Services[0].Spec.Selector, identityResources.MappedResource[0].Kube.Services[0].Spec.Selector)
+
+	//Resync of projected resources does not change anything.
+	mapResults, err := identityMapper.StoreMap(helperGetResourceEvent(kubeResources.Pods[0].DeepCopy(), "pod"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Unchanged", mapResults[0].Action)
+
+	summaryMapper, err := NewMapperWithOptions(MapOptions{
+		Projection: ProjectionOptions{
+			Profile: ProjectionSummary,
+			Include: map[string][]string{"service": {"metadata.name"}},
+			Exclude: map[string][]string{"pod": {"metadata.labels", "spec.containers.image"}},
+		},
+	})
+	assert.Nil(t, err)
+	summaryResources, err := summaryMapper.Map(kubeResources)
+	assert.Nil(t, err)
+
+	pod = summaryResources.MappedResource[0].Kube.Pods[0]
+	assert.Equal(t, map[string]string{"team": "payments"}, pod.Annotations)
+	assert.Empty(t, pod.Status.ContainerStatuses)
+	assert.Equal(t, kubeResources.Pods[0].Labels, pod.Labels)
+	assert.Equal(t, kubeResources.Pods[0].Spec.Containers[0].Name, pod.Spec.Containers[0].Name)
+	assert.Empty(t, pod.Spec.Containers[0].Image)
+	assert.Empty(t, summaryResources.MappedResource[0].Kube.Services[0].Spec.SessionAffinity)
+
+	_, err = NewMapperWithOptions(MapOptions{Projection: ProjectionOptions{Profile: "identity-only"}})
+	assert.Nil(t, err)
+	_, err = NewMapperWithOptions(MapOptions{Projection: ProjectionOptions{Profile: "minimal"}})
+	assert.EqualError(t, err, "Cannot instantiate Mapper. Invalid projection profile minimal provided. Accepted values are 'full', 'summary' & 'identity-only'")
+	_, err = NewMapperWithOptions(MapOptions{Projection: ProjectionOptions{Exclude: map[string][]string{"pods": {"spec"}}}})
+	assert.NotNil(t, err)
+	_, err = NewMapperWithOptions(MapOptions{Projection: ProjectionOptions{Exclude: map[string][]string{"pod": {"metadata.annotations[team"}}}})
+	assert.NotNil(t, err)
+}
+
+func TestRedaction(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	kubeResources.Pods[0].Spec.Containers[0].Env = []core_v1.EnvVar{
+		{Name: "PASSWORD", Value: "hunter2"},
+		{Name: "TOKEN", ValueFrom: &core_v1.EnvVarSource{SecretKeyRef: &core_v1.SecretKeySelector{Key: "token"}}},
+	}
+	kubeResources.Services[0].Annotations = map[string]string{"example.com/credentials": "secret", "team": "payments"}
+
+	mapper, err := NewMapperWithOptions(MapOptions{
+		Redaction: RedactionOptions{EnvValues: true, Annotations: []string{"example.com/credentials"}},
+	})
+	assert.Nil(t, err)
+	mappedResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+
+	pod := mappedResources.MappedResource[0].Kube.Pods[0]
+	assert.Equal(t, RedactedValue, pod.Spec.Containers[0].Env[0].Value)
+	assert.NotNil(t, pod.Spec.Containers[0].Env[1].ValueFrom)
+	assert.Equal(t, "spec.containers[kube-map].env[PASSWORD]", pod.Annotations[RedactedAnnotation])
+
+	service := mappedResources.MappedResource[0].Kube.Services[0]
+	assert.Equal(t, RedactedValue, service.Annotations["example.com/credentials"])
+	assert.Equal(t, "payments", service.Annotations["team"])
+	assert.Equal(t, "metadata.annotations[example.com/credentials]", service.Annotations[RedactedAnnotation])
+
+	//Objects passed by caller are not modified.
+	assert.Equal(t, "hunter2", kubeResources.Pods[0].Spec.Containers[0].Env[0].Value)
+
+	//Resync of redacted resources does not change anything.
+	mapResults, err := mapper.StoreMap(helperGetResourceEvent(kubeResources.Pods[0].DeepCopy(), "pod"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Unchanged", mapResults[0].Action)
+
+	secret := core_v1.Secret{
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+		StringData: map[string]string{"token": "abc"},
+	}
+	redaction{}.redactSecret(&secret)
+	assert.Equal(t, []byte(RedactedValue), secret.Data["password"])
+	assert.Equal(t, RedactedValue, secret.StringData["token"])
+	assert.Equal(t, "data[password],stringData[token]", secret.Annotations[RedactedAnnotation])
+
+	secret.Data["password"] = []byte("hunter2")
+	newRedaction(RedactionOptions{KeepSecretData: true}).redactSecret(&secret)
+	assert.Equal(t, []byte("hunter2"), secret.Data["password"])
+}
+
+func TestFilter(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	kubeResources.Services[0].Labels = map[string]string{"kubemap.io/ignore": "true"}
+
+	mapper, err := NewMapperWithOptions(MapOptions{
+		Filter: FilterOptions{
+			ExcludeNamespaces: []string{"kube-*"},
+			LabelSelector:     "kubemap.io/ignore!=true",
+			Kinds:             []string{"service", "deployment", "pod"},
+		},
+	})
+	assert.Nil(t, err)
+
+	mapResults, err := mapper.StoreMap(helperGetResourceEvent(kubeResources.Services[0].DeepCopy(), "service"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Rejected", mapResults[0].Action)
+	assert.Contains(t, mapResults[0].RejectReason, "kubemap.io/ignore!=true")
+
+	mapResults, err = mapper.StoreMap(helperGetResourceEvent(kubeResources.Ingresses[0].DeepCopy(), "ingress"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Rejected", mapResults[0].Action)
+	assert.Equal(t, "Kind ingress is not enabled", mapResults[0].RejectReason)
+
+	systemPod := kubeResources.Pods[0].DeepCopy()
+	systemPod.Namespace = "kube-system"
+	mapResults, err = mapper.StoreMap(helperGetResourceEvent(systemPod, "pod"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Rejected", mapResults[0].Action)
+	assert.Equal(t, "Namespace kube-system is excluded by pattern kube-*", mapResults[0].RejectReason)
+
+	mapResults, err = mapper.StoreMap(helperGetResourceEvent(kubeResources.Pods[0].DeepCopy(), "pod"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Added", mapResults[0].Action)
+	assert.Empty(t, mapResults[0].RejectReason)
+
+	//Pod relabeled out of selector is removed.
+	ignoredPod := kubeResources.Pods[0].DeepCopy()
+	ignoredPod.Labels["kubemap.io/ignore"] = "true"
+	event := helperGetResourceEvent(ignoredPod, "pod")
+	event.EventType = "UPDATED"
+	mapResults, err = mapper.StoreMap(event)
+	assert.Nil(t, err)
+	assert.Equal(t, "Deleted", mapResults[0].Action)
+	assert.Empty(t, getAllMappedResources(mapper.store).MappedResource)
+
+	//Filters apply to Map too.
+	mappedResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+	for _, mappedResource := range mappedResources.MappedResource {
+		assert.Empty(t, mappedResource.Kube.Services)
+		assert.Empty(t, mappedResource.Kube.Ingresses)
+	}
+
+	includeMapper, err := NewMapperWithOptions(MapOptions{Filter: FilterOptions{IncludeNamespaces: []string{"team-*"}}})
+	assert.Nil(t, err)
+	mapResults, err = includeMapper.StoreMap(helperGetResourceEvent(kubeResources.Pods[0].DeepCopy(), "pod"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Namespace test-namespace is not included", mapResults[0].RejectReason)
+
+	//Cluster scoped objects are filtered by namespace of groups using them.
+	includeMapper, err = NewMapperWithOptions(MapOptions{Filter: FilterOptions{IncludeNamespaces: []string{"test-*"}}})
+	assert.Nil(t, err)
+	kubeResources.Pods[0].Spec.NodeName = "node-a"
+	kubeResources.Nodes = []core_v1.Node{{ObjectMeta: meta_v1.ObjectMeta{Name: "node-a"}}}
+	kubeResources.PersistentVolumes = []core_v1.PersistentVolume{{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "system-volume"},
+		Spec:       core_v1.PersistentVolumeSpec{ClaimRef: &core_v1.ObjectReference{Namespace: "kube-system", Name: "data"}},
+	}}
+	mappedResources, err = includeMapper.Map(kubeResources)
+	assert.Nil(t, err)
+	for _, mappedResource := range mappedResources.MappedResource {
+		assert.Empty(t, mappedResource.Kube.PersistentVolumes)
+		if mappedResource.CommonLabel == "kube-map" {
+			assert.Len(t, mappedResource.Kube.Nodes, 1)
+		}
+	}
+
+	_, err = NewMapperWithOptions(MapOptions{Filter: FilterOptions{ExcludeNamespaces: []string{"kube-["}}})
+	assert.NotNil(t, err)
+	_, err = NewMapperWithOptions(MapOptions{Filter: FilterOptions{LabelSelector: "a in (b"}})
+	assert.NotNil(t, err)
+	_, err = NewMapperWithOptions(MapOptions{Filter: FilterOptions{Kinds: []string{"pods"}}})
+	assert.NotNil(t, err)
+}
+
+func TestConfigMapsAndSecrets(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	namespace := kubeResources.Pods[0].Namespace
+
+	configMap := core_v1.ConfigMap{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "app-config", Namespace: namespace},
+		Data:       map[string]string{"level": "debug"},
+	}
+	secret := core_v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "tls-cert", Namespace: namespace},
+		Data:       map[string][]byte{"tls.key": []byte("private")},
+	}
+	kubeResources.ConfigMaps = append(kubeResources.ConfigMaps, configMap)
+	kubeResources.Secrets = append(kubeResources.Secrets, secret)
+
+	kubeResources.Ingresses[0].Spec.TLS = []ext_v1beta1.IngressTLS{{SecretName: "tls-cert"}}
+	configMapVolume := core_v1.Volume{
+		Name:         "config",
+		VolumeSource: core_v1.VolumeSource{ConfigMap: &core_v1.ConfigMapVolumeSource{LocalObjectReference: core_v1.LocalObjectReference{Name: "app-config"}}},
+	}
+	kubeResources.Pods[0].Spec.Volumes = append(kubeResources.Pods[0].Spec.Volumes, configMapVolume)
+
+	//Another group using same config map
+	otherPod := kubeResources.Pods[0].DeepCopy()
+	otherPod.Name = "other"
+	otherPod.Labels = map[string]string{"app": "other"}
+	otherPod.OwnerReferences = nil
+	kubeResources.Pods = append(kubeResources.Pods, *otherPod)
+
+	mapper := NewMapper()
+	mappedResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+
+	//Shared config map does not link groups together.
+	assert.Len(t, mappedResources.MappedResource, 2)
+	for _, mappedResource := range mappedResources.MappedResource {
+		assert.Len(t, mappedResource.Kube.ConfigMaps, 1)
+		assert.Equal(t, "app-config", mappedResource.Kube.ConfigMaps[0].Name)
+
+		if len(mappedResource.Kube.Ingresses) > 0 {
+			assert.Len(t, mappedResource.Kube.Secrets, 1)
+			assert.Equal(t, []byte(RedactedValue), mappedResource.Kube.Secrets[0].Data["tls.key"])
+		} else {
+			assert.Empty(t, mappedResource.Kube.Secrets)
+		}
+	}
+
+	//Unreferenced config map is kept standalone.
+	unusedConfigMap := configMap.DeepCopy()
+	unusedConfigMap.Name = "unused"
+	mapResults, err := mapper.StoreMap(helperGetResourceEvent(unusedConfigMap, "configmap"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Added", mapResults[0].Action)
+	assert.Equal(t, "configmap-unused", mapResults[0].CommonLabel)
+
+	//Update of shared config map updates every group using it.
+	updatedConfigMap := configMap.DeepCopy()
+	updatedConfigMap.Data["level"] = "info"
+	mapResults, err = mapper.StoreMap(helperGetResourceEvent(updatedConfigMap, "configmap"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 2)
+	for _, mapResult := range mapResults {
+		assert.Equal(t, "Updated", mapResult.Action)
+		assert.Equal(t, "info", mapResult.MappedResource.Kube.ConfigMaps[0].Data["level"])
+	}
+
+	//Deleted config map is removed from groups.
+	mapResults, err = mapper.StoreMap(ResourceEvent{EventType: "DELETED", ResourceType: "configmap", Name: "app-config", Namespace: namespace})
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 2)
+	for _, mapResult := range mapResults {
+		assert.Empty(t, mapResult.MappedResource.Kube.ConfigMaps)
+	}
+
+	mapResults, err = mapper.StoreMap(ResourceEvent{EventType: "DELETED", ResourceType: "configmap", Name: "unused", Namespace: namespace})
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Deleted", mapResults[0].Action)
+
+	//Secret of deleted ingress is kept standalone.
+	mapResults, err = mapper.StoreMap(ResourceEvent{EventType: "DELETED", ResourceType: "ingress", Name: kubeResources.Ingresses[0].Name, Namespace: namespace})
+	assert.Nil(t, err)
+	var standalone []string
+	for _, mapResult := range mapResults {
+		if mapResult.Action == "Added" {
+			standalone = append(standalone, mapResult.CommonLabel)
+		}
+	}
+	assert.Equal(t, []string{"secret-tls-cert"}, standalone)
+}
+
+func TestPersistentVolumes(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	namespace := kubeResources.Pods[0].Namespace
+	storageClass := "fast"
+
+	kubeResources.Pods[0].Spec.Volumes = append(kubeResources.Pods[0].Spec.Volumes, core_v1.Volume{
+		Name:         "data",
+		VolumeSource: core_v1.VolumeSource{PersistentVolumeClaim: &core_v1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}},
+	})
+	kubeResources.PersistentVolumeClaims = []core_v1.PersistentVolumeClaim{{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "data", Namespace: namespace},
+		Spec:       core_v1.PersistentVolumeClaimSpec{VolumeName: "pv-data", StorageClassName: &storageClass},
+	}}
+	kubeResources.PersistentVolumes = []core_v1.PersistentVolume{{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "pv-data"},
+		Spec: core_v1.PersistentVolumeSpec{
+			ClaimRef:         &core_v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: namespace, Name: "data"},
+			StorageClassName: storageClass,
+		},
+	}}
+
+	mapper := NewMapper()
+	mappedResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+	assert.Len(t, mappedResources.MappedResource, 1)
+	assert.Equal(t, []VolumeClaim{{Name: "data", VolumeName: "pv-data", StorageClassName: "fast", IsVolumeMapped: true}}, VolumeClaims(mappedResources.MappedResource[0]))
+
+	//Parallel mapping puts volume in partition of its claim.
+	parallelMapper, err := NewMapperWithOptions(MapOptions{Workers: 4})
+	assert.Nil(t, err)
+	parallelResources, err := parallelMapper.Map(kubeResources)
+	assert.Nil(t, err)
+	assert.Len(t, parallelResources.MappedResource, 1)
+	assert.Len(t, parallelResources.MappedResource[0].Kube.PersistentVolumes, 1)
+
+	//Pending claim nobody uses is standalone. Its volume joins it once bound.
+	pendingClaim := core_v1.PersistentVolumeClaim{ObjectMeta: meta_v1.ObjectMeta{Name: "scratch", Namespace: namespace}}
+	mapResults, err := mapper.StoreMap(helperGetResourceEvent(pendingClaim.DeepCopy(), "persistentvolumeclaim"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "persistentvolumeclaim-scratch", mapResults[0].CommonLabel)
+
+	volume := core_v1.PersistentVolume{ObjectMeta: meta_v1.ObjectMeta{Name: "pv-scratch"}}
+	mapResults, err = mapper.StoreMap(helperGetResourceEvent(volume.DeepCopy(), "persistentvolume"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Added", mapResults[0].Action)
+	assert.Equal(t, "", mapResults[0].MappedResource.Namespace)
+
+	boundClaim := pendingClaim.DeepCopy()
+	boundClaim.Spec.VolumeName = "pv-scratch"
+	mapResults, err = mapper.StoreMap(helperGetResourceEvent(boundClaim, "persistentvolumeclaim"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Updated", mapResults[0].Action)
+	assert.Empty(t, VolumeClaims(mapResults[0].MappedResource)[0].StorageClassName)
+	assert.False(t, VolumeClaims(mapResults[0].MappedResource)[0].IsVolumeMapped)
+
+	boundVolume := volume.DeepCopy()
+	boundVolume.Spec.ClaimRef = &core_v1.ObjectReference{Namespace: namespace, Name: "scratch"}
+	boundVolume.Spec.StorageClassName = "slow"
+	mapResults, err = mapper.StoreMap(helperGetResourceEvent(boundVolume, "persistentvolume"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 2)
+	assert.Equal(t, "Updated", mapResults[0].Action)
+	assert.Equal(t, []VolumeClaim{{Name: "scratch", VolumeName: "pv-scratch", StorageClassName: "slow", IsVolumeMapped: true}}, VolumeClaims(mapResults[0].MappedResource))
+	assert.Equal(t, "Deleted", mapResults[1].Action)
+	assert.Equal(t, "persistentvolume-pv-scratch", mapResults[1].CommonLabel)
+
+	//Deleted claim leaves its volume standalone in claim's namespace.
+	mapResults, err = mapper.StoreMap(ResourceEvent{EventType: "DELETED", ResourceType: "persistentvolumeclaim", Name: "data", Namespace: namespace})
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 2)
+	assert.Empty(t, mapResults[0].MappedResource.Kube.PersistentVolumeClaims)
+	assert.Empty(t, mapResults[0].MappedResource.Kube.PersistentVolumes)
+	assert.Equal(t, "Added", mapResults[1].Action)
+	assert.Equal(t, "persistentvolume-pv-data", mapResults[1].CommonLabel)
+	assert.Equal(t, namespace, mapResults[1].MappedResource.Namespace)
+
+	//Deleted volume event has no namespace.
+	mapResults, err = mapper.StoreMap(ResourceEvent{EventType: "DELETED", ResourceType: "persistentvolume", Name: "pv-data"})
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Deleted", mapResults[0].Action)
+}
+
+func TestEndpoints(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	namespace := kubeResources.Services[0].Namespace
+	service := kubeResources.Services[0].Name
+	pod := kubeResources.Pods[0].Name
+
+	kubeResources.Endpoints = []core_v1.Endpoints{{
+		ObjectMeta: meta_v1.ObjectMeta{Name: service, Namespace: namespace},
+		Subsets: []core_v1.EndpointSubset{{
+			Addresses:         []core_v1.EndpointAddress{{IP: "10.0.0.1", TargetRef: &core_v1.ObjectReference{Kind: "Pod", Name: pod}}},
+			NotReadyAddresses: []core_v1.EndpointAddress{{IP: "10.0.0.2", TargetRef: &core_v1.ObjectReference{Kind: "Pod", Name: "starting"}}},
+		}},
+	}}
+
+	mapper := NewMapper()
+	mappedResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+	assert.Len(t, mappedResources.MappedResource, 1)
+	mappedResource := mappedResources.MappedResource[0]
+	assert.Len(t, mappedResource.Kube.Endpoints, 1)
+	assert.Equal(t, []ServiceEndpoint{
+		{Service: service, Pod: pod, Ready: true, IsPodMapped: true},
+		{Service: service, Pod: "starting", Ready: false},
+	}, ServiceEndpoints(mappedResource))
+	assert.Equal(t, "true", mappedResource.Kube.Pods[0].Annotations[ReadyAnnotationPrefix+service])
+
+	//Endpoint slices are accepted as unstructured objects too. Pod reported not ready by any of them is not ready.
+	endpointSlice := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":  "discovery.k8s.io/v1",
+		"kind":        "EndpointSlice",
+		"metadata":    map[string]interface{}{"name": service + "-abcde", "namespace": namespace, "labels": map[string]interface{}{"kubernetes.io/service-name": service}},
+		"addressType": "IPv4",
+		"endpoints": []interface{}{map[string]interface{}{
+			"addresses":  []interface{}{"10.0.0.1"},
+			"conditions": map[string]interface{}{"ready": false},
+			"targetRef":  map[string]interface{}{"kind": "Pod", "name": pod},
+		}},
+	}}
+	mapResults, err := mapper.StoreMap(ResourceEvent{EventType: "ADDED", ResourceType: "endpointslice", Name: endpointSlice.GetName(), Namespace: namespace, Event: endpointSlice})
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Updated", mapResults[0].Action)
+	assert.Len(t, mapResults[0].MappedResource.Kube.EndpointSlices, 1)
+	assert.Equal(t, "false", mapResults[0].MappedResource.Kube.Pods[0].Annotations[ReadyAnnotationPrefix+service])
+
+	//Readiness annotation is kept on pod updates.
+	mapResults, err = mapper.StoreMap(helperGetResourceEvent(kubeResources.Pods[0].DeepCopy(), "pod"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Unchanged", mapResults[0].Action)
+
+	//Readiness annotations are removed along with endpoints.
+	for _, resourceType := range []string{"endpoints", "endpointslice"} {
+		name := service
+		if resourceType == "endpointslice" {
+			name = endpointSlice.GetName()
+		}
+		mapResults, err = mapper.StoreMap(ResourceEvent{EventType: "DELETED", ResourceType: resourceType, Name: name, Namespace: namespace})
+		assert.Nil(t, err)
+		assert.Len(t, mapResults, 1)
+	}
+	assert.Empty(t, ServiceEndpoints(mapResults[0].MappedResource))
+	assert.NotContains(t, mapResults[0].MappedResource.Kube.Pods[0].Annotations, ReadyAnnotationPrefix+service)
+
+	//Projection does not drop readiness annotations.
+	identityMapper, err := NewMapperWithOptions(MapOptions{Projection: ProjectionOptions{Profile: ProjectionIdentity}})
+	assert.Nil(t, err)
+	mappedResources, err = identityMapper.Map(kubeResources)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{ReadyAnnotationPrefix + service: "true"}, mappedResources.MappedResource[0].Kube.Pods[0].Annotations)
+}
+
+func TestEndpointPodsOutsideSelector(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	namespace := kubeResources.Services[0].Namespace
+	service := kubeResources.Services[0].Name
+
+	//Pod whose labels diverge from selector of service, still listed in its endpoints while it starts.
+	canary := core_v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Name: "canary", Namespace: namespace, Labels: map[string]string{"app": "canary"}}}
+	endpoints := core_v1.Endpoints{
+		ObjectMeta: meta_v1.ObjectMeta{Name: service, Namespace: namespace},
+		Subsets: []core_v1.EndpointSubset{{
+			Addresses:         []core_v1.EndpointAddress{{IP: "10.0.0.1", TargetRef: &core_v1.ObjectReference{Kind: "Pod", Name: kubeResources.Pods[0].Name}}},
+			NotReadyAddresses: []core_v1.EndpointAddress{{IP: "10.0.0.2", TargetRef: &core_v1.ObjectReference{Kind: "Pod", Name: canary.Name}}},
+		}},
+	}
+
+	assertLinked := func(mapper *Mapper) {
+		mappedResources := getAllMappedResources(mapper.store)
+		assert.Len(t, mappedResources.MappedResource, 1)
+		mappedResource := mappedResources.MappedResource[0]
+		assert.Equal(t, service, mappedResource.CommonLabel)
+		assert.Len(t, mappedResource.Kube.Pods, 2)
+		assert.Len(t, mappedResource.Kube.Endpoints, 1)
+		for _, endpoint := range ServiceEndpoints(mappedResource) {
+			assert.True(t, endpoint.IsPodMapped)
+		}
+	}
+
+	//Pod mapped before endpoints joins group of service once endpoints are mapped.
+	kubeResources.Pods = append(kubeResources.Pods, canary)
+	kubeResources.Endpoints = []core_v1.Endpoints{endpoints}
+	mapper := NewMapper()
+	_, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+	assertLinked(mapper)
+
+	//Pod mapped after endpoints joins group of service right away.
+	mapper = NewMapper()
+	for _, event := range []ResourceEvent{
+		helperGetResourceEvent(kubeResources.Services[0].DeepCopy(), "service"),
+		helperGetResourceEvent(kubeResources.Pods[0].DeepCopy(), "pod"),
+		helperGetResourceEvent(endpoints.DeepCopy(), "endpoints"),
+		helperGetResourceEvent(canary.DeepCopy(), "pod"),
+	} {
+		_, err := mapper.StoreMap(event)
+		assert.Nil(t, err)
+	}
+	assertLinked(mapper)
+
+	//Pod stays with service when it is updated while endpoints reference it.
+	_, err = mapper.StoreMap(helperGetResourceEvent(canary.DeepCopy(), "pod"))
+	assert.Nil(t, err)
+	assertLinked(mapper)
+}
+
+func TestSelectorlessServices(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	namespace := kubeResources.Services[0].Namespace
+	pod := kubeResources.Pods[0].Name
+
+	//Service without selector backed by a pod through manually managed endpoints, and by an address outside of cluster.
+	kubeResources.Services = append(kubeResources.Services, core_v1.Service{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "legacy", Namespace: namespace},
+	})
+	kubeResources.Endpoints = []core_v1.Endpoints{{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "legacy", Namespace: namespace},
+		Subsets: []core_v1.EndpointSubset{{
+			Addresses: []core_v1.EndpointAddress{
+				{IP: "10.0.0.1", TargetRef: &core_v1.ObjectReference{Kind: "Pod", Name: pod}},
+				{IP: "192.168.1.10"},
+			},
+		}},
+	}}
+
+	//ExternalName service routed to by an ingress.
+	kubeResources.Services = append(kubeResources.Services, core_v1.Service{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "payments-api", Namespace: namespace},
+		Spec:       core_v1.ServiceSpec{Type: core_v1.ServiceTypeExternalName, ExternalName: "api.payments.example.com"},
+	})
+	kubeResources.Ingresses = append(kubeResources.Ingresses, ext_v1beta1.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "payments", Namespace: namespace},
+		Spec: ext_v1beta1.IngressSpec{Rules: []ext_v1beta1.IngressRule{{
+			IngressRuleValue: ext_v1beta1.IngressRuleValue{HTTP: &ext_v1beta1.HTTPIngressRuleValue{
+				Paths: []ext_v1beta1.HTTPIngressPath{{Backend: ext_v1beta1.IngressBackend{ServiceName: "payments-api"}}},
+			}},
+		}}},
+	})
+
+	mapper := NewMapper()
+	mappedResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+	assert.Len(t, mappedResources.MappedResource, 2)
+
+	for _, mappedResource := range mappedResources.MappedResource {
+		var services []string
+		for _, service := range mappedResource.Kube.Services {
+			services = append(services, service.Name)
+		}
+
+		if mappedResource.CommonLabel == "payments-api" {
+			assert.Equal(t, []string{"payments-api"}, services)
+			assert.Len(t, mappedResource.Kube.Ingresses, 1)
+			assert.Equal(t, []ExternalDependency{{Service: "payments-api", Type: ExternalDependencyDNS, Target: "api.payments.example.com"}}, mappedResource.ExternalDependencies)
+			continue
+		}
+
+		//Service without selector follows pod of its endpoints.
+		assert.ElementsMatch(t, []string{kubeResources.Services[0].Name, "legacy"}, services)
+		assert.Len(t, mappedResource.Kube.Endpoints, 1)
+		assert.Equal(t, []ExternalDependency{{Service: "legacy", Type: ExternalDependencyAddress, Target: "192.168.1.10"}}, mappedResource.ExternalDependencies)
+	}
+
+	//Update of service without selector stays in its group.
+	legacy := kubeResources.Services[1].DeepCopy()
+	legacy.Labels = map[string]string{"tier": "legacy"}
+	mapResults, err := mapper.StoreMap(helperGetResourceEvent(legacy, "service"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Updated", mapResults[0].Action)
+	assert.Len(t, mapResults[0].MappedResource.Kube.Pods, 1)
+	assert.Len(t, mapper.store.ListKeys(), 2)
+
+	//Deleting sibling service leaves service without selector with pod of its endpoints. Only ingress of deleted service is split off.
+	_, err = mapper.StoreMap(ResourceEvent{Name: kubeResources.Services[0].Name, Namespace: namespace, ResourceType: "service", EventType: "DELETED"})
+	assert.Nil(t, err)
+	assert.Len(t, mapper.store.ListKeys(), 3)
+	for _, mappedResource := range getAllMappedResources(mapper.store).MappedResource {
+		if len(mappedResource.Kube.Pods) > 0 {
+			assert.Len(t, mappedResource.Kube.Services, 1)
+			assert.Equal(t, "legacy", mappedResource.Kube.Services[0].Name)
+			assert.Len(t, mappedResource.Kube.Endpoints, 1)
+		}
+	}
+
+	//Projection keeps external name, so that dependencies are found again on updates of group.
+	identityMapper, err := NewMapperWithOptions(MapOptions{Projection: ProjectionOptions{Profile: ProjectionIdentity}})
+	assert.Nil(t, err)
+	_, err = identityMapper.Map(kubeResources)
+	assert.Nil(t, err)
+	payments := kubeResources.Ingresses[1].DeepCopy()
+	payments.Labels = map[string]string{"team": "payments"}
+	mapResults, err = identityMapper.StoreMap(helperGetResourceEvent(payments, "ingress"))
+	assert.Nil(t, err)
+	assert.Equal(t, []ExternalDependency{{Service: "payments-api", Type: ExternalDependencyDNS, Target: "api.payments.example.com"}}, mapResults[0].MappedResource.ExternalDependencies)
+}
+
+func TestDiagnostics(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	namespace := kubeResources.Services[0].Namespace
+
+	mapper := NewMapper()
+	mappedResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+	assert.Empty(t, mappedResources.MappedResource[0].Diagnostics)
+
+	ingress := kubeResources.Ingresses[0].DeepCopy()
+	ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServicePort = intstr.FromInt(80)
+	ingress.Spec.Backend = &ext_v1beta1.IngressBackend{ServiceName: "missing", ServicePort: intstr.FromInt(80)}
+	mapResults, err := mapper.StoreMap(helperGetResourceEvent(ingress, "ingress"))
+	assert.Nil(t, err)
+	assert.Equal(t, []Diagnostic{
+		{Type: DiagnosticUnknownServicePort, Kind: "ingress", Name: ingress.Name, Message: "Port 80 of service kube-map used by ingress kube-map does not exist"},
+		{Type: DiagnosticMissingService, Kind: "ingress", Name: ingress.Name, Message: "Service missing of ingress kube-map does not exist"},
+	}, mapResults[0].MappedResource.Diagnostics)
+
+	service := kubeResources.Services[0].DeepCopy()
+	service.Spec.Ports[0].TargetPort = intstr.FromString("http")
+	mapResults, err = mapper.StoreMap(helperGetResourceEvent(service, "service"))
+	assert.Nil(t, err)
+	assert.Contains(t, mapResults[0].MappedResource.Diagnostics, Diagnostic{
+		Type:    DiagnosticUnknownTargetPort,
+		Kind:    "service",
+		Name:    service.Name,
+		Message: "Target port http of service kube-map port 8085 is not a container port of its pods",
+	})
+
+	//Service which exists in another group is not missing.
+	otherService := core_v1.Service{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "missing", Namespace: namespace},
+		Spec:       core_v1.ServiceSpec{Selector: map[string]string{"app": "other"}, Ports: []core_v1.ServicePort{{Port: 80}}},
+	}
+	assert.Empty(t, diagnose(MappedResource{Kube: Kube{Ingresses: []ext_v1beta1.Ingress{*ingress}}}, map[string]bool{"missing": true, "kube-map": true}))
+	assert.Empty(t, diagnose(MappedResource{Kube: Kube{Services: []core_v1.Service{otherService}}}, nil))
+
+	//Projection keeps ports diagnostics need.
+	identityMapper, err := NewMapperWithOptions(MapOptions{Projection: ProjectionOptions{Profile: ProjectionIdentity}})
+	assert.Nil(t, err)
+	mappedResources, err = identityMapper.Map(kubeResources)
+	assert.Nil(t, err)
+	assert.Empty(t, mappedResources.MappedResource[0].Diagnostics)
+
+	ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServicePort = intstr.FromInt(80)
+	mapResults, err = identityMapper.StoreMap(helperGetResourceEvent(ingress, "ingress"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults[0].MappedResource.Diagnostics, 2)
+}
+
+func TestNetworkPolicies(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	namespace := kubeResources.Pods[0].Namespace
+	port := intstr.FromInt(8085)
+
+	client := core_v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Name: "client", Namespace: namespace, Labels: map[string]string{"role": "client"}}}
+	kubeResources.Pods = append(kubeResources.Pods, client)
+	kubeResources.NetworkPolicies = []networking_v1.NetworkPolicy{
+		{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "allow-client", Namespace: namespace},
+			Spec: networking_v1.NetworkPolicySpec{
+				PodSelector: meta_v1.LabelSelector{MatchLabels: map[string]string{"test": "map"}},
+				Ingress: []networking_v1.NetworkPolicyIngressRule{{
+					From:  []networking_v1.NetworkPolicyPeer{{PodSelector: &meta_v1.LabelSelector{MatchLabels: map[string]string{"role": "client"}}}},
+					Ports: []networking_v1.NetworkPolicyPort{{Port: &port}},
+				}},
+			},
+		},
+		{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "client-egress", Namespace: namespace},
+			Spec: networking_v1.NetworkPolicySpec{
+				PodSelector: meta_v1.LabelSelector{MatchLabels: map[string]string{"role": "client"}},
+				PolicyTypes: []networking_v1.PolicyType{networking_v1.PolicyTypeEgress},
+				Egress: []networking_v1.NetworkPolicyEgressRule{{
+					To: []networking_v1.NetworkPolicyPeer{
+						{NamespaceSelector: &meta_v1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}}},
+						{IPBlock: &networking_v1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}},
+					},
+				}},
+			},
+		},
+		{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "unused", Namespace: namespace},
+			Spec:       networking_v1.NetworkPolicySpec{PodSelector: meta_v1.LabelSelector{MatchLabels: map[string]string{"role": "none"}}},
+		},
+	}
+
+	mapper := NewMapper()
+	mappedResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+
+	groupOf := func(mappedResources MappedResources, pod string) MappedResource {
+		for _, mappedResource := range mappedResources.MappedResource {
+			for _, mappedPod := range mappedResource.Kube.Pods {
+				if mappedPod.Name == pod {
+					return mappedResource
+				}
+			}
+		}
+		return MappedResource{}
+	}
+	commonLabels := func(mappedResources MappedResources) []string {
+		var labels []string
+		for _, mappedResource := range mappedResources.MappedResource {
+			labels = append(labels, mappedResource.CommonLabel)
+		}
+		return labels
+	}
+	policyNames := func(mappedResource MappedResource) []string {
+		var names []string
+		for _, networkPolicy := range mappedResource.Kube.NetworkPolicies {
+			names = append(names, networkPolicy.Name)
+		}
+		return names
+	}
+
+	serverGroup := groupOf(mappedResources, kubeResources.Pods[0].Name)
+	clientGroup := groupOf(mappedResources, "client")
+	assert.Equal(t, []string{"allow-client"}, policyNames(serverGroup))
+	assert.Equal(t, []string{"client-egress"}, policyNames(clientGroup))
+	assert.Contains(t, commonLabels(mappedResources), "networkpolicy-unused")
+
+	graph := Reachability(mappedResources, ReachabilityOptions{NamespaceLabels: map[string]map[string]string{"platform": {"team": "platform"}}})
+	server, ok := graph.Group(namespace, serverGroup.CommonLabel)
+	assert.True(t, ok)
+	assert.True(t, server.IsIngressIsolated)
+	assert.False(t, server.IsEgressIsolated)
+	assert.Equal(t, []ReachabilityPeer{
+		{Type: ReachabilityPeerGroup, Namespace: namespace, CommonLabel: clientGroup.CommonLabel, Ports: []string{"TCP/8085"}, Policy: "allow-client"},
+	}, server.Ingress)
+
+	clientReachability, ok := graph.Group(namespace, clientGroup.CommonLabel)
+	assert.True(t, ok)
+	assert.False(t, clientReachability.IsIngressIsolated)
+	assert.Equal(t, []ReachabilityPeer{
+		{Type: ReachabilityPeerNamespace, Namespace: "platform", Policy: "client-egress"},
+		{Type: ReachabilityPeerCIDR, CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}, Policy: "client-egress"},
+	}, clientReachability.Egress)
+
+	//Egress of client only allows platform namespace and CIDR, ingress of server allows client.
+	assert.False(t, graph.CanReach(namespace, clientGroup.CommonLabel, namespace, serverGroup.CommonLabel))
+	assert.True(t, graph.CanReach(namespace, serverGroup.CommonLabel, namespace, clientGroup.CommonLabel))
+
+	//Policy which stops selecting client becomes standalone, and client may reach server once server allows it.
+	egress := kubeResources.NetworkPolicies[1].DeepCopy()
+	egress.Spec.PodSelector = meta_v1.LabelSelector{MatchLabels: map[string]string{"role": "none"}}
+	_, err = mapper.StoreMap(helperGetResourceEvent(egress, "networkpolicy"))
+	assert.Nil(t, err)
+
+	mappedResources = getAllMappedResources(mapper.store)
+	assert.Empty(t, groupOf(mappedResources, "client").Kube.NetworkPolicies)
+	assert.Contains(t, commonLabels(mappedResources), "networkpolicy-client-egress")
+	assert.True(t, mapper.Reachability(ReachabilityOptions{}).CanReach(namespace, clientGroup.CommonLabel, namespace, serverGroup.CommonLabel))
+
+	ingress := kubeResources.NetworkPolicies[0].DeepCopy()
+	ingress.Spec.Ingress[0].From[0].PodSelector.MatchLabels = map[string]string{"role": "other"}
+	_, err = mapper.StoreMap(helperGetResourceEvent(ingress, "networkpolicy"))
+	assert.Nil(t, err)
+	assert.False(t, mapper.Reachability(ReachabilityOptions{}).CanReach(namespace, clientGroup.CommonLabel, namespace, serverGroup.CommonLabel))
+}
+
+func TestDisruptionBudgets(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	namespace := kubeResources.Pods[0].Namespace
+	ready := []core_v1.PodCondition{{Type: core_v1.PodReady, Status: core_v1.ConditionTrue}}
+
+	kubeResources.Pods[0].Spec.NodeName = "node-a"
+	kubeResources.Pods[0].Status.Conditions = ready
+	second := kubeResources.Pods[0].DeepCopy()
+	second.Name = "kube-map-second"
+	second.Spec.NodeName = "node-b"
+	kubeResources.Pods = append(kubeResources.Pods, *second)
+
+	minAvailable := intstr.FromInt(1)
+	budget := policy_v1beta1.PodDisruptionBudget{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "kube-map", Namespace: namespace},
+		Spec: policy_v1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector:     &meta_v1.LabelSelector{MatchLabels: map[string]string{"test": "map"}},
+		},
+	}
+	unused := policy_v1beta1.PodDisruptionBudget{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "unused", Namespace: namespace},
+		Spec:       policy_v1beta1.PodDisruptionBudgetSpec{Selector: &meta_v1.LabelSelector{}},
+	}
+	kubeResources.PodDisruptionBudgets = []policy_v1beta1.PodDisruptionBudget{budget, unused}
+
+	mapper := NewMapper()
+	mappedResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+	assert.Len(t, mappedResources.MappedResource, 2)
+
+	groups := DisruptionBudgets(mappedResources)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, []DisruptionBudget{
+		{Name: "kube-map", AllowedDisruptions: 1, CurrentHealthy: 2, DesiredHealthy: 1, ExpectedPods: 2, IsComputed: true},
+	}, groups[0].Budgets)
+
+	report := CheckDrain(mappedResources, "node-a")
+	assert.True(t, report.IsSafe())
+	assert.Equal(t, []string{namespace + "/" + kubeResources.Pods[0].Name}, report.Pods)
+
+	//Second pod is not ready, so draining first one leaves budget short.
+	notReady := second.DeepCopy()
+	notReady.Status.Conditions = []core_v1.PodCondition{{Type: core_v1.PodReady, Status: core_v1.ConditionFalse}}
+	_, err = mapper.StoreMap(helperGetResourceEvent(notReady, "pod"))
+	assert.Nil(t, err)
+
+	report = mapper.CheckDrain("node-a")
+	assert.False(t, report.IsSafe())
+	assert.Equal(t, []DrainViolation{
+		{Namespace: namespace, Budget: "kube-map", AllowedDisruptions: 0, Pods: []string{kubeResources.Pods[0].Name}},
+	}, report.Violations)
+	assert.True(t, mapper.CheckDrain("node-b").IsSafe())
+
+	//Observed status of budget is used as is.
+	observed := budget.DeepCopy()
+	observed.Generation = 1
+	observed.Status = policy_v1beta1.PodDisruptionBudgetStatus{ObservedGeneration: 1, PodDisruptionsAllowed: 1, CurrentHealthy: 2, DesiredHealthy: 1, ExpectedPods: 2}
+	_, err = mapper.StoreMap(helperGetResourceEvent(observed, "poddisruptionbudget"))
+	assert.Nil(t, err)
+	assert.Equal(t, DisruptionBudget{Name: "kube-map", AllowedDisruptions: 1, CurrentHealthy: 2, DesiredHealthy: 1, ExpectedPods: 2}, mapper.DisruptionBudgets()[0].Budgets[0])
+	assert.True(t, mapper.CheckDrain("node-a").IsSafe())
+
+	maxUnavailable := intstr.FromString("50%")
+	percentBudget := budget.DeepCopy()
+	percentBudget.Spec.MinAvailable = nil
+	percentBudget.Spec.MaxUnavailable = &maxUnavailable
+	assert.Equal(t, int32(1), evaluateBudget(*percentBudget, []core_v1.Pod{kubeResources.Pods[0], kubeResources.Pods[0]}).AllowedDisruptions)
+}
+
+func TestServiceAccountsAndRBAC(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	namespace := kubeResources.Pods[0].Namespace
+
+	kubeResources.Deployments[0].Spec.Template.Spec.ServiceAccountName = "kube-map"
+	kubeResources.ReplicaSets[0].Spec.Template.Spec.ServiceAccountName = "kube-map"
+	kubeResources.Pods[0].Spec.ServiceAccountName = "kube-map"
+	kubeResources.ServiceAccounts = []core_v1.ServiceAccount{
+		{ObjectMeta: meta_v1.ObjectMeta{Name: "kube-map", Namespace: namespace}},
+		{ObjectMeta: meta_v1.ObjectMeta{Name: "unused", Namespace: namespace}},
+	}
+	kubeResources.Roles = []rbac_v1.Role{{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "reader", Namespace: namespace},
+		Rules: []rbac_v1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods", "configmaps"}, Verbs: []string{"list", "get"}},
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"watch"}},
+		},
+	}}
+	kubeResources.RoleBindings = []rbac_v1.RoleBinding{{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "kube-map-reader", Namespace: namespace},
+		Subjects:   []rbac_v1.Subject{{Kind: rbac_v1.ServiceAccountKind, Name: "kube-map"}},
+		RoleRef:    rbac_v1.RoleRef{APIGroup: rbac_v1.GroupName, Kind: "Role", Name: "reader"},
+	}}
+	kubeResources.ClusterRoles = []rbac_v1.ClusterRole{
+		{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "node-viewer"},
+			Rules: []rbac_v1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"nodes"}, ResourceNames: []string{"node-a"}, Verbs: []string{"get"}},
+				{NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}},
+			},
+		},
+		{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "cluster-admin"},
+			Rules:      []rbac_v1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		},
+	}
+	kubeResources.ClusterRoleBindings = []rbac_v1.ClusterRoleBinding{{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "kube-map-nodes"},
+		Subjects:   []rbac_v1.Subject{{Kind: rbac_v1.UserKind, Name: "system:serviceaccount:" + namespace + ":kube-map"}},
+		RoleRef:    rbac_v1.RoleRef{APIGroup: rbac_v1.GroupName, Kind: "ClusterRole", Name: "node-viewer"},
+	}}
+
+	permissions := []Permission{
+		{ServiceAccount: "kube-map", NonResourceURL: "/healthz", Verbs: []string{"get"}},
+		{ServiceAccount: "kube-map", Resource: "nodes", ResourceName: "node-a", Verbs: []string{"get"}},
+		{ServiceAccount: "kube-map", Namespace: namespace, Resource: "configmaps", Verbs: []string{"get", "list"}},
+		{ServiceAccount: "kube-map", Namespace: namespace, Resource: "pods", Verbs: []string{"get", "list", "watch"}},
+	}
+
+	groupOf := func(mappedResources MappedResources, commonLabel string) MappedResource {
+		for _, mappedResource := range mappedResources.MappedResource {
+			if mappedResource.CommonLabel == commonLabel {
+				return mappedResource
+			}
+		}
+		return MappedResource{}
+	}
+
+	for _, workers := range []int{1, 4} {
+		mapper, err := NewMapperWithOptions(MapOptions{Workers: workers})
+		assert.Nil(t, err)
+		mappedResources, err := mapper.Map(kubeResources)
+		assert.Nil(t, err)
+
+		group := groupOf(mappedResources, "kube-map")
+		assert.Len(t, group.Kube.ServiceAccounts, 1)
+		assert.Len(t, group.Kube.RoleBindings, 1)
+		assert.Len(t, group.Kube.Roles, 1)
+		assert.Len(t, group.Kube.ClusterRoleBindings, 1)
+		assert.Len(t, group.Kube.ClusterRoles, 1)
+		assert.Equal(t, permissions, Permissions(group))
+
+		//Cluster wide objects keep a standalone group, whether a group uses them or not.
+		assert.Equal(t, namespace, groupOf(mappedResources, "serviceaccount-unused").Namespace)
+		assert.Equal(t, "", groupOf(mappedResources, "clusterrole-cluster-admin").Namespace)
+		assert.Len(t, groupOf(mappedResources, "clusterrole-node-viewer").Kube.ClusterRoles, 1)
+	}
+
+	mapper := NewMapper()
+	_, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+
+	//Binding cluster-admin instead grants every verb on every resource.
+	binding := kubeResources.ClusterRoleBindings[0].DeepCopy()
+	binding.RoleRef.Name = "cluster-admin"
+	_, err = mapper.StoreMap(helperGetResourceEvent(binding, "clusterrolebinding"))
+	assert.Nil(t, err)
+	group := groupOf(getAllMappedResources(mapper.store), "kube-map")
+	assert.Equal(t, "cluster-admin", group.Kube.ClusterRoles[0].Name)
+	assert.Contains(t, Permissions(group), Permission{ServiceAccount: "kube-map", APIGroup: "*", Resource: "*", Verbs: []string{"*"}})
+
+	//Deleted role binding takes its role along.
+	_, err = mapper.StoreMap(ResourceEvent{EventType: "DELETED", ResourceType: "rolebinding", Name: "kube-map-reader", Namespace: namespace})
+	assert.Nil(t, err)
+	group = groupOf(getAllMappedResources(mapper.store), "kube-map")
+	assert.Empty(t, group.Kube.RoleBindings)
+	assert.Empty(t, group.Kube.Roles)
+	assert.Equal(t, "reader", groupOf(getAllMappedResources(mapper.store), "role-reader").Kube.Roles[0].Name)
+}
+
+func TestNodeTopology(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	namespace := kubeResources.Pods[0].Namespace
+
+	kubeResources.Pods[0].Spec.NodeName = "node-a"
+	second := kubeResources.Pods[0].DeepCopy()
+	second.Name = "kube-map-second"
+	second.Spec.NodeName = "node-b"
+	pending := kubeResources.Pods[0].DeepCopy()
+	pending.Name = "kube-map-pending"
+	pending.Spec.NodeName = ""
+	kubeResources.Pods = append(kubeResources.Pods, *second, *pending)
+	kubeResources.Nodes = []core_v1.Node{
+		{ObjectMeta: meta_v1.ObjectMeta{Name: "node-a", Labels: map[string]string{"topology.kubernetes.io/zone": "zone-a", "topology.kubernetes.io/region": "region"}}},
+		{ObjectMeta: meta_v1.ObjectMeta{Name: "node-b", Labels: map[string]string{core_v1.LabelZoneFailureDomain: "zone-a", core_v1.LabelZoneRegion: "region"}}},
+		{ObjectMeta: meta_v1.ObjectMeta{Name: "node-c", Labels: map[string]string{"topology.kubernetes.io/zone": "zone-c", "topology.kubernetes.io/region": "region"}}},
+	}
+
+	for _, workers := range []int{1, 4} {
+		mapper, err := NewMapperWithOptions(MapOptions{Workers: workers})
+		assert.Nil(t, err)
+		mappedResources, err := mapper.Map(kubeResources)
+		assert.Nil(t, err)
+
+		//Every node keeps a standalone group, and groups get nodes their pods run on.
+		assert.Len(t, mappedResources.MappedResource, 4)
+		topologies := Topology(mappedResources)
+		assert.Equal(t, []GroupTopology{{
+			Namespace:       namespace,
+			CommonLabel:     "kube-map",
+			Pods:            2,
+			UnscheduledPods: 1,
+			Nodes:           []TopologyDomain{{Name: "node-a", Pods: 1}, {Name: "node-b", Pods: 1}},
+			Zones:           []TopologyDomain{{Name: "zone-a", Pods: 2}},
+			Regions:         []TopologyDomain{{Name: "region", Pods: 2}},
+			IsSingleZone:    true,
+		}}, topologies)
+	}
+
+	mapper := NewMapper()
+	_, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+
+	//Moving second pod to node of first one puts all replicas on one node.
+	moved := second.DeepCopy()
+	moved.Spec.NodeName = "node-a"
+	_, err = mapper.StoreMap(helperGetResourceEvent(moved, "pod"))
+	assert.Nil(t, err)
+	topology := mapper.Topology()[0]
+	assert.True(t, topology.IsSingleNode)
+	assert.Equal(t, []TopologyDomain{{Name: "node-a", Pods: 2}}, topology.Nodes)
+
+	//Spreading it to another zone clears both flags.
+	moved.Spec.NodeName = "node-c"
+	_, err = mapper.StoreMap(helperGetResourceEvent(moved, "pod"))
+	assert.Nil(t, err)
+	topology = mapper.Topology()[0]
+	assert.False(t, topology.IsSingleNode)
+	assert.False(t, topology.IsSingleZone)
+	assert.Equal(t, []TopologyDomain{{Name: "zone-a", Pods: 1}, {Name: "zone-c", Pods: 1}}, topology.Zones)
+
+	//Relabeled node is updated in groups running on it.
+	relabeled := kubeResources.Nodes[2].DeepCopy()
+	relabeled.Labels["topology.kubernetes.io/zone"] = "zone-a"
+	_, err = mapper.StoreMap(helperGetResourceEvent(relabeled, "node"))
+	assert.Nil(t, err)
+	assert.True(t, mapper.Topology()[0].IsSingleZone)
+
+	//Heartbeats leave topology labels alone, so they change no group. Groups keep only topology labels of nodes.
+	keys := mapper.store.ListKeys()
+	heartbeat := relabeled.DeepCopy()
+	heartbeat.Labels["team"] = "platform"
+	heartbeat.Status.Conditions = []core_v1.NodeCondition{{Type: core_v1.NodeReady, Status: core_v1.ConditionTrue, LastHeartbeatTime: meta_v1.Now()}}
+	mapResults, err := mapper.StoreMap(helperGetResourceEvent(heartbeat, "node"))
+	assert.Nil(t, err)
+	assert.Len(t, mapResults, 1)
+	assert.Equal(t, "Unchanged", mapResults[0].Action)
+	assert.ElementsMatch(t, keys, mapper.store.ListKeys())
+	for _, mappedResource := range getAllMappedResources(mapper.store).MappedResource {
+		for _, node := range mappedResource.Kube.Nodes {
+			assert.Empty(t, node.Status)
+			assert.NotContains(t, node.Labels, "team")
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	kubeResources := helperGetK8sResources()
+	namespace := kubeResources.Services[0].Namespace
+
+	mapper := NewMapper()
+	mappedResources, err := mapper.Map(kubeResources)
+	assert.Nil(t, err)
+	report, err := Lint(mappedResources, LintOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, report.Findings)
+
+	_, err = Lint(mappedResources, LintOptions{MinSeverity: "fatal"})
+	assert.NotNil(t, err)
+
+	duplicate := kubeResources.Services[0].DeepCopy()
+	duplicate.Name = "kube-map-duplicate"
+	deployment := kubeResources.Deployments[0].DeepCopy()
+	deployment.Name = "mismatch"
+	deployment.Spec.Template.Labels = map[string]string{"app": "other"}
+	replicaSet := kubeResources.ReplicaSets[0].DeepCopy()
+	replicaSet.Name = "orphan"
+	replicaSet.OwnerReferences = nil
+	pod := kubeResources.Pods[0].DeepCopy()
+	pod.Name = "unmanaged"
+	pod.OwnerReferences = nil
+	lonely := core_v1.Service{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "lonely", Namespace: namespace},
+		Spec:       core_v1.ServiceSpec{Selector: map[string]string{"app": "lonely"}},
+	}
+	mappedResources.MappedResource = append(mappedResources.MappedResource, MappedResource{
+		CommonLabel: "lint",
+		Namespace:   namespace,
+		Kube: Kube{
+			Services:    []core_v1.Service{*duplicate, lonely},
+			Deployments: []apps_v1beta2.Deployment{*deployment},
+			ReplicaSets: []ext_v1beta1.ReplicaSet{*replicaSet},
+			Pods:        []core_v1.Pod{*pod},
+		},
+	})
+	autoscaler := autoscaling_v1.HorizontalPodAutoscaler{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "scaler", Namespace: namespace},
+		Spec: autoscaling_v1.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscaling_v1.CrossVersionObjectReference{Kind: "Deployment", Name: "missing"},
+		},
+	}
+
+	report, err = Lint(mappedResources, LintOptions{HorizontalPodAutoscalers: []autoscaling_v1.HorizontalPodAutoscaler{autoscaler}})
+	assert.Nil(t, err)
+	var checks []string
+	for _, finding := range report.Findings {
+		checks = append(checks, finding.Check)
+	}
+	assert.Equal(t, []string{
+		LintDeploymentSelectorMismatch,
+		LintMissingScaleTarget,
+		LintDuplicateServiceSelector,
+		LintOrphanReplicaSet,
+		LintServiceWithoutPods,
+		LintUnmanagedPod,
+	}, checks)
+	assert.Equal(t, []LintObjectReference{
+		{Kind: "horizontalpodautoscaler", Namespace: namespace, Name: "scaler"},
+		{Kind: "deployment", Namespace: namespace, Name: "missing"},
+	}, report.Findings[1].Objects)
+	assert.Len(t, report.Findings[2].Objects, 2)
+	assert.True(t, report.HasSeverity(LintSeverityError))
+
+	report, err = Lint(mappedResources, LintOptions{MinSeverity: LintSeverityError})
+	assert.Nil(t, err)
+	assert.Len(t, report.Findings, 1)
+
+	var b strings.Builder
+	assert.Nil(t, report.WriteText(&b))
+	assert.Equal(t, "[error] deployment-selector-mismatch: Template labels app=other of deployment mismatch do not match its selector test=map (deployment "+namespace+"/mismatch)\n", b.String())
+}
+
 func helperGetK8sResources() KubeResources {
 	var kubeResources KubeResources
 
@@ -87,6 +1823,15 @@ func helperGetK8sResources() KubeResources {
 	return kubeResources
 }
 
+func helperGetResourceEvent(obj interface{}, resourceType string) ResourceEvent {
+	event, err := gerResourceEvent(obj, resourceType)
+	if err != nil {
+		panic(err)
+	}
+
+	return event
+}
+
 func helperGetFileContent(fileName string) []byte {
 	path := filepath.Join("testdata", "test-fixtures", fileName)
 
@@ -97,3 +1842,17 @@ func helperGetFileContent(fileName string) []byte {
 
 	return content
 }
+
+func helperGatherGauges(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+
+	values := map[string]float64{}
+	for _, family := range families {
+		if family.GetMetric()[0].GetGauge() != nil {
+			values[family.GetName()] = family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,341 @@
+package kubemap
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	apps_v1beta2 "k8s.io/api/apps/v1beta2"
+	autoscaling_v1 "k8s.io/api/autoscaling/v1"
+	core_v1 "k8s.io/api/core/v1"
+	ext_v1beta1 "k8s.io/api/extensions/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+//Severities of LintFinding, from least to most severe.
+const (
+	LintSeverityInfo    = "info"
+	LintSeverityWarning = "warning"
+	LintSeverityError   = "error"
+)
+
+//Checks of LintFinding
+const (
+	//LintServiceWithoutPods is a service whose selector matches no pods.
+	LintServiceWithoutPods = "service-without-pods"
+	//LintDeploymentSelectorMismatch is a deployment whose template labels do not satisfy its own selector.
+	LintDeploymentSelectorMismatch = "deployment-selector-mismatch"
+	//LintDuplicateServiceSelector is a set of services with identical selectors.
+	LintDuplicateServiceSelector = "duplicate-service-selector"
+	//LintOrphanReplicaSet is a replica set with no owner.
+	LintOrphanReplicaSet = "orphan-replicaset"
+	//LintUnmanagedPod is a pod with no controller.
+	LintUnmanagedPod = "unmanaged-pod"
+	//LintMissingScaleTarget is a horizontal pod autoscaler targeting a missing deployment or replica set.
+	LintMissingScaleTarget = "missing-scale-target"
+)
+
+var lintSeverities = []string{LintSeverityInfo, LintSeverityWarning, LintSeverityError}
+
+//LintOptions controls Lint.
+type LintOptions struct {
+	//HorizontalPodAutoscalers are checked against mapped workloads. They are not mapped, so they are passed here.
+	HorizontalPodAutoscalers []autoscaling_v1.HorizontalPodAutoscaler
+	//MinSeverity drops findings less severe than it. Empty keeps all findings.
+	MinSeverity string
+}
+
+//LintObjectReference is a k8s object involved in a finding.
+type LintObjectReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+//LintFinding is a misconfiguration found by Lint.
+type LintFinding struct {
+	Check    string                `json:"check"`
+	Severity string                `json:"severity"`
+	Message  string                `json:"message"`
+	Objects  []LintObjectReference `json:"objects"`
+}
+
+//LintReport is set of findings ordered from most to least severe.
+type LintReport struct {
+	Findings []LintFinding `json:"findings,omitempty"`
+}
+
+//HasSeverity returns true when report has a finding as severe as given severity or more.
+func (r LintReport) HasSeverity(severity string) bool {
+	for _, finding := range r.Findings {
+		if lintSeverityRank(finding.Severity) >= lintSeverityRank(severity) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//WriteText writes human-readable form of report to w.
+func (r LintReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	if len(r.Findings) == 0 {
+		b.WriteString("No findings\n")
+	}
+	for _, finding := range r.Findings {
+		var objects []string
+		for _, object := range finding.Objects {
+			objects = append(objects, fmt.Sprintf("%s %s/%s", object.Kind, object.Namespace, object.Name))
+		}
+		fmt.Fprintf(&b, "[%s] %s: %s (%s)\n", finding.Severity, finding.Check, finding.Message, strings.Join(objects, ", "))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+//Lint reports misconfigurations across groups of mapped resources.
+func Lint(mappedResources MappedResources, options LintOptions) (LintReport, error) {
+	if options.MinSeverity != "" && lintSeverityRank(options.MinSeverity) < 0 {
+		return LintReport{}, fmt.Errorf("Cannot lint. Invalid severity %s provided. Accepted values are '%s'", options.MinSeverity, strings.Join(lintSeverities, "', '"))
+	}
+
+	objects := lintObjectsOf(mappedResources)
+
+	var findings []LintFinding
+	findings = append(findings, lintServices(objects)...)
+	findings = append(findings, lintDeployments(objects)...)
+	findings = append(findings, lintOwners(objects)...)
+	findings = append(findings, lintScaleTargets(objects, options.HorizontalPodAutoscalers)...)
+
+	var report LintReport
+	for _, finding := range findings {
+		if lintSeverityRank(finding.Severity) >= lintSeverityRank(options.MinSeverity) {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		if report.Findings[i].Severity != report.Findings[j].Severity {
+			return lintSeverityRank(report.Findings[i].Severity) > lintSeverityRank(report.Findings[j].Severity)
+		}
+		if report.Findings[i].Check != report.Findings[j].Check {
+			return report.Findings[i].Check < report.Findings[j].Check
+		}
+		return report.Findings[i].Message < report.Findings[j].Message
+	})
+
+	return report, nil
+}
+
+//Lint reports misconfigurations across groups of mapped resources in store.
+func (m *Mapper) Lint(options LintOptions) (LintReport, error) {
+	return Lint(getAllMappedResources(m.store), options)
+}
+
+//lintSeverityRank returns rank of severity, or -1 when it is unknown. Empty severity ranks as info.
+func lintSeverityRank(severity string) int {
+	if severity == "" {
+		return 0
+	}
+	for i, lintSeverity := range lintSeverities {
+		if severity == lintSeverity {
+			return i
+		}
+	}
+
+	return -1
+}
+
+//lintObjects are objects of all groups. Objects present in several groups, like ingresses, are listed once.
+type lintObjects struct {
+	services    []core_v1.Service
+	deployments []apps_v1beta2.Deployment
+	replicaSets []ext_v1beta1.ReplicaSet
+	pods        []core_v1.Pod
+}
+
+func lintObjectsOf(mappedResources MappedResources) lintObjects {
+	var objects lintObjects
+	seen := map[string]bool{}
+	isNew := func(kind string, objectMeta meta_v1.ObjectMeta) bool {
+		key := kind + "/" + objectMeta.Namespace + "/" + objectMeta.Name
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+		return true
+	}
+
+	for _, mappedResource := range mappedResources.MappedResource {
+		for _, service := range mappedResource.Kube.Services {
+			if isNew("service", service.ObjectMeta) {
+				objects.services = append(objects.services, service)
+			}
+		}
+		for _, deployment := range mappedResource.Kube.Deployments {
+			if isNew("deployment", deployment.ObjectMeta) {
+				objects.deployments = append(objects.deployments, deployment)
+			}
+		}
+		for _, replicaSet := range mappedResource.Kube.ReplicaSets {
+			if isNew("replicaset", replicaSet.ObjectMeta) {
+				objects.replicaSets = append(objects.replicaSets, replicaSet)
+			}
+		}
+		for _, pod := range mappedResource.Kube.Pods {
+			if isNew("pod", pod.ObjectMeta) {
+				objects.pods = append(objects.pods, pod)
+			}
+		}
+	}
+
+	return objects
+}
+
+func lintReference(kind string, objectMeta meta_v1.ObjectMeta) LintObjectReference {
+	return LintObjectReference{Kind: kind, Namespace: objectMeta.Namespace, Name: objectMeta.Name}
+}
+
+//lintServices finds services whose selector matches no pods, and services with identical selectors.
+func lintServices(objects lintObjects) []LintFinding {
+	var findings []LintFinding
+	sameSelector := map[string][]core_v1.Service{}
+	var selectors []string
+
+	for _, service := range objects.services {
+		if isSelectorlessService(service) {
+			continue
+		}
+
+		selector := labels.SelectorFromSet(service.Spec.Selector)
+		isMatched := false
+		for _, pod := range objects.pods {
+			if pod.Namespace == service.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+				isMatched = true
+				break
+			}
+		}
+		if !isMatched {
+			findings = append(findings, LintFinding{
+				Check:    LintServiceWithoutPods,
+				Severity: LintSeverityWarning,
+				Message:  fmt.Sprintf("Selector %s of service %s matches no pods", selector.String(), service.Name),
+				Objects:  []LintObjectReference{lintReference("service", service.ObjectMeta)},
+			})
+		}
+
+		key := service.Namespace + "/" + selector.String()
+		if _, ok := sameSelector[key]; !ok {
+			selectors = append(selectors, key)
+		}
+		sameSelector[key] = append(sameSelector[key], service)
+	}
+
+	for _, key := range selectors {
+		services := sameSelector[key]
+		if len(services) < 2 {
+			continue
+		}
+
+		var names []string
+		var references []LintObjectReference
+		for _, service := range services {
+			names = append(names, service.Name)
+			references = append(references, lintReference("service", service.ObjectMeta))
+		}
+		findings = append(findings, LintFinding{
+			Check:    LintDuplicateServiceSelector,
+			Severity: LintSeverityWarning,
+			Message:  fmt.Sprintf("Services %s have identical selector %s", strings.Join(names, ", "), labels.SelectorFromSet(services[0].Spec.Selector).String()),
+			Objects:  references,
+		})
+	}
+
+	return findings
+}
+
+//lintDeployments finds deployments whose template labels do not satisfy their own selector.
+func lintDeployments(objects lintObjects) []LintFinding {
+	var findings []LintFinding
+	for _, deployment := range objects.deployments {
+		selector, err := meta_v1.LabelSelectorAsSelector(deployment.Spec.Selector)
+		if err == nil && deployment.Spec.Selector != nil && selector.Matches(labels.Set(deployment.Spec.Template.Labels)) {
+			continue
+		}
+
+		findings = append(findings, LintFinding{
+			Check:    LintDeploymentSelectorMismatch,
+			Severity: LintSeverityError,
+			Message:  fmt.Sprintf("Template labels %s of deployment %s do not match its selector %s", formatLabels(deployment.Spec.Template.Labels), deployment.Name, meta_v1.FormatLabelSelector(deployment.Spec.Selector)),
+			Objects:  []LintObjectReference{lintReference("deployment", deployment.ObjectMeta)},
+		})
+	}
+
+	return findings
+}
+
+//lintOwners finds replica sets with no owner and pods with no controller.
+func lintOwners(objects lintObjects) []LintFinding {
+	var findings []LintFinding
+	for _, replicaSet := range objects.replicaSets {
+		if len(replicaSet.OwnerReferences) == 0 {
+			findings = append(findings, LintFinding{
+				Check:    LintOrphanReplicaSet,
+				Severity: LintSeverityWarning,
+				Message:  fmt.Sprintf("Replica set %s has no owner", replicaSet.Name),
+				Objects:  []LintObjectReference{lintReference("replicaset", replicaSet.ObjectMeta)},
+			})
+		}
+	}
+	for _, pod := range objects.pods {
+		if meta_v1.GetControllerOf(&pod) == nil {
+			findings = append(findings, LintFinding{
+				Check:    LintUnmanagedPod,
+				Severity: LintSeverityWarning,
+				Message:  fmt.Sprintf("Pod %s has no controller", pod.Name),
+				Objects:  []LintObjectReference{lintReference("pod", pod.ObjectMeta)},
+			})
+		}
+	}
+
+	return findings
+}
+
+//lintScaleTargets finds horizontal pod autoscalers targeting missing deployments or replica sets.
+//Targets of other kinds are not mapped, so they are not checked.
+func lintScaleTargets(objects lintObjects, autoscalers []autoscaling_v1.HorizontalPodAutoscaler) []LintFinding {
+	workloads := map[string]bool{}
+	for _, deployment := range objects.deployments {
+		workloads["Deployment/"+deployment.Namespace+"/"+deployment.Name] = true
+	}
+	for _, replicaSet := range objects.replicaSets {
+		workloads["ReplicaSet/"+replicaSet.Namespace+"/"+replicaSet.Name] = true
+	}
+
+	var findings []LintFinding
+	for _, autoscaler := range autoscalers {
+		target := autoscaler.Spec.ScaleTargetRef
+		if target.Kind != "Deployment" && target.Kind != "ReplicaSet" {
+			continue
+		}
+		if workloads[target.Kind+"/"+autoscaler.Namespace+"/"+target.Name] {
+			continue
+		}
+
+		findings = append(findings, LintFinding{
+			Check:    LintMissingScaleTarget,
+			Severity: LintSeverityError,
+			Message:  fmt.Sprintf("Horizontal pod autoscaler %s targets missing %s %s", autoscaler.Name, strings.ToLower(target.Kind), target.Name),
+			Objects: []LintObjectReference{
+				lintReference("horizontalpodautoscaler", autoscaler.ObjectMeta),
+				{Kind: strings.ToLower(target.Kind), Namespace: autoscaler.Namespace, Name: target.Name},
+			},
+		})
+	}
+
+	return findings
+}
@@ -0,0 +1,31 @@
+package kubemap
+
+import "sync"
+
+//lock acquires lock of given namespace and returns func to release it.
+func (l *namespaceLocks) lock(namespace string) func() {
+	l.cluster.RLock()
+
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*sync.Mutex{}
+	}
+	namespaceLock, ok := l.locks[namespace]
+	if !ok {
+		namespaceLock = &sync.Mutex{}
+		l.locks[namespace] = namespaceLock
+	}
+	l.mu.Unlock()
+
+	namespaceLock.Lock()
+	return func() {
+		namespaceLock.Unlock()
+		l.cluster.RUnlock()
+	}
+}
+
+//lockAll acquires locks of all namespaces and returns func to release them.
+func (l *namespaceLocks) lockAll() func() {
+	l.cluster.Lock()
+	return l.cluster.Unlock
+}
@@ -2,59 +2,103 @@ package kubemap
 
 import (
 	"fmt"
+	"log/slog"
 	"strings"
 
+	"github.com/go-logr/logr"
 	"go.uber.org/zap"
+	"go.uber.org/zap/exp/zapslog"
 	"go.uber.org/zap/zapcore"
 )
 
-func (m *Mapper) debug(msg string) {
+//Log methods take message followed by alternating keys and values,
+//like "kind", "pod", "name", "nginx", "namespace", "default".
+
+func (m *Mapper) debug(msg string, keysAndValues ...interface{}) {
 	if m.log.enabled {
-		m.log.logger.Debug(msg)
+		m.log.logger.Debug(msg, keysAndValues...)
 	}
 }
 
-func (m *Mapper) info(msg string) {
+func (m *Mapper) info(msg string, keysAndValues ...interface{}) {
 	if m.log.enabled {
-		m.log.logger.Info(msg)
+		m.log.logger.Info(msg, keysAndValues...)
 	}
 }
 
-func (m *Mapper) warn(msg string) {
+func (m *Mapper) warn(msg string, keysAndValues ...interface{}) {
 	if m.log.enabled {
-		m.log.logger.Warn(msg)
+		m.log.logger.Warn(msg, keysAndValues...)
 	}
 }
 
-func (m *Mapper) error(msg string) {
+func (m *Mapper) error(msg string, keysAndValues ...interface{}) {
 	if m.log.enabled {
-		m.log.logger.Error(msg)
+		m.log.logger.Error(msg, keysAndValues...)
 	}
 }
 
-func (m *Mapper) fatal(msg string) {
-	if m.log.enabled {
-		m.log.logger.Fatal(msg)
+//newLogger picks logger for Mapper. Handler takes precedence over Logger.
+//Without either of them zap development logger with given LogLevel is used.
+func newLogger(options LoggingOptions) (Logger, error) {
+	if options.Handler != nil {
+		return Logger{
+			enabled: true,
+			logger:  slog.New(options.Handler),
+		}, nil
 	}
+
+	if options.Logger.GetSink() != nil {
+		return Logger{
+			enabled: true,
+			logger:  slog.New(logr.ToSlogHandler(options.Logger)),
+		}, nil
+	}
+
+	zapLogger, zapErr := getZapLogger(options.LogLevel)
+	if zapErr != nil {
+		return Logger{}, zapErr
+	}
+
+	return Logger{
+		enabled: options.Enabled,
+		logger:  slog.New(zapslog.NewHandler(zapLogger.Core())),
+	}, nil
 }
 
-func getZapLogger(logLevel string) (*zap.SugaredLogger, error) {
+func getZapLogger(logLevel string) (*zap.Logger, error) {
 	//zap config
-	zap.NewProduction()
 	zapConfig := zap.NewDevelopmentConfig()
 
 	if logLevel != "" {
 		switch strings.ToLower(logLevel) {
-		case "info":
-			zapConfig.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
 		case "debug":
 			zapConfig.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+		case "info":
+			zapConfig.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+		case "warn":
+			zapConfig.Level = zap.NewAtomicLevelAt(zapcore.WarnLevel)
+		case "error":
+			zapConfig.Level = zap.NewAtomicLevelAt(zapcore.ErrorLevel)
 		default:
-			return nil, fmt.Errorf("Cannot instantiate Mapper. Invalid Log level %s provided. Accepted values are 'info' & 'debug'", logLevel)
+			return nil, fmt.Errorf("Cannot instantiate Mapper. Invalid Log level %s provided. Accepted values are 'debug', 'info', 'warn' & 'error'", logLevel)
 		}
 	}
 
-	logger, _ := zapConfig.Build()
-	defer logger.Sync() // flushes buffer, if any
-	return logger.Sugar(), nil
+	logger, err := zapConfig.Build()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot instantiate Mapper. Cannot build logger - %v", err)
+	}
+
+	return logger, nil
+}
+
+//resourceEventLogFields returns kind, name and namespace of a queued resource event followed by given keys and values.
+func resourceEventLogFields(obj interface{}, keysAndValues ...interface{}) []interface{} {
+	var fields []interface{}
+	if event, ok := obj.(ResourceEvent); ok {
+		fields = append(fields, "kind", event.ResourceType, "name", event.Name, "namespace", event.Namespace)
+	}
+
+	return append(fields, keysAndValues...)
 }
@@ -1,11 +1,13 @@
 package kubemap
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	apps_v1beta2 "k8s.io/api/apps/v1beta2"
 	core_v1 "k8s.io/api/core/v1"
@@ -13,35 +15,105 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
-func (m *Mapper) kubemapper(obj interface{}, store cache.Store) ([]MapResult, error) {
-	object := obj.(ResourceEvent)
-	m.debug(fmt.Sprintf("Processing object - K8s Type - %s Name - %s Namespace - %s", object.ResourceType, object.Name, object.Namespace))
+func (m *Mapper) kubemapper(ctx context.Context, obj interface{}, store cache.Store) (mapResults []MapResult, err error) {
+	object, ok := obj.(ResourceEvent)
+	if !ok {
+		return []MapResult{}, fmt.Errorf("%w: expected ResourceEvent, got %T", ErrInvalidEvent, obj)
+	}
+	m.debug("Processing object", "kind", object.ResourceType, "name", object.Name, "namespace", object.Namespace)
+
+	reason := m.filter.reject(object)
+	//Object relabeled out of selector may have been mapped before. Remove it as if it was deleted.
+	//Labels are checked last, so reason is theirs only when object is otherwise accepted.
+	if reason != "" && object.EventType == "UPDATED" && reason == m.filter.rejectLabels(object) {
+		m.debug("Object does not match label selector anymore. Mapping it as deleted", "kind", object.ResourceType, "name", object.Name, "namespace", object.Namespace)
+		object.EventType = "DELETED"
+		object.Event = nil
+		reason = ""
+	}
+
+	if reason != "" {
+		m.debug("Object rejected by filter", "kind", object.ResourceType, "name", object.Name, "namespace", object.Namespace, "reason", reason)
+		return []MapResult{{
+			Action:       "Rejected",
+			RejectReason: reason,
+			Message:      fmt.Sprintf("%s %s is not mapped. %s", object.ResourceType, object.Name, reason),
+		}}, nil
+	}
+
+	//Store is read, modified and written back. Do not let another event of same namespace interleave.
+	//Cluster scoped satellites change groups of other namespaces, so no other event may interleave with them.
+	var unlock func()
+	if satellite, ok := getSatelliteKind(object.ResourceType); ok && satellite.isClusterScoped() {
+		unlock = m.locks.lockAll()
+	} else {
+		unlock = m.locks.lock(object.Namespace)
+	}
+	defer unlock()
+
+	//Waiting for the lock may take a while. Don't map if caller has given up by now.
+	if err := ctx.Err(); err != nil {
+		return []MapResult{}, err
+	}
 
-	mappedResource, mapErr := m.resourceMapper(object, store)
+	start := time.Now()
+	ctx, span := m.startSpan(ctx, "kubemap.Map", eventAttributes(object)...)
+	defer func() {
+		m.metrics.eventProcessed(object, err, start)
+		setResultAttributes(span, mapResults)
+		endSpan(span, err)
+	}()
+
+	mappedResource, mapErr := m.resourceMapper(ctx, object, store)
+	if mapErr != nil {
+		return []MapResult{}, &MapError{ResourceType: object.ResourceType, Namespace: object.Namespace, Name: object.Name, Err: mapErr}
+	}
+
+	//Satellites of groups changed by event are attached here.
+	mappedResource, mapErr = attachSatellites(mappedResource, store, attachedSatelliteKinds(object.ResourceType))
 	if mapErr != nil {
-		return []MapResult{}, mapErr
+		return []MapResult{}, &MapError{ResourceType: object.ResourceType, Namespace: object.Namespace, Name: object.Name, Err: mapErr}
 	}
 
 	if object.EventType == "DELETED" {
-		m.info(fmt.Sprintf("Updating store for incoming DELETE event with Resource %s", object.Name))
+		m.info("Updating store for incoming DELETE event", "kind", object.ResourceType, "name", object.Name, "namespace", object.Namespace)
 	}
-	storeErr := m.updateStore(mappedResource, store)
+	storeErr := m.updateStore(ctx, mappedResource, store)
 	if storeErr != nil {
-		m.warn(fmt.Sprintf("Error while updating store - %v K8s Type - %s Name - %s Namespace - %s", storeErr, object.ResourceType, object.Name, object.Namespace))
-		return []MapResult{}, storeErr
+		m.warn("Error while updating store", "kind", object.ResourceType, "name", object.Name, "namespace", object.Namespace, "error", storeErr)
+		return []MapResult{}, &MapError{ResourceType: object.ResourceType, Namespace: object.Namespace, Name: object.Name, Err: storeErr}
+	}
+
+	//Services without selector follow pods referenced by their endpoints
+	if object.ResourceType == "endpoints" || object.ResourceType == "endpointslice" {
+		relinkResults, relinkErr := m.relinkSelectorlessService(ctx, object, store)
+		if relinkErr != nil {
+			return []MapResult{}, &MapError{ResourceType: object.ResourceType, Namespace: object.Namespace, Name: object.Name, Err: relinkErr}
+		}
+		mappedResource = append(mappedResource, relinkResults...)
+	}
+
+	//Services are linked to pods their endpoints reference, whether their selector matches them or not.
+	//Such pods may be mapped before or after endpoints.
+	if object.ResourceType == "endpoints" || object.ResourceType == "endpointslice" || object.ResourceType == "pod" && object.EventType != "DELETED" {
+		linkResults, linkErr := m.linkEndpointPods(ctx, object.Namespace, store)
+		if linkErr != nil {
+			return []MapResult{}, &MapError{ResourceType: object.ResourceType, Namespace: object.Namespace, Name: object.Name, Err: linkErr}
+		}
+		mappedResource = append(mappedResource, linkResults...)
 	}
 
 	if object.EventType == "DELETED" {
-		m.info(fmt.Sprintf("Store updated successfully for incoming DELETE event with Resource %s", object.Name))
+		m.info("Store updated successfully for incoming DELETE event", "kind", object.ResourceType, "name", object.Name, "namespace", object.Namespace)
 	}
 
 	return mappedResource, nil
 }
 
-func (m *Mapper) resourceMapper(obj ResourceEvent, store cache.Store) ([]MapResult, error) {
+func (m *Mapper) resourceMapper(ctx context.Context, obj ResourceEvent, store cache.Store) ([]MapResult, error) {
 	switch obj.ResourceType {
 	case "ingress":
-		mappedIngress, err := m.mapIngressObj(obj, store)
+		mappedIngress, err := m.mapIngressObj(ctx, obj, store)
 		if err != nil {
 			return []MapResult{}, err
 		}
@@ -53,18 +125,14 @@ func (m *Mapper) resourceMapper(obj ResourceEvent, store cache.Store) ([]MapResu
 			return []MapResult{}, err
 		}
 
-		return []MapResult{
-			mappedService,
-		}, nil
+		return mappedService, nil
 	case "deployment":
 		mappedDeployment, err := m.mapDeploymentObj(obj, store)
 		if err != nil {
 			return []MapResult{}, err
 		}
 
-		return []MapResult{
-			mappedDeployment,
-		}, nil
+		return mappedDeployment, nil
 	case "replicaset":
 		mappedReplicaSet, err := m.mapReplicaSetObj(obj, store)
 		if err != nil {
@@ -85,15 +153,23 @@ func (m *Mapper) resourceMapper(obj ResourceEvent, store cache.Store) ([]MapResu
 		}, nil
 	}
 
-	return []MapResult{}, fmt.Errorf("Resource type '%s' is not supported for mapping", obj.ResourceType)
+	if satellite, ok := getSatelliteKind(obj.ResourceType); ok {
+		return m.mapSatelliteObj(obj, store, satellite)
+	}
+
+	return []MapResult{}, fmt.Errorf("%w: '%s'", ErrUnsupportedKind, obj.ResourceType)
 }
 
-func (m *Mapper) mapIngressObj(obj ResourceEvent, store cache.Store) ([]MapResult, error) {
+func (m *Mapper) mapIngressObj(ctx context.Context, obj ResourceEvent, store cache.Store) ([]MapResult, error) {
 	var ingress ext_v1beta1.Ingress
 	var ingressBackendServices []string
 
 	if obj.Event != nil {
-		ingress = *obj.Event.(*ext_v1beta1.Ingress).DeepCopy()
+		eventIngress, ok := obj.Event.(*ext_v1beta1.Ingress)
+		if !ok {
+			return []MapResult{}, invalidEventError(obj)
+		}
+		ingress = *eventIngress.DeepCopy()
 		//Get all services from ingress rules
 		for _, ingressRule := range ingress.Spec.Rules {
 			if ingressRule.IngressRuleValue.HTTP != nil {
@@ -108,16 +184,16 @@ func (m *Mapper) mapIngressObj(obj ResourceEvent, store cache.Store) ([]MapResul
 		ingressBackendServices = removeDuplicateStrings(ingressBackendServices)
 
 		if obj.EventType == "ADDED" {
-			return m.addIngress(store, obj, ingress, ingressBackendServices)
+			return m.addIngress(ctx, store, obj, ingress, ingressBackendServices)
 		} else if obj.EventType == "UPDATED" {
 			mapResults := []MapResult{}
 
-			deleteResults, delErr := m.deleteIngress(store, obj)
+			deleteResults, delErr := m.deleteIngress(ctx, store, obj)
 			if delErr != nil {
 				return []MapResult{}, delErr
 			}
 
-			addResults, addErr := m.addIngress(store, obj, ingress, ingressBackendServices)
+			addResults, addErr := m.addIngress(ctx, store, obj, ingress, ingressBackendServices)
 			if addErr != nil {
 				return []MapResult{}, addErr
 			}
@@ -131,24 +207,17 @@ func (m *Mapper) mapIngressObj(obj ResourceEvent, store cache.Store) ([]MapResul
 
 	//Handle Delete
 	if obj.EventType == "DELETED" {
-		return m.deleteIngress(store, obj)
+		return m.deleteIngress(ctx, store, obj)
 	}
 	return []MapResult{}, nil
 }
 
-func (m *Mapper) addIngress(store cache.Store, obj ResourceEvent, ingress ext_v1beta1.Ingress, ingressBackendServices []string) ([]MapResult, error) {
+func (m *Mapper) addIngress(ctx context.Context, store cache.Store, obj ResourceEvent, ingress ext_v1beta1.Ingress, ingressBackendServices []string) ([]MapResult, error) {
 	var mapResults []MapResult
-	var namespaceKeys []string
-
-	keys := store.ListKeys()
-	for _, b64Key := range keys {
-		encodedKey, _ := base64.StdEncoding.DecodeString(b64Key)
-		key := fmt.Sprintf("%s", encodedKey)
-		if len(strings.Split(key, "$")) > 0 {
-			if strings.Split(key, "$")[0] == obj.Namespace {
-				namespaceKeys = append(namespaceKeys, key)
-			}
-		}
+
+	namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+	if err != nil {
+		return []MapResult{}, err
 	}
 
 	isMatched := false
@@ -156,16 +225,24 @@ func (m *Mapper) addIngress(store cache.Store, obj ResourceEvent, ingress ext_v1
 		metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 		metaIdentifier := MetaIdentifier{}
 
-		json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+		if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+			return []MapResult{}, storeKeyError(namespaceKey, err)
+		}
 
+		//Ingress may have more than one backend service in same mapped resource. Map it only once.
+		isKeyMatched := false
 		for _, ingressBackendService := range ingressBackendServices {
 			//Try matching with Service
 			for _, serviceName := range metaIdentifier.ServicesIdentifier.Names {
-				if serviceName == ingressBackendService {
+				if serviceName == ingressBackendService && !isKeyMatched {
+					isKeyMatched = true
 					//Get object
 
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 
 					isUpdated := false
 					for i, mappedIngress := range mappedResource.Kube.Ingresses {
@@ -222,7 +299,13 @@ func (m *Mapper) addIngress(store cache.Store, obj ResourceEvent, ingress ext_v1
 	}
 
 	//Update store right sway. Helps in B/G scenarios of ingress
-	m.updateStore(mapResults, store)
+	mapResults, err = attachSatellites(mapResults, store, satelliteKinds)
+	if err != nil {
+		return []MapResult{}, err
+	}
+	if err := m.updateStore(ctx, mapResults, store); err != nil {
+		return []MapResult{}, err
+	}
 
 	//Set IsStoreUpdated to true
 	for i := range mapResults {
@@ -232,28 +315,24 @@ func (m *Mapper) addIngress(store cache.Store, obj ResourceEvent, ingress ext_v1
 	return mapResults, nil
 }
 
-func (m *Mapper) deleteIngress(store cache.Store, obj ResourceEvent) ([]MapResult, error) {
-	m.info(fmt.Sprintf("DELETE received - K8s Type - %s Name - %s Namespace - %s", obj.ResourceType, obj.Name, obj.Namespace))
+func (m *Mapper) deleteIngress(ctx context.Context, store cache.Store, obj ResourceEvent) ([]MapResult, error) {
+	m.info("DELETE received", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace)
 
-	var ingressBackendServices, namespaceKeys []string
+	var ingressBackendServices []string
 	var mapResults []MapResult
 
-	keys := store.ListKeys()
-	for _, b64Key := range keys {
-		encodedKey, _ := base64.StdEncoding.DecodeString(b64Key)
-		key := fmt.Sprintf("%s", encodedKey)
-		if len(strings.Split(key, "$")) > 0 {
-			if strings.Split(key, "$")[0] == obj.Namespace {
-				namespaceKeys = append(namespaceKeys, key)
-			}
-		}
+	namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+	if err != nil {
+		return []MapResult{}, err
 	}
 
 	for _, namespaceKey := range namespaceKeys {
 		metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 		metaIdentifier := MetaIdentifier{}
 
-		json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+		if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+			return []MapResult{}, storeKeyError(namespaceKey, err)
+		}
 
 		for _, ingressName := range metaIdentifier.IngressIdentifier.Names {
 			if ingressName == obj.Name {
@@ -264,19 +343,27 @@ func (m *Mapper) deleteIngress(store cache.Store, obj ResourceEvent) ([]MapResul
 		}
 	}
 
+	//Ingress may have more than one backend service in same mapped resource. Delete it only once.
+	matchedKeys := map[string]bool{}
 	for _, ingressBackendService := range ingressBackendServices {
 		for _, namespaceKey := range namespaceKeys {
 			metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 			metaIdentifier := MetaIdentifier{}
 
-			json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+			if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+				return []MapResult{}, storeKeyError(namespaceKey, err)
+			}
 
 			var newIngressSet []ext_v1beta1.Ingress
 			for _, serviceName := range metaIdentifier.ServicesIdentifier.Names {
-				if serviceName == ingressBackendService {
+				if serviceName == ingressBackendService && !matchedKeys[namespaceKey] {
+					matchedKeys[namespaceKey] = true
 					//Services matched. See if ingress is present. If it is, then delete it.
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 
 					newIngressSet = nil
 					isPresent := false
@@ -322,31 +409,42 @@ func (m *Mapper) deleteIngress(store cache.Store, obj ResourceEvent) ([]MapResul
 	}
 
 	//Update store right sway. Helps in B/G scenarios of ingress
-	m.updateStore(mapResults, store)
+	mapResults, err = attachSatellites(mapResults, store, satelliteKinds)
+	if err != nil {
+		return []MapResult{}, err
+	}
+	if err := m.updateStore(ctx, mapResults, store); err != nil {
+		return []MapResult{}, err
+	}
 
 	//Set IsStoreUpdated to true
 	for i := range mapResults {
 		mapResults[i].IsStoreUpdated = true
 	}
 
-	m.info(fmt.Sprintf("DELETE Completed - K8s Type - %s Name - %s Namespace - %s", obj.ResourceType, obj.Name, obj.Namespace))
+	m.info("DELETE completed", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace)
 	return mapResults, nil
 }
 
-func (m *Mapper) ingressCheck(mappedResource MappedResource, serviceName string, namespaceKeys []string, store cache.Store) (MappedResource, []string) {
+func (m *Mapper) ingressCheck(mappedResource MappedResource, serviceName string, namespaceKeys []string, store cache.Store) (MappedResource, []string, error) {
 	var oldIngressDeleteKeys []string
 	for _, namespaceKey := range namespaceKeys {
 		metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 		metaIdentifier := MetaIdentifier{}
 
-		json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+		if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+			return MappedResource{}, nil, storeKeyError(namespaceKey, err)
+		}
 		if metaIdentifier.DeploymentsIdentifier.MatchLabels == nil && metaIdentifier.PodsIdentifier == nil && metaIdentifier.ReplicaSetsIdentifier == nil && metaIdentifier.ServicesIdentifier.MatchLabels == nil && metaIdentifier.IngressIdentifier.IngressBackendServices != nil {
 			//Its an object with just ingress
 			for _, ingressBackendService := range metaIdentifier.IngressIdentifier.IngressBackendServices {
 				if ingressBackendService == serviceName {
 					//This ingress belongs to this service. Add it
 					// ingressMappedResource, _ := getObjectFromStore(namespaceKey, store)
-					ingressMappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					ingressMappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return MappedResource{}, nil, err
+					}
 					for _, loneIngress := range ingressMappedResource.Kube.Ingresses {
 						mappedResource.Kube.Ingresses = append(mappedResource.Kube.Ingresses, loneIngress)
 					}
@@ -361,7 +459,10 @@ func (m *Mapper) ingressCheck(mappedResource MappedResource, serviceName string,
 		//This ingress belongs to this service. Add it
 
 		// ingressMappedResource, _ := getObjectFromStore(namespaceKey, store)
-		ingressMappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+		ingressMappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+		if err != nil {
+			return MappedResource{}, nil, err
+		}
 
 		for _, mappedIngress := range mappedResource.Kube.Ingresses {
 			for _, mappedIngressResource := range ingressMappedResource.Kube.Ingresses {
@@ -389,55 +490,65 @@ func (m *Mapper) ingressCheck(mappedResource MappedResource, serviceName string,
 		//}
 	}
 
-	return mappedResource, oldIngressDeleteKeys
+	return mappedResource, oldIngressDeleteKeys, nil
 }
 
-func (m *Mapper) mapServiceObj(obj ResourceEvent, store cache.Store) (MapResult, error) {
+func (m *Mapper) mapServiceObj(obj ResourceEvent, store cache.Store) ([]MapResult, error) {
 	var service core_v1.Service
-	var namespaceKeys []string
 
 	if obj.Event != nil {
-		service = *obj.Event.(*core_v1.Service).DeepCopy()
-
-		keys := store.ListKeys()
-		for _, b64Key := range keys {
-			encodedKey, _ := base64.StdEncoding.DecodeString(b64Key)
-			key := fmt.Sprintf("%s", encodedKey)
-			if len(strings.Split(key, "$")) > 0 {
-				if strings.Split(key, "$")[0] == obj.Namespace {
-					namespaceKeys = append(namespaceKeys, key)
-				}
-			}
+		eventService, ok := obj.Event.(*core_v1.Service)
+		if !ok {
+			return []MapResult{}, invalidEventError(obj)
+		}
+		service = *eventService.DeepCopy()
+
+		namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+		if err != nil {
+			return []MapResult{}, err
+		}
+
+		//Services without selector never match labels of workloads
+		if isSelectorlessService(service) {
+			return m.mapSelectorlessService(service, namespaceKeys, store)
 		}
 
 		for _, namespaceKey := range namespaceKeys {
 			metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 			metaIdentifier := MetaIdentifier{}
 
-			json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+			if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+				return []MapResult{}, storeKeyError(namespaceKey, err)
+			}
 
 			//Try matching with Service
 			for _, svcID := range metaIdentifier.ServicesIdentifier.MatchLabels {
 				if reflect.DeepEqual(service.Spec.Selector, svcID) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 
 					for i, mappedService := range mappedResource.Kube.Services {
 						if mappedService.Name == service.Name {
 							mappedResource.Kube.Services[i] = service
 
-							newMappedResource, deleteKeys := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+							newMappedResource, deleteKeys, err := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+							if err != nil {
+								return []MapResult{}, err
+							}
 							deleteKeys = append(deleteKeys, namespaceKey)
 							deleteKeys = removeDuplicateStrings(deleteKeys)
 
-							return MapResult{
+							return []MapResult{{
 								Action:         "Updated",
 								DeleteKeys:     deleteKeys,
 								IsMapped:       true,
 								MappedResource: newMappedResource,
 								Message:        fmt.Sprintf("Service %s updated in Common Label %s after matching with service.", service.Name, mappedResource.CommonLabel),
-							}, nil
+							}}, nil
 						}
 					}
 				}
@@ -448,23 +559,29 @@ func (m *Mapper) mapServiceObj(obj ResourceEvent, store cache.Store) (MapResult,
 				if reflect.DeepEqual(service.Spec.Selector, depID) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 
 					for i, mappedService := range mappedResource.Kube.Services {
 						if mappedService.Name == service.Name {
 							mappedResource.Kube.Services[i] = service
 
-							newMappedResource, deleteKeys := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+							newMappedResource, deleteKeys, err := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+							if err != nil {
+								return []MapResult{}, err
+							}
 							deleteKeys = append(deleteKeys, namespaceKey)
 							deleteKeys = removeDuplicateStrings(deleteKeys)
 
-							return MapResult{
+							return []MapResult{{
 								Action:         "Updated",
 								DeleteKeys:     deleteKeys,
 								IsMapped:       true,
 								MappedResource: newMappedResource,
 								Message:        fmt.Sprintf("Service %s updated in Common Label %s after matching with deployment.", service.Name, mappedResource.CommonLabel),
-							}, nil
+							}}, nil
 						}
 					}
 
@@ -473,17 +590,20 @@ func (m *Mapper) mapServiceObj(obj ResourceEvent, store cache.Store) (MapResult,
 						mappedResource.CommonLabel = service.Name
 					}
 
-					newMappedResource, deleteKeys := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+					newMappedResource, deleteKeys, err := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 					deleteKeys = append(deleteKeys, namespaceKey)
 					deleteKeys = removeDuplicateStrings(deleteKeys)
 
-					return MapResult{
+					return []MapResult{{
 						Action:         "Updated",
 						DeleteKeys:     deleteKeys,
 						IsMapped:       true,
 						MappedResource: newMappedResource,
 						Message:        fmt.Sprintf("Service %s is added to Common Label %s after matching with deployment.", service.Name, mappedResource.CommonLabel),
-					}, nil
+					}}, nil
 				}
 			}
 
@@ -500,23 +620,29 @@ func (m *Mapper) mapServiceObj(obj ResourceEvent, store cache.Store) (MapResult,
 				if reflect.DeepEqual(service.Spec.Selector, serviceMatchedLabels) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 
 					for i, mappedService := range mappedResource.Kube.Services {
 						if mappedService.Name == service.Name {
 							mappedResource.Kube.Services[i] = service
 
-							newMappedResource, deleteKeys := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+							newMappedResource, deleteKeys, err := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+							if err != nil {
+								return []MapResult{}, err
+							}
 							deleteKeys = append(deleteKeys, namespaceKey)
 							deleteKeys = removeDuplicateStrings(deleteKeys)
 
-							return MapResult{
+							return []MapResult{{
 								Action:         "Updated",
 								DeleteKeys:     deleteKeys,
 								IsMapped:       true,
 								MappedResource: newMappedResource,
 								Message:        fmt.Sprintf("Service %s is updated in Common Label %s after matching with replica set.", service.Name, mappedResource.CommonLabel),
-							}, nil
+							}}, nil
 						}
 					}
 
@@ -524,17 +650,20 @@ func (m *Mapper) mapServiceObj(obj ResourceEvent, store cache.Store) (MapResult,
 					if len(mappedResource.Kube.Services) < 2 { //Set Common Label to service name.
 						mappedResource.CommonLabel = service.Name
 					}
-					newMappedResource, deleteKeys := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+					newMappedResource, deleteKeys, err := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 					deleteKeys = append(deleteKeys, namespaceKey)
 					deleteKeys = removeDuplicateStrings(deleteKeys)
 
-					return MapResult{
+					return []MapResult{{
 						Action:         "Updated",
 						DeleteKeys:     deleteKeys,
 						IsMapped:       true,
 						MappedResource: newMappedResource,
 						Message:        fmt.Sprintf("Service %s is added to Common Label %s after matching with replica set.", service.Name, mappedResource.CommonLabel),
-					}, nil
+					}}, nil
 				}
 			}
 
@@ -551,23 +680,29 @@ func (m *Mapper) mapServiceObj(obj ResourceEvent, store cache.Store) (MapResult,
 				if reflect.DeepEqual(service.Spec.Selector, serviceMatchedLabels) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 
 					for i, mappedService := range mappedResource.Kube.Services {
 						if mappedService.Name == service.Name {
 							mappedResource.Kube.Services[i] = service
 
-							newMappedResource, deleteKeys := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+							newMappedResource, deleteKeys, err := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+							if err != nil {
+								return []MapResult{}, err
+							}
 							deleteKeys = append(deleteKeys, namespaceKey)
 							deleteKeys = removeDuplicateStrings(deleteKeys)
 
-							return MapResult{
+							return []MapResult{{
 								Action:         "Updated",
 								DeleteKeys:     deleteKeys,
 								IsMapped:       true,
 								MappedResource: newMappedResource,
 								Message:        fmt.Sprintf("Service %s is updated in Common Label %s after matching with pod.", service.Name, mappedResource.CommonLabel),
-							}, nil
+							}}, nil
 						}
 					}
 
@@ -575,18 +710,21 @@ func (m *Mapper) mapServiceObj(obj ResourceEvent, store cache.Store) (MapResult,
 					if len(mappedResource.Kube.Services) < 2 { //Set Common Label to service name.
 						mappedResource.CommonLabel = service.Name
 					}
-					newMappedResource, deleteKeys := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+					newMappedResource, deleteKeys, err := m.ingressCheck(mappedResource, service.Name, namespaceKeys, store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 					deleteKeys = append(deleteKeys, namespaceKey)
 					deleteKeys = removeDuplicateStrings(deleteKeys)
 
-					return MapResult{
+					return []MapResult{{
 						Action: "Updated",
 						// Key:            namespaceKey,
 						DeleteKeys:     deleteKeys,
 						IsMapped:       true,
 						MappedResource: newMappedResource,
 						Message:        fmt.Sprintf("Service %s is added to Common Label %s after matching with pod.", service.Name, mappedResource.CommonLabel),
-					}, nil
+					}}, nil
 				}
 			}
 		}
@@ -598,31 +736,28 @@ func (m *Mapper) mapServiceObj(obj ResourceEvent, store cache.Store) (MapResult,
 		newMappedService.Namespace = service.Namespace
 		newMappedService.Kube.Services = append(newMappedService.Kube.Services, service)
 
-		newMappedResourceWithIngress, deleteKeys := m.ingressCheck(newMappedService, service.Name, namespaceKeys, store)
+		newMappedResourceWithIngress, deleteKeys, err := m.ingressCheck(newMappedService, service.Name, namespaceKeys, store)
+		if err != nil {
+			return []MapResult{}, err
+		}
 		deleteKeys = removeDuplicateStrings(deleteKeys)
 
-		return MapResult{
+		return []MapResult{{
 			Action:         "Added",
 			IsMapped:       true,
 			DeleteKeys:     deleteKeys,
 			MappedResource: newMappedResourceWithIngress,
 			Message:        fmt.Sprintf("New service %s is added with Common Label %s", service.Name, newMappedResourceWithIngress.CommonLabel),
-		}, nil
+		}}, nil
 	}
 
 	//Handle Delete
 	if obj.EventType == "DELETED" {
-		m.info(fmt.Sprintf("DELETE received - K8s Type - %s Name - %s Namespace - %s", obj.ResourceType, obj.Name, obj.Namespace))
-
-		keys := store.ListKeys()
-		for _, b64Key := range keys {
-			encodedKey, _ := base64.StdEncoding.DecodeString(b64Key)
-			key := fmt.Sprintf("%s", encodedKey)
-			if len(strings.Split(key, "$")) > 0 {
-				if strings.Split(key, "$")[0] == obj.Namespace {
-					namespaceKeys = append(namespaceKeys, key)
-				}
-			}
+		m.info("DELETE received", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace)
+
+		namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+		if err != nil {
+			return []MapResult{}, err
 		}
 
 		var newSvcSet []core_v1.Service
@@ -630,13 +765,18 @@ func (m *Mapper) mapServiceObj(obj ResourceEvent, store cache.Store) (MapResult,
 			metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 			metaIdentifier := MetaIdentifier{}
 
-			json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+			if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+				return []MapResult{}, storeKeyError(namespaceKey, err)
+			}
 
 			for _, mappedSvcName := range metaIdentifier.ServicesIdentifier.Names {
 				if mappedSvcName == obj.Name {
 					//Pod is being deleted.
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 
 					newSvcSet = nil
 					for _, mappedService := range mappedResource.Kube.Services {
@@ -650,86 +790,86 @@ func (m *Mapper) mapServiceObj(obj ResourceEvent, store cache.Store) (MapResult,
 						mappedResource.Kube.Services = nil
 						mappedResource.Kube.Services = newSvcSet
 
-						m.info(fmt.Sprintf("DELETE Completed - K8s Type - %s Name - %s Namespace - %s CL %s updated.", obj.ResourceType, obj.Name, obj.Namespace, mappedResource.CommonLabel))
-						return MapResult{
-							Action:         "Updated",
-							Key:            namespaceKey,
-							IsMapped:       true,
-							MappedResource: mappedResource,
-							Message:        fmt.Sprintf("Service %s is deleted from Common Label %s", service.Name, mappedResource.CommonLabel),
-						}, nil
+						m.info("DELETE completed", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace, "group", mappedResource.CommonLabel, "action", "Updated")
+						//Removing the service may leave members with nothing linking them. Split them into separate groups.
+						return splitMappedResourceResults(namespaceKey, mappedResource, fmt.Sprintf("Service %s is deleted from Common Label %s", obj.Name, mappedResource.CommonLabel)), nil
 					}
 
-					m.info(fmt.Sprintf("DELETE Completed. - K8s Type - %s Name - %s Namespace - %s CL %s deleted.", obj.ResourceType, obj.Name, obj.Namespace, mappedResource.CommonLabel))
-					return MapResult{
+					m.info("DELETE completed", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace, "group", mappedResource.CommonLabel, "action", "Deleted")
+					return []MapResult{{
 						Action:         "Deleted",
 						Key:            namespaceKey,
 						IsMapped:       true,
 						CommonLabel:    mappedResource.CommonLabel,
 						MappedResource: mappedResource,
-						Message:        fmt.Sprintf("Service %s is deleted from Common Label %s", service.Name, mappedResource.CommonLabel),
-					}, nil
+						Message:        fmt.Sprintf("Service %s is deleted from Common Label %s", obj.Name, mappedResource.CommonLabel),
+					}}, nil
 
 				}
 			}
 		}
 	}
-	return MapResult{}, nil
+	return []MapResult{}, nil
 }
 
-func (m *Mapper) mapDeploymentObj(obj ResourceEvent, store cache.Store) (MapResult, error) {
+func (m *Mapper) mapDeploymentObj(obj ResourceEvent, store cache.Store) ([]MapResult, error) {
 	var deployment apps_v1beta2.Deployment
-	var namespaceKeys []string
 
 	if obj.Event != nil {
-		deployment = *obj.Event.(*apps_v1beta2.Deployment).DeepCopy()
-
-		keys := store.ListKeys()
-		for _, b64Key := range keys {
-			encodedKey, _ := base64.StdEncoding.DecodeString(b64Key)
-			key := fmt.Sprintf("%s", encodedKey)
-			if len(strings.Split(key, "$")) > 0 {
-				if strings.Split(key, "$")[0] == obj.Namespace {
-					namespaceKeys = append(namespaceKeys, key)
-				}
-			}
+		eventDeployment, ok := obj.Event.(*apps_v1beta2.Deployment)
+		if !ok {
+			return []MapResult{}, invalidEventError(obj)
+		}
+		deployment = *eventDeployment.DeepCopy()
+		if deployment.Spec.Selector == nil {
+			return []MapResult{}, missingSelectorError(obj)
+		}
+
+		namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+		if err != nil {
+			return []MapResult{}, err
 		}
 
 		for _, namespaceKey := range namespaceKeys {
 			metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 			metaIdentifier := MetaIdentifier{}
 
-			json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+			if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+				return []MapResult{}, storeKeyError(namespaceKey, err)
+			}
 
 			//Try matching with Service
 			for _, svcID := range metaIdentifier.ServicesIdentifier.MatchLabels {
 				if reflect.DeepEqual(deployment.Spec.Selector.MatchLabels, svcID) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 
 					for i, mappedDeployment := range mappedResource.Kube.Deployments {
 						if mappedDeployment.Name == deployment.Name {
 							mappedResource.Kube.Deployments[i] = deployment
 
-							return MapResult{
+							return []MapResult{{
 								Action:         "Updated",
 								Key:            namespaceKey,
 								IsMapped:       true,
 								MappedResource: mappedResource,
 								Message:        fmt.Sprintf("Deployment %s is updated in Common Label %s after matching with service", deployment.Name, mappedResource.CommonLabel),
-							}, nil
+							}}, nil
 						}
 					}
 
 					mappedResource.Kube.Deployments = append(mappedResource.Kube.Deployments, deployment)
-					return MapResult{
+					return []MapResult{{
 						Action:         "Updated",
 						Key:            namespaceKey,
 						IsMapped:       true,
 						MappedResource: mappedResource,
 						Message:        fmt.Sprintf("Deployment %s is added to Common Label %s after matching with service", deployment.Name, mappedResource.CommonLabel),
-					}, nil
+					}}, nil
 				}
 			}
 
@@ -738,19 +878,22 @@ func (m *Mapper) mapDeploymentObj(obj ResourceEvent, store cache.Store) (MapResu
 				if reflect.DeepEqual(deployment.Spec.Selector.MatchLabels, depID) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 
 					for i, mappedDeployment := range mappedResource.Kube.Deployments {
 						if mappedDeployment.Name == deployment.Name {
 							mappedResource.Kube.Deployments[i] = deployment
 
-							return MapResult{
+							return []MapResult{{
 								Action:         "Updated",
 								Key:            namespaceKey,
 								IsMapped:       true,
 								MappedResource: mappedResource,
 								Message:        fmt.Sprintf("Deployment %s is updated io Common Label %s after matching with deployment", deployment.Name, mappedResource.CommonLabel),
-							}, nil
+							}}, nil
 						}
 					}
 				}
@@ -762,19 +905,22 @@ func (m *Mapper) mapDeploymentObj(obj ResourceEvent, store cache.Store) (MapResu
 					if ownerReference == deployment.Name {
 						//Deployment and RS matches. Add deployment to this mapped resource
 						// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-						mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+						mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+						if err != nil {
+							return []MapResult{}, err
+						}
 
 						for i, mappedDeployment := range mappedResource.Kube.Deployments {
 							if mappedDeployment.Name == deployment.Name {
 								mappedResource.Kube.Deployments[i] = deployment
 
-								return MapResult{
+								return []MapResult{{
 									Action:         "Updated",
 									Key:            namespaceKey,
 									IsMapped:       true,
 									MappedResource: mappedResource,
 									Message:        fmt.Sprintf("Deployment %s is updated io Common Label %s after matching with replica set", deployment.Name, mappedResource.CommonLabel),
-								}, nil
+								}}, nil
 							}
 						}
 
@@ -782,13 +928,13 @@ func (m *Mapper) mapDeploymentObj(obj ResourceEvent, store cache.Store) (MapResu
 						if len(mappedResource.Kube.Deployments) < 2 { //Set Common Label to deployment name.
 							mappedResource.CommonLabel = deployment.Name
 						}
-						return MapResult{
+						return []MapResult{{
 							Action:         "Updated",
 							Key:            namespaceKey,
 							IsMapped:       true,
 							MappedResource: mappedResource,
 							Message:        fmt.Sprintf("Deployment %s is added to Common Label %s after matching with replica set", deployment.Name, mappedResource.CommonLabel),
-						}, nil
+						}}, nil
 					}
 				}
 			}
@@ -807,19 +953,22 @@ func (m *Mapper) mapDeploymentObj(obj ResourceEvent, store cache.Store) (MapResu
 				if reflect.DeepEqual(deployment.Spec.Selector.MatchLabels, podMatchedLabels) {
 					//Deployment and RS matches. Add deployment to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 
 					for i, mappedDeployment := range mappedResource.Kube.Deployments {
 						if mappedDeployment.Name == deployment.Name {
 							mappedResource.Kube.Deployments[i] = deployment
 
-							return MapResult{
+							return []MapResult{{
 								Action:         "Updated",
 								Key:            namespaceKey,
 								IsMapped:       true,
 								MappedResource: mappedResource,
 								Message:        fmt.Sprintf("Deployment %s is updated io Common Label %s after matching with pod", deployment.Name, mappedResource.CommonLabel),
-							}, nil
+							}}, nil
 						}
 					}
 
@@ -827,13 +976,13 @@ func (m *Mapper) mapDeploymentObj(obj ResourceEvent, store cache.Store) (MapResu
 					if len(mappedResource.Kube.Deployments) < 2 { //Set Common Label to deployment name.
 						mappedResource.CommonLabel = deployment.Name
 					}
-					return MapResult{
+					return []MapResult{{
 						Action:         "Updated",
 						Key:            namespaceKey,
 						IsMapped:       true,
 						MappedResource: mappedResource,
 						Message:        fmt.Sprintf("Deployment %s is added to Common Label %s after matching with pod", deployment.Name, mappedResource.CommonLabel),
-					}, nil
+					}}, nil
 				}
 			}
 		}
@@ -845,27 +994,21 @@ func (m *Mapper) mapDeploymentObj(obj ResourceEvent, store cache.Store) (MapResu
 		newMappedService.Namespace = deployment.Namespace
 		newMappedService.Kube.Deployments = append(newMappedService.Kube.Deployments, deployment)
 
-		return MapResult{
+		return []MapResult{{
 			Action:         "Added",
 			IsMapped:       true,
 			MappedResource: newMappedService,
 			Message:        fmt.Sprintf("New deployment %s is created with Common Label %s", deployment.Name, newMappedService.CommonLabel),
-		}, nil
+		}}, nil
 	}
 
 	//Handle Delete
 	if obj.EventType == "DELETED" {
-		m.info(fmt.Sprintf("DELETE received. - K8s Type - %s Name - %s Namespace - %s", obj.ResourceType, obj.Name, obj.Namespace))
-
-		keys := store.ListKeys()
-		for _, b64Key := range keys {
-			encodedKey, _ := base64.StdEncoding.DecodeString(b64Key)
-			key := fmt.Sprintf("%s", encodedKey)
-			if len(strings.Split(key, "$")) > 0 {
-				if strings.Split(key, "$")[0] == obj.Namespace {
-					namespaceKeys = append(namespaceKeys, key)
-				}
-			}
+		m.info("DELETE received", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace)
+
+		namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+		if err != nil {
+			return []MapResult{}, err
 		}
 
 		var newDepSet []apps_v1beta2.Deployment
@@ -873,13 +1016,18 @@ func (m *Mapper) mapDeploymentObj(obj ResourceEvent, store cache.Store) (MapResu
 			metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 			metaIdentifier := MetaIdentifier{}
 
-			json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+			if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+				return []MapResult{}, storeKeyError(namespaceKey, err)
+			}
 
 			for _, mappedDepName := range metaIdentifier.DeploymentsIdentifier.Names {
 				if mappedDepName == obj.Name {
 					//Pod is being deleted.
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return []MapResult{}, err
+					}
 
 					newDepSet = nil
 					for _, mappedDeployment := range mappedResource.Kube.Deployments {
@@ -893,56 +1041,50 @@ func (m *Mapper) mapDeploymentObj(obj ResourceEvent, store cache.Store) (MapResu
 						mappedResource.Kube.Deployments = nil
 						mappedResource.Kube.Deployments = newDepSet
 
-						m.info(fmt.Sprintf("DELETE Completed. - K8s Type - %s Name - %s Namespace - %s CL %s updated.", obj.ResourceType, obj.Name, obj.Namespace, mappedResource.CommonLabel))
-						return MapResult{
-							Action:         "Updated",
-							Key:            namespaceKey,
-							IsMapped:       true,
-							MappedResource: mappedResource,
-							Message:        fmt.Sprintf("Deployment %s is deleted from Common Label %s", deployment.Name, mappedResource.CommonLabel),
-						}, nil
+						m.info("DELETE completed", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace, "group", mappedResource.CommonLabel, "action", "Updated")
+						//Removing the deployment may leave members with nothing linking them. Split them into separate groups.
+						return splitMappedResourceResults(namespaceKey, mappedResource, fmt.Sprintf("Deployment %s is deleted from Common Label %s", obj.Name, mappedResource.CommonLabel)), nil
 					}
 
-					m.info(fmt.Sprintf("DELETE Completed. - K8s Type - %s Name - %s Namespace - %s CL %s deleted.", obj.ResourceType, obj.Name, obj.Namespace, mappedResource.CommonLabel))
-					return MapResult{
+					m.info("DELETE completed", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace, "group", mappedResource.CommonLabel, "action", "Deleted")
+					return []MapResult{{
 						Action:         "Deleted",
 						Key:            namespaceKey,
 						IsMapped:       true,
 						CommonLabel:    mappedResource.CommonLabel,
 						MappedResource: mappedResource,
-						Message:        fmt.Sprintf("Deployment %s is deleted from Common Label %s", deployment.Name, mappedResource.CommonLabel),
-					}, nil
+						Message:        fmt.Sprintf("Deployment %s is deleted from Common Label %s", obj.Name, mappedResource.CommonLabel),
+					}}, nil
 
 				}
 			}
 		}
 	}
-	return MapResult{}, nil
+	return []MapResult{}, nil
 }
 
 func (m *Mapper) mapPodObj(obj ResourceEvent, store cache.Store) (MapResult, error) {
 	var pod core_v1.Pod
-	var namespaceKeys []string
 
 	if obj.Event != nil {
-		pod = *obj.Event.(*core_v1.Pod).DeepCopy()
-
-		keys := store.ListKeys()
-		for _, b64Key := range keys {
-			encodedKey, _ := base64.StdEncoding.DecodeString(b64Key)
-			key := fmt.Sprintf("%s", encodedKey)
-			if len(strings.Split(key, "$")) > 0 {
-				if strings.Split(key, "$")[0] == obj.Namespace {
-					namespaceKeys = append(namespaceKeys, key)
-				}
-			}
+		eventPod, ok := obj.Event.(*core_v1.Pod)
+		if !ok {
+			return MapResult{}, invalidEventError(obj)
+		}
+		pod = *eventPod.DeepCopy()
+
+		namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+		if err != nil {
+			return MapResult{}, err
 		}
 
 		for _, namespaceKey := range namespaceKeys {
 			metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 			metaIdentifier := MetaIdentifier{}
 
-			json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+			if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+				return MapResult{}, storeKeyError(namespaceKey, err)
+			}
 
 			//Try matching with Service
 			for _, svcID := range metaIdentifier.ServicesIdentifier.MatchLabels {
@@ -957,7 +1099,10 @@ func (m *Mapper) mapPodObj(obj ResourceEvent, store cache.Store) (MapResult, err
 				if reflect.DeepEqual(podMatchedLabels, svcID) {
 					//Service and pod matches. Add pod to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return MapResult{}, err
+					}
 
 					for i, mappedPod := range mappedResource.Kube.Pods {
 						if mappedPod.Name == pod.Name {
@@ -997,7 +1142,10 @@ func (m *Mapper) mapPodObj(obj ResourceEvent, store cache.Store) (MapResult, err
 				if reflect.DeepEqual(podMatchedLabels, depID) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return MapResult{}, err
+					}
 
 					for i, mappedPod := range mappedResource.Kube.Pods {
 						if mappedPod.Name == pod.Name {
@@ -1037,7 +1185,10 @@ func (m *Mapper) mapPodObj(obj ResourceEvent, store cache.Store) (MapResult, err
 				if reflect.DeepEqual(podMatchedLabels, rsID.MatchLabels) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return MapResult{}, err
+					}
 
 					for i, mappedPod := range mappedResource.Kube.Pods {
 						if mappedPod.Name == pod.Name {
@@ -1069,7 +1220,10 @@ func (m *Mapper) mapPodObj(obj ResourceEvent, store cache.Store) (MapResult, err
 				if reflect.DeepEqual(pod.Labels, podID.MatchLabels) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return MapResult{}, err
+					}
 
 					for i, mappedPod := range mappedResource.Kube.Pods {
 						if mappedPod.Name == pod.Name {
@@ -1105,17 +1259,11 @@ func (m *Mapper) mapPodObj(obj ResourceEvent, store cache.Store) (MapResult, err
 
 	//Handle Delete
 	if obj.EventType == "DELETED" {
-		m.info(fmt.Sprintf("DELETE received. - K8s Type - %s Name - %s Namespace - %s", obj.ResourceType, obj.Name, obj.Namespace))
-
-		keys := store.ListKeys()
-		for _, b64Key := range keys {
-			encodedKey, _ := base64.StdEncoding.DecodeString(b64Key)
-			key := fmt.Sprintf("%s", encodedKey)
-			if len(strings.Split(key, "$")) > 0 {
-				if strings.Split(key, "$")[0] == obj.Namespace {
-					namespaceKeys = append(namespaceKeys, key)
-				}
-			}
+		m.info("DELETE received", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace)
+
+		namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+		if err != nil {
+			return MapResult{}, err
 		}
 
 		var newPodSet []core_v1.Pod
@@ -1123,13 +1271,18 @@ func (m *Mapper) mapPodObj(obj ResourceEvent, store cache.Store) (MapResult, err
 			metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 			metaIdentifier := MetaIdentifier{}
 
-			json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+			if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+				return MapResult{}, storeKeyError(namespaceKey, err)
+			}
 
 			for _, podChileSet := range metaIdentifier.PodsIdentifier {
 				if podChileSet.Name == obj.Name {
 					//Pod is being deleted.
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return MapResult{}, err
+					}
 
 					newPodSet = nil
 					for _, mappedPod := range mappedResource.Kube.Pods {
@@ -1143,7 +1296,7 @@ func (m *Mapper) mapPodObj(obj ResourceEvent, store cache.Store) (MapResult, err
 						mappedResource.Kube.Pods = nil
 						mappedResource.Kube.Pods = newPodSet
 
-						m.info(fmt.Sprintf("DELETE Completed. - K8s Type - %s Name - %s Namespace - %s CL %s updated.", obj.ResourceType, obj.Name, obj.Namespace, mappedResource.CommonLabel))
+						m.info("DELETE completed", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace, "group", mappedResource.CommonLabel, "action", "Updated")
 						return MapResult{
 							Action:         "Updated",
 							Key:            namespaceKey,
@@ -1153,7 +1306,7 @@ func (m *Mapper) mapPodObj(obj ResourceEvent, store cache.Store) (MapResult, err
 						}, nil
 					}
 
-					m.info(fmt.Sprintf("DELETE Completed. - K8s Type - %s Name - %s Namespace - %s CL %s deleted.", obj.ResourceType, obj.Name, obj.Namespace, mappedResource.CommonLabel))
+					m.info("DELETE completed", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace, "group", mappedResource.CommonLabel, "action", "Deleted")
 					return MapResult{
 						Action:         "Deleted",
 						Key:            namespaceKey,
@@ -1172,27 +1325,29 @@ func (m *Mapper) mapPodObj(obj ResourceEvent, store cache.Store) (MapResult, err
 
 func (m *Mapper) mapReplicaSetObj(obj ResourceEvent, store cache.Store) (MapResult, error) {
 	var replicaSet ext_v1beta1.ReplicaSet
-	var namespaceKeys []string
 
 	if obj.Event != nil {
-		replicaSet = *obj.Event.(*ext_v1beta1.ReplicaSet).DeepCopy()
-
-		keys := store.ListKeys()
-		for _, b64Key := range keys {
-			encodedKey, _ := base64.StdEncoding.DecodeString(b64Key)
-			key := fmt.Sprintf("%s", encodedKey)
-			if len(strings.Split(key, "$")) > 0 {
-				if strings.Split(key, "$")[0] == obj.Namespace {
-					namespaceKeys = append(namespaceKeys, key)
-				}
-			}
+		eventReplicaSet, ok := obj.Event.(*ext_v1beta1.ReplicaSet)
+		if !ok {
+			return MapResult{}, invalidEventError(obj)
+		}
+		replicaSet = *eventReplicaSet.DeepCopy()
+		if replicaSet.Spec.Selector == nil {
+			return MapResult{}, missingSelectorError(obj)
+		}
+
+		namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+		if err != nil {
+			return MapResult{}, err
 		}
 
 		for _, namespaceKey := range namespaceKeys {
 			metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 			metaIdentifier := MetaIdentifier{}
 
-			json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+			if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+				return MapResult{}, storeKeyError(namespaceKey, err)
+			}
 
 			//Try matching with Service
 			if metaIdentifier.ServicesIdentifier.MatchLabels != nil {
@@ -1210,7 +1365,10 @@ func (m *Mapper) mapReplicaSetObj(obj ResourceEvent, store cache.Store) (MapResu
 					if reflect.DeepEqual(rsMatchedLabels, svcID) {
 						//Service and pod matches. Add pod to this mapped resource
 						// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-						mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+						mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+						if err != nil {
+							return MapResult{}, err
+						}
 
 						for i, mappedReplicaSet := range mappedResource.Kube.ReplicaSets {
 							if mappedReplicaSet.Name == replicaSet.Name {
@@ -1252,7 +1410,10 @@ func (m *Mapper) mapReplicaSetObj(obj ResourceEvent, store cache.Store) (MapResu
 				if reflect.DeepEqual(rsMatchedLabels, depID) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return MapResult{}, err
+					}
 
 					for i, mappedReplicaSet := range mappedResource.Kube.ReplicaSets {
 						if mappedReplicaSet.Name == replicaSet.Name {
@@ -1284,7 +1445,10 @@ func (m *Mapper) mapReplicaSetObj(obj ResourceEvent, store cache.Store) (MapResu
 				if reflect.DeepEqual(replicaSet.Spec.Selector.MatchLabels, rsID.MatchLabels) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return MapResult{}, err
+					}
 
 					for i, mappedReplicaSet := range mappedResource.Kube.ReplicaSets {
 						if mappedReplicaSet.Name == replicaSet.Name {
@@ -1315,7 +1479,10 @@ func (m *Mapper) mapReplicaSetObj(obj ResourceEvent, store cache.Store) (MapResu
 				if reflect.DeepEqual(rsMatchedLabels, replicaSet.Spec.Selector.MatchLabels) {
 					//Service and deployment matches. Add service to this mapped resource
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return MapResult{}, err
+					}
 
 					for i, mappedReplicaSet := range mappedResource.Kube.ReplicaSets {
 						if mappedReplicaSet.Name == replicaSet.Name {
@@ -1364,17 +1531,11 @@ func (m *Mapper) mapReplicaSetObj(obj ResourceEvent, store cache.Store) (MapResu
 
 	//Handle Delete
 	if obj.EventType == "DELETED" {
-		m.info(fmt.Sprintf("DELETE received. - K8s Type - %s Name - %s Namespace - %s", obj.ResourceType, obj.Name, obj.Namespace))
-
-		keys := store.ListKeys()
-		for _, b64Key := range keys {
-			encodedKey, _ := base64.StdEncoding.DecodeString(b64Key)
-			key := fmt.Sprintf("%s", encodedKey)
-			if len(strings.Split(key, "$")) > 0 {
-				if strings.Split(key, "$")[0] == obj.Namespace {
-					namespaceKeys = append(namespaceKeys, key)
-				}
-			}
+		m.info("DELETE received", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace)
+
+		namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+		if err != nil {
+			return MapResult{}, err
 		}
 
 		var newRsSet []ext_v1beta1.ReplicaSet
@@ -1382,13 +1543,18 @@ func (m *Mapper) mapReplicaSetObj(obj ResourceEvent, store cache.Store) (MapResu
 			metaIdentifierString := strings.Split(namespaceKey, "$")[1]
 			metaIdentifier := MetaIdentifier{}
 
-			json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier)
+			if err := json.Unmarshal([]byte(metaIdentifierString), &metaIdentifier); err != nil {
+				return MapResult{}, storeKeyError(namespaceKey, err)
+			}
 
 			for _, rsChileSet := range metaIdentifier.ReplicaSetsIdentifier {
 				if rsChileSet.Name == obj.Name {
 					//Pod is being deleted.
 					// mappedResource, _ := getObjectFromStore(namespaceKey, store)
-					mappedResource, _ := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+					if err != nil {
+						return MapResult{}, err
+					}
 
 					newRsSet = nil
 					for _, mappedRs := range mappedResource.Kube.ReplicaSets {
@@ -1402,7 +1568,7 @@ func (m *Mapper) mapReplicaSetObj(obj ResourceEvent, store cache.Store) (MapResu
 						mappedResource.Kube.ReplicaSets = nil
 						mappedResource.Kube.ReplicaSets = newRsSet
 
-						m.info(fmt.Sprintf("DELETE Completed. - K8s Type - %s Name - %s Namespace - %s CL %s updated.", obj.ResourceType, obj.Name, obj.Namespace, mappedResource.CommonLabel))
+						m.info("DELETE completed", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace, "group", mappedResource.CommonLabel, "action", "Updated")
 						return MapResult{
 							Action:         "Updated",
 							Key:            namespaceKey,
@@ -1412,7 +1578,7 @@ func (m *Mapper) mapReplicaSetObj(obj ResourceEvent, store cache.Store) (MapResu
 						}, nil
 					}
 
-					m.info(fmt.Sprintf("DELETE Completed. - K8s Type - %s Name - %s Namespace - %s CL %s deleted.", obj.ResourceType, obj.Name, obj.Namespace, mappedResource.CommonLabel))
+					m.info("DELETE completed", "kind", obj.ResourceType, "name", obj.Name, "namespace", obj.Namespace, "group", mappedResource.CommonLabel, "action", "Deleted")
 					return MapResult{
 						Action:         "Deleted",
 						Key:            namespaceKey,
@@ -1429,3 +1595,207 @@ func (m *Mapper) mapReplicaSetObj(obj ResourceEvent, store cache.Store) (MapResu
 
 	return MapResult{}, nil
 }
+
+//splitMappedResourceResults returns map results for a mapped resource from which a member was just removed.
+//If the remaining members are still linked, a single update is returned. Otherwise first group replaces the existing key and rest are added as new groups.
+func splitMappedResourceResults(namespaceKey string, mappedResource MappedResource, message string) []MapResult {
+	groups := splitMappedResource(mappedResource)
+
+	if len(groups) < 2 {
+		return []MapResult{
+			MapResult{
+				Action:         "Updated",
+				Key:            namespaceKey,
+				IsMapped:       true,
+				MappedResource: mappedResource,
+				Message:        message,
+			},
+		}
+	}
+
+	var splitLabels []string
+	for _, group := range groups {
+		splitLabels = append(splitLabels, group.CommonLabel)
+	}
+
+	var mapResults []MapResult
+	for i, group := range groups {
+		if i == 0 {
+			mapResults = append(mapResults,
+				MapResult{
+					Action:         "Updated",
+					Key:            namespaceKey,
+					IsMapped:       true,
+					MappedResource: group,
+					Message:        fmt.Sprintf("%s. Common Label split into %s", message, strings.Join(splitLabels, ", ")),
+				},
+			)
+		} else {
+			mapResults = append(mapResults,
+				MapResult{
+					Action:         "Added",
+					IsMapped:       true,
+					MappedResource: group,
+					Message:        fmt.Sprintf("Common Label %s split from %s", group.CommonLabel, mappedResource.CommonLabel),
+				},
+			)
+		}
+	}
+
+	return mapResults
+}
+
+//splitMappedResource recomputes connectivity between members of a mapped resource and returns one mapped resource per connected set.
+//Group holding the current common label is returned first.
+func splitMappedResource(mappedResource MappedResource) []MappedResource {
+	kube := mappedResource.Kube
+	ingressCount := len(kube.Ingresses)
+	serviceCount := len(kube.Services)
+	deploymentCount := len(kube.Deployments)
+	rsCount := len(kube.ReplicaSets)
+
+	//Members are indexed in order ingresses, services, deployments, replica sets, pods.
+	serviceIndex := func(i int) int { return ingressCount + i }
+	deploymentIndex := func(i int) int { return ingressCount + serviceCount + i }
+	rsIndex := func(i int) int { return ingressCount + serviceCount + deploymentCount + i }
+	podIndex := func(i int) int { return ingressCount + serviceCount + deploymentCount + rsCount + i }
+
+	parent := make([]int, podIndex(len(kube.Pods)))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(a, b int) {
+		rootA, rootB := find(a), find(b)
+		if rootA < rootB {
+			parent[rootB] = rootA
+		} else if rootB < rootA {
+			parent[rootA] = rootB
+		}
+	}
+
+	for i, ingress := range kube.Ingresses {
+		for _, backendService := range ingressBackendServiceNames(ingress) {
+			for j, service := range kube.Services {
+				if service.Name == backendService {
+					union(i, serviceIndex(j))
+				}
+			}
+		}
+	}
+
+	for i, service := range kube.Services {
+		for j, deployment := range kube.Deployments {
+			if deployment.Spec.Selector != nil && isLabelSubset(service.Spec.Selector, deployment.Spec.Selector.MatchLabels) || isLabelSubset(service.Spec.Selector, deployment.Spec.Template.Labels) {
+				union(serviceIndex(i), deploymentIndex(j))
+			}
+		}
+		for j, replicaSet := range kube.ReplicaSets {
+			if replicaSet.Spec.Selector != nil && isLabelSubset(service.Spec.Selector, replicaSet.Spec.Selector.MatchLabels) {
+				union(serviceIndex(i), rsIndex(j))
+			}
+		}
+		for j, pod := range kube.Pods {
+			if isLabelSubset(service.Spec.Selector, pod.Labels) {
+				union(serviceIndex(i), podIndex(j))
+			}
+		}
+	}
+
+	//Services are linked to pods their endpoints reference too, whether their selector matches pods or not.
+	readiness := podReadiness(kube)
+	for i, service := range kube.Services {
+		for j, pod := range kube.Pods {
+			if _, ok := readiness[service.Name][pod.Name]; ok {
+				union(serviceIndex(i), podIndex(j))
+			}
+		}
+	}
+
+	for i, deployment := range kube.Deployments {
+		var deploymentSelector map[string]string
+		if deployment.Spec.Selector != nil {
+			deploymentSelector = deployment.Spec.Selector.MatchLabels
+		}
+		for j, replicaSet := range kube.ReplicaSets {
+			if isOwnedBy(replicaSet.OwnerReferences, deployment.Name) || replicaSet.Spec.Selector != nil && isLabelSubset(deploymentSelector, replicaSet.Spec.Selector.MatchLabels) {
+				union(deploymentIndex(i), rsIndex(j))
+			}
+		}
+		for j, pod := range kube.Pods {
+			if isLabelSubset(deploymentSelector, pod.Labels) {
+				union(deploymentIndex(i), podIndex(j))
+			}
+		}
+	}
+
+	for i, replicaSet := range kube.ReplicaSets {
+		var rsSelector map[string]string
+		if replicaSet.Spec.Selector != nil {
+			rsSelector = replicaSet.Spec.Selector.MatchLabels
+		}
+		for j, pod := range kube.Pods {
+			if isOwnedBy(pod.OwnerReferences, replicaSet.Name) || isLabelSubset(rsSelector, pod.Labels) {
+				union(rsIndex(i), podIndex(j))
+			}
+		}
+	}
+
+	var roots []int
+	groupsByRoot := map[int]*MappedResource{}
+	groupFor := func(i int) *MappedResource {
+		root := find(i)
+		if _, ok := groupsByRoot[root]; !ok {
+			groupsByRoot[root] = &MappedResource{
+				Namespace:   mappedResource.Namespace,
+				CurrentType: mappedResource.CurrentType,
+			}
+			roots = append(roots, root)
+		}
+		return groupsByRoot[root]
+	}
+
+	for i, ingress := range kube.Ingresses {
+		group := groupFor(i)
+		group.Kube.Ingresses = append(group.Kube.Ingresses, ingress)
+	}
+	for i, service := range kube.Services {
+		group := groupFor(serviceIndex(i))
+		group.Kube.Services = append(group.Kube.Services, service)
+	}
+	for i, deployment := range kube.Deployments {
+		group := groupFor(deploymentIndex(i))
+		group.Kube.Deployments = append(group.Kube.Deployments, deployment)
+	}
+	for i, replicaSet := range kube.ReplicaSets {
+		group := groupFor(rsIndex(i))
+		group.Kube.ReplicaSets = append(group.Kube.ReplicaSets, replicaSet)
+	}
+	for i, pod := range kube.Pods {
+		group := groupFor(podIndex(i))
+		group.Kube.Pods = append(group.Kube.Pods, pod)
+	}
+
+	var groups []MappedResource
+	isLabelKept := false
+	for _, root := range roots {
+		group := *groupsByRoot[root]
+		if !isLabelKept && hasMemberNamed(group, mappedResource.CommonLabel) {
+			isLabelKept = true
+			group.CommonLabel = mappedResource.CommonLabel
+			group.Kube.Events = mappedResource.Kube.Events
+			groups = append([]MappedResource{group}, groups...)
+		} else {
+			group.CommonLabel = defaultCommonLabel(group)
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
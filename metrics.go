@@ -0,0 +1,288 @@
+package kubemap
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	metricsNamespace = "kubemap"
+	workqueueName    = "kubemap"
+)
+
+//Metrics is a prometheus collector for mapping pipeline.
+//Register it with a prometheus registry and pass it to Mapper via MapOptions.Metrics.
+type Metrics struct {
+	eventsProcessed *prometheus.CounterVec
+	mappingLatency  *prometheus.HistogramVec
+	retriesDropped  *prometheus.CounterVec
+
+	workqueueDepth   *prometheus.GaugeVec
+	workqueueAdds    *prometheus.CounterVec
+	workqueueRetries *prometheus.CounterVec
+	workqueueLatency *prometheus.HistogramVec
+	workDuration     *prometheus.HistogramVec
+	unfinishedWork   *prometheus.GaugeVec
+	longestRunning   *prometheus.GaugeVec
+
+	//Store size is tracked as store is updated, so that scrapes do not read whole store.
+	storeGroups  prometheus.Gauge
+	storeObjects prometheus.Gauge
+}
+
+//NewMetrics creates collector for kubemap metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		eventsProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "events_processed_total",
+			Help:      "Number of resource events processed by kind, event type and result.",
+		}, []string{"kind", "event_type", "result"}),
+		mappingLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "mapping_duration_seconds",
+			Help:      "Time taken to map a resource event and update store, by kind.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"kind"}),
+		retriesDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "events_dropped_total",
+			Help:      "Number of resource events dropped from workqueue after too many retries, by kind.",
+		}, []string{"kind"}),
+
+		workqueueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "depth",
+			Help:      "Current depth of workqueue.",
+		}, []string{"name"}),
+		workqueueAdds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "adds_total",
+			Help:      "Number of adds handled by workqueue.",
+		}, []string{"name"}),
+		workqueueRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "retries_total",
+			Help:      "Number of retries handled by workqueue.",
+		}, []string{"name"}),
+		workqueueLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "queue_duration_seconds",
+			Help:      "How long an item stays in workqueue before being processed.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"name"}),
+		workDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "work_duration_seconds",
+			Help:      "How long processing an item from workqueue takes.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"name"}),
+		unfinishedWork: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "unfinished_work_seconds",
+			Help:      "Seconds of work in progress which has not been observed by work_duration.",
+		}, []string{"name"}),
+		longestRunning: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "longest_running_processor_seconds",
+			Help:      "Seconds for which the longest running processor of workqueue has been running.",
+		}, []string{"name"}),
+
+		storeGroups: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "store",
+			Name:      "groups",
+			Help:      "Number of mapped resources (common labels) in store.",
+		}),
+		storeObjects: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "store",
+			Name:      "objects",
+			Help:      "Number of k8s objects in mapped resources of store.",
+		}),
+	}
+}
+
+//Describe implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range m.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+//Collect implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range m.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.eventsProcessed,
+		m.mappingLatency,
+		m.retriesDropped,
+		m.workqueueDepth,
+		m.workqueueAdds,
+		m.workqueueRetries,
+		m.workqueueLatency,
+		m.workDuration,
+		m.unfinishedWork,
+		m.longestRunning,
+		m.storeGroups,
+		m.storeObjects,
+	}
+}
+
+//observeStore adds mapped resources already in store to store size metrics. Later changes are reported by storeUpdated.
+func (m *Metrics) observeStore(store cache.Store) {
+	if m == nil {
+		return
+	}
+
+	var mappedResources []MappedResource
+	for _, item := range store.List() {
+		if mappedResource, ok := item.(MappedResource); ok {
+			mappedResources = append(mappedResources, mappedResource)
+		}
+	}
+	m.storeUpdated(mappedResources, nil)
+}
+
+//storeUpdated adjusts store size metrics by mapped resources added to and removed from store.
+func (m *Metrics) storeUpdated(added []MappedResource, removed []MappedResource) {
+	if m == nil {
+		return
+	}
+
+	for _, mappedResource := range added {
+		m.storeGroups.Inc()
+		m.storeObjects.Add(float64(mappedResourceObjectCount(mappedResource)))
+	}
+	for _, mappedResource := range removed {
+		m.storeGroups.Dec()
+		m.storeObjects.Sub(float64(mappedResourceObjectCount(mappedResource)))
+	}
+}
+
+func (m *Metrics) eventProcessed(obj ResourceEvent, err error, start time.Time) {
+	if m == nil {
+		return
+	}
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.eventsProcessed.WithLabelValues(obj.ResourceType, obj.EventType, result).Inc()
+	m.mappingLatency.WithLabelValues(obj.ResourceType).Observe(time.Since(start).Seconds())
+}
+
+func (m *Metrics) eventDropped(obj interface{}) {
+	if m == nil {
+		return
+	}
+
+	kind := ""
+	if event, ok := obj.(ResourceEvent); ok {
+		kind = event.ResourceType
+	}
+	m.retriesDropped.WithLabelValues(kind).Inc()
+}
+
+func mappedResourceObjectCount(mappedResource MappedResource) int {
+	return len(mappedResource.Kube.Ingresses) + len(mappedResource.Kube.Services) + len(mappedResource.Kube.Deployments) + len(mappedResource.Kube.ReplicaSets) + len(mappedResource.Kube.Pods) +
+		len(mappedResource.Kube.ConfigMaps) + len(mappedResource.Kube.Secrets) +
+		len(mappedResource.Kube.PersistentVolumeClaims) + len(mappedResource.Kube.PersistentVolumes) +
+		len(mappedResource.Kube.Endpoints) + len(mappedResource.Kube.EndpointSlices) +
+		len(mappedResource.Kube.NetworkPolicies) + len(mappedResource.Kube.PodDisruptionBudgets) +
+		len(mappedResource.Kube.ServiceAccounts) + len(mappedResource.Kube.RoleBindings) + len(mappedResource.Kube.ClusterRoleBindings) +
+		len(mappedResource.Kube.Roles) + len(mappedResource.Kube.ClusterRoles) + len(mappedResource.Kube.Nodes)
+}
+
+//NewDepthMetric implements workqueue.MetricsProvider
+func (m *Metrics) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return m.workqueueDepth.WithLabelValues(name)
+}
+
+//NewAddsMetric implements workqueue.MetricsProvider
+func (m *Metrics) NewAddsMetric(name string) workqueue.CounterMetric {
+	return m.workqueueAdds.WithLabelValues(name)
+}
+
+//NewLatencyMetric implements workqueue.MetricsProvider
+func (m *Metrics) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return m.workqueueLatency.WithLabelValues(name)
+}
+
+//NewWorkDurationMetric implements workqueue.MetricsProvider
+func (m *Metrics) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return m.workDuration.WithLabelValues(name)
+}
+
+//NewUnfinishedWorkSecondsMetric implements workqueue.MetricsProvider
+func (m *Metrics) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return m.unfinishedWork.WithLabelValues(name)
+}
+
+//NewLongestRunningProcessorSecondsMetric implements workqueue.MetricsProvider
+func (m *Metrics) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return m.longestRunning.WithLabelValues(name)
+}
+
+//NewRetriesMetric implements workqueue.MetricsProvider
+func (m *Metrics) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return m.workqueueRetries.WithLabelValues(name)
+}
+
+//NewDeprecatedDepthMetric implements workqueue.MetricsProvider. Deprecated metrics are not exported.
+func (m *Metrics) NewDeprecatedDepthMetric(name string) workqueue.GaugeMetric {
+	return noopMetric{}
+}
+
+//NewDeprecatedAddsMetric implements workqueue.MetricsProvider. Deprecated metrics are not exported.
+func (m *Metrics) NewDeprecatedAddsMetric(name string) workqueue.CounterMetric {
+	return noopMetric{}
+}
+
+//NewDeprecatedLatencyMetric implements workqueue.MetricsProvider. Deprecated metrics are not exported.
+func (m *Metrics) NewDeprecatedLatencyMetric(name string) workqueue.SummaryMetric {
+	return noopMetric{}
+}
+
+//NewDeprecatedWorkDurationMetric implements workqueue.MetricsProvider. Deprecated metrics are not exported.
+func (m *Metrics) NewDeprecatedWorkDurationMetric(name string) workqueue.SummaryMetric {
+	return noopMetric{}
+}
+
+//NewDeprecatedUnfinishedWorkSecondsMetric implements workqueue.MetricsProvider. Deprecated metrics are not exported.
+func (m *Metrics) NewDeprecatedUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+//NewDeprecatedLongestRunningProcessorMicrosecondsMetric implements workqueue.MetricsProvider. Deprecated metrics are not exported.
+func (m *Metrics) NewDeprecatedLongestRunningProcessorMicrosecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+//NewDeprecatedRetriesMetric implements workqueue.MetricsProvider. Deprecated metrics are not exported.
+func (m *Metrics) NewDeprecatedRetriesMetric(name string) workqueue.CounterMetric {
+	return noopMetric{}
+}
+
+type noopMetric struct{}
+
+func (noopMetric) Inc()            {}
+func (noopMetric) Dec()            {}
+func (noopMetric) Set(float64)     {}
+func (noopMetric) Observe(float64) {}
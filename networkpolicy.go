@@ -0,0 +1,308 @@
+package kubemap
+
+import (
+	"fmt"
+	"sort"
+
+	core_v1 "k8s.io/api/core/v1"
+	networking_v1 "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+//Types of ReachabilityPeer
+const (
+	//ReachabilityPeerGroup is a group whose pods a policy peer selects.
+	ReachabilityPeerGroup = "group"
+	//ReachabilityPeerNamespace is every pod of a namespace a policy peer selects.
+	ReachabilityPeerNamespace = "namespace"
+	//ReachabilityPeerCIDR is an IP block of a policy peer.
+	ReachabilityPeerCIDR = "cidr"
+	//ReachabilityPeerAll is any source or destination, allowed by a rule without peers.
+	ReachabilityPeerAll = "all"
+)
+
+//namespaceNameLabel is set on every namespace to its name.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+//ReachabilityOptions controls Reachability.
+type ReachabilityOptions struct {
+	//NamespaceLabels are labels of namespaces, keyed by namespace name. Namespaces are not mapped, so namespaceSelector
+	//of policy peers only matches labels given here, and kubernetes.io/metadata.name label of namespaces of groups.
+	NamespaceLabels map[string]map[string]string
+}
+
+//ReachabilityPeer is a source or destination a network policy allows.
+type ReachabilityPeer struct {
+	Type        string   `json:"type"`
+	Namespace   string   `json:"namespace,omitempty"`
+	CommonLabel string   `json:"commonLabel,omitempty"`
+	CIDR        string   `json:"cidr,omitempty"`
+	Except      []string `json:"except,omitempty"`
+	//Ports are allowed ports like "TCP/8080". Empty allows all ports.
+	Ports []string `json:"ports,omitempty"`
+	//Policy is name of network policy allowing peer.
+	Policy string `json:"policy"`
+}
+
+//GroupReachability is traffic network policies allow to and from pods of a group.
+type GroupReachability struct {
+	Namespace   string   `json:"namespace"`
+	CommonLabel string   `json:"commonLabel"`
+	Policies    []string `json:"policies,omitempty"`
+	//IsIngressIsolated is true when a policy restricts ingress of group. Only Ingress peers may reach group then.
+	IsIngressIsolated bool `json:"isIngressIsolated"`
+	//IsEgressIsolated is true when a policy restricts egress of group. Group may only reach Egress peers then.
+	IsEgressIsolated bool               `json:"isEgressIsolated"`
+	Ingress          []ReachabilityPeer `json:"ingress,omitempty"`
+	Egress           []ReachabilityPeer `json:"egress,omitempty"`
+}
+
+//ReachabilityGraph is reachability of groups holding workloads, ordered by namespace and common label.
+type ReachabilityGraph struct {
+	Groups []GroupReachability `json:"groups,omitempty"`
+}
+
+//Group returns reachability of group with given namespace and common label.
+func (g ReachabilityGraph) Group(namespace string, commonLabel string) (GroupReachability, bool) {
+	for _, group := range g.Groups {
+		if group.Namespace == namespace && group.CommonLabel == commonLabel {
+			return group, true
+		}
+	}
+
+	return GroupReachability{}, false
+}
+
+//CanReach returns true when egress of group from and ingress of group to both allow traffic between them, on any port.
+func (g ReachabilityGraph) CanReach(fromNamespace string, fromCommonLabel string, toNamespace string, toCommonLabel string) bool {
+	from, ok := g.Group(fromNamespace, fromCommonLabel)
+	if !ok {
+		return false
+	}
+	to, ok := g.Group(toNamespace, toCommonLabel)
+	if !ok {
+		return false
+	}
+
+	return (!from.IsEgressIsolated || allowsGroup(from.Egress, to)) && (!to.IsIngressIsolated || allowsGroup(to.Ingress, from))
+}
+
+func allowsGroup(peers []ReachabilityPeer, group GroupReachability) bool {
+	for _, peer := range peers {
+		switch peer.Type {
+		case ReachabilityPeerAll:
+			return true
+		case ReachabilityPeerNamespace:
+			if peer.Namespace == group.Namespace {
+				return true
+			}
+		case ReachabilityPeerGroup:
+			if peer.Namespace == group.Namespace && peer.CommonLabel == group.CommonLabel {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//Reachability returns traffic network policies attached to groups allow, in terms of other groups, namespaces and CIDRs.
+//Groups without workloads are not part of graph, as policies do not select them.
+func Reachability(mappedResources MappedResources, options ReachabilityOptions) ReachabilityGraph {
+	var groups []MappedResource
+	namespaceLabels := map[string]labels.Set{}
+	for _, mappedResource := range mappedResources.MappedResource {
+		if hasWorkloads(mappedResource) {
+			groups = append(groups, mappedResource)
+			namespaceLabels[mappedResource.Namespace] = labels.Set{namespaceNameLabel: mappedResource.Namespace}
+		}
+	}
+	for namespace, namespaceLabel := range options.NamespaceLabels {
+		namespaceLabels[namespace] = labels.Merge(namespaceLabel, labels.Set{namespaceNameLabel: namespace})
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Namespace != groups[j].Namespace {
+			return groups[i].Namespace < groups[j].Namespace
+		}
+		return groups[i].CommonLabel < groups[j].CommonLabel
+	})
+
+	var namespaces []string
+	for namespace := range namespaceLabels {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	resolver := reachabilityResolver{groups: groups, namespaces: namespaces, namespaceLabels: namespaceLabels}
+
+	var graph ReachabilityGraph
+	for _, mappedResource := range groups {
+		group := GroupReachability{Namespace: mappedResource.Namespace, CommonLabel: mappedResource.CommonLabel}
+		for _, networkPolicy := range mappedResource.Kube.NetworkPolicies {
+			group.Policies = append(group.Policies, networkPolicy.Name)
+
+			isIngress, isEgress := policyTypes(networkPolicy)
+			if isIngress {
+				group.IsIngressIsolated = true
+				for _, rule := range networkPolicy.Spec.Ingress {
+					group.Ingress = append(group.Ingress, resolver.peers(networkPolicy, rule.From, rule.Ports)...)
+				}
+			}
+			if isEgress {
+				group.IsEgressIsolated = true
+				for _, rule := range networkPolicy.Spec.Egress {
+					group.Egress = append(group.Egress, resolver.peers(networkPolicy, rule.To, rule.Ports)...)
+				}
+			}
+		}
+		graph.Groups = append(graph.Groups, group)
+	}
+
+	return graph
+}
+
+//Reachability returns traffic network policies allow between groups in store.
+func (m *Mapper) Reachability(options ReachabilityOptions) ReachabilityGraph {
+	return Reachability(getAllMappedResources(m.store), options)
+}
+
+//policyTypes returns whether policy restricts ingress and egress. Policies without policyTypes always restrict ingress,
+//and restrict egress when they have egress rules.
+func policyTypes(networkPolicy networking_v1.NetworkPolicy) (bool, bool) {
+	if len(networkPolicy.Spec.PolicyTypes) == 0 {
+		return true, len(networkPolicy.Spec.Egress) > 0
+	}
+
+	isIngress, isEgress := false, false
+	for _, policyType := range networkPolicy.Spec.PolicyTypes {
+		switch policyType {
+		case networking_v1.PolicyTypeIngress:
+			isIngress = true
+		case networking_v1.PolicyTypeEgress:
+			isEgress = true
+		}
+	}
+
+	return isIngress, isEgress
+}
+
+type reachabilityResolver struct {
+	groups          []MappedResource
+	namespaces      []string
+	namespaceLabels map[string]labels.Set
+}
+
+//peers returns peers of a rule of network policy. Rule without peers allows all sources or destinations.
+func (r reachabilityResolver) peers(networkPolicy networking_v1.NetworkPolicy, policyPeers []networking_v1.NetworkPolicyPeer, policyPorts []networking_v1.NetworkPolicyPort) []ReachabilityPeer {
+	ports := formatPolicyPorts(policyPorts)
+	if len(policyPeers) == 0 {
+		return []ReachabilityPeer{{Type: ReachabilityPeerAll, Ports: ports, Policy: networkPolicy.Name}}
+	}
+
+	var peers []ReachabilityPeer
+	for _, policyPeer := range policyPeers {
+		if policyPeer.IPBlock != nil {
+			peers = append(peers, ReachabilityPeer{
+				Type:   ReachabilityPeerCIDR,
+				CIDR:   policyPeer.IPBlock.CIDR,
+				Except: policyPeer.IPBlock.Except,
+				Ports:  ports,
+				Policy: networkPolicy.Name,
+			})
+			continue
+		}
+
+		namespaces := []string{networkPolicy.Namespace}
+		if policyPeer.NamespaceSelector != nil {
+			namespaces = r.selectNamespaces(policyPeer.NamespaceSelector)
+		}
+
+		//Namespace selector alone selects every pod of namespaces
+		if policyPeer.PodSelector == nil {
+			for _, namespace := range namespaces {
+				peers = append(peers, ReachabilityPeer{Type: ReachabilityPeerNamespace, Namespace: namespace, Ports: ports, Policy: networkPolicy.Name})
+			}
+			continue
+		}
+
+		selected := map[string]bool{}
+		for _, namespace := range namespaces {
+			selected[namespace] = true
+		}
+		for _, group := range r.groups {
+			if selected[group.Namespace] && selectsGroupPods(group, policyPeer.PodSelector) {
+				peers = append(peers, ReachabilityPeer{
+					Type:        ReachabilityPeerGroup,
+					Namespace:   group.Namespace,
+					CommonLabel: group.CommonLabel,
+					Ports:       ports,
+					Policy:      networkPolicy.Name,
+				})
+			}
+		}
+	}
+
+	return peers
+}
+
+//selectNamespaces returns known namespaces matching selector.
+func (r reachabilityResolver) selectNamespaces(labelSelector *meta_v1.LabelSelector) []string {
+	selector, err := meta_v1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil
+	}
+
+	var namespaces []string
+	for _, namespace := range r.namespaces {
+		if selector.Matches(r.namespaceLabels[namespace]) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	return namespaces
+}
+
+//formatPolicyPorts returns ports like "TCP/8080", or "TCP" when port is not set.
+func formatPolicyPorts(policyPorts []networking_v1.NetworkPolicyPort) []string {
+	var ports []string
+	for _, policyPort := range policyPorts {
+		protocol := core_v1.ProtocolTCP
+		if policyPort.Protocol != nil {
+			protocol = *policyPort.Protocol
+		}
+		if policyPort.Port == nil {
+			ports = append(ports, string(protocol))
+			continue
+		}
+		ports = append(ports, fmt.Sprintf("%s/%s", protocol, policyPort.Port.String()))
+	}
+
+	return ports
+}
+
+//selectsGroupPods returns true when selector matches labels of pods of group, or of pod templates of its workloads.
+func selectsGroupPods(mappedResource MappedResource, labelSelector *meta_v1.LabelSelector) bool {
+	selector, err := meta_v1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return false
+	}
+
+	for _, pod := range mappedResource.Kube.Pods {
+		if selector.Matches(labels.Set(pod.Labels)) {
+			return true
+		}
+	}
+	for _, deployment := range mappedResource.Kube.Deployments {
+		if selector.Matches(labels.Set(deployment.Spec.Template.Labels)) {
+			return true
+		}
+	}
+	for _, replicaSet := range mappedResource.Kube.ReplicaSets {
+		if selector.Matches(labels.Set(replicaSet.Spec.Template.Labels)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,193 @@
+package kubemap
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+//parallelMap maps resources using one worker per namespace partition.
+//Mapped resources never span namespaces, so workers map their partitions in Mapper's store side by side, each under lock of its namespaces.
+//Cluster wide objects are attached to groups of any namespace. They are mapped once, after all partitions.
+func (m *Mapper) parallelMap(ctx context.Context, resources KubeResources, workers int) (MappedResources, error) {
+	partitions := partitionByNamespace(resources, workers)
+	errs := make([]error, len(partitions))
+
+	var wg sync.WaitGroup
+	for i, partition := range partitions {
+		wg.Add(1)
+		go func(i int, partition KubeResources) {
+			defer wg.Done()
+			errs[i] = m.mapPartition(ctx, partition)
+		}(i, partition)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return getAllMappedResources(m.store), err
+	}
+
+	clusterWide := KubeResources{
+		ClusterRoleBindings: resources.ClusterRoleBindings,
+		ClusterRoles:        resources.ClusterRoles,
+		Nodes:               resources.Nodes,
+	}
+	if err := m.mapPartition(ctx, clusterWide); err != nil {
+		return getAllMappedResources(m.store), err
+	}
+
+	return getAllMappedResources(m.store), nil
+}
+
+//mapPartition maps resources in Mapper's store using a queue of their own.
+func (m *Mapper) mapPartition(ctx context.Context, resources KubeResources) error {
+	queue := newMapQueue(m.metrics)
+	if err := addResourcesForMapping(resources, queue); err != nil {
+		queue.ShutDown()
+		return err
+	}
+
+	_, err := m.runMap(ctx, queue, m.store)
+	return err
+}
+
+//partitionByNamespace splits resources in at most given number of partitions.
+//All resources of a namespace land in same partition and keep their relative order. Cluster wide objects are left out.
+func partitionByNamespace(resources KubeResources, partitions int) []KubeResources {
+	namespaceSet := map[string]bool{}
+	for _, ingress := range resources.Ingresses {
+		namespaceSet[ingress.Namespace] = true
+	}
+	for _, service := range resources.Services {
+		namespaceSet[service.Namespace] = true
+	}
+	for _, deployment := range resources.Deployments {
+		namespaceSet[deployment.Namespace] = true
+	}
+	for _, replicaSet := range resources.ReplicaSets {
+		namespaceSet[replicaSet.Namespace] = true
+	}
+	for _, pod := range resources.Pods {
+		namespaceSet[pod.Namespace] = true
+	}
+	for _, configMap := range resources.ConfigMaps {
+		namespaceSet[configMap.Namespace] = true
+	}
+	for _, secret := range resources.Secrets {
+		namespaceSet[secret.Namespace] = true
+	}
+	for _, claim := range resources.PersistentVolumeClaims {
+		namespaceSet[claim.Namespace] = true
+	}
+	for _, volume := range resources.PersistentVolumes {
+		namespaceSet[persistentVolumeNamespace(volume)] = true
+	}
+	for _, endpoints := range resources.Endpoints {
+		namespaceSet[endpoints.Namespace] = true
+	}
+	for _, endpointSlice := range resources.EndpointSlices {
+		namespaceSet[endpointSlice.Namespace] = true
+	}
+	for _, networkPolicy := range resources.NetworkPolicies {
+		namespaceSet[networkPolicy.Namespace] = true
+	}
+	for _, budget := range resources.PodDisruptionBudgets {
+		namespaceSet[budget.Namespace] = true
+	}
+	for _, serviceAccount := range resources.ServiceAccounts {
+		namespaceSet[serviceAccount.Namespace] = true
+	}
+	for _, roleBinding := range resources.RoleBindings {
+		namespaceSet[roleBinding.Namespace] = true
+	}
+	for _, role := range resources.Roles {
+		namespaceSet[role.Namespace] = true
+	}
+
+	var namespaces []string
+	for namespace := range namespaceSet {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	if partitions > len(namespaces) {
+		partitions = len(namespaces)
+	}
+	if partitions < 1 {
+		partitions = 1
+	}
+
+	namespacePartition := map[string]int{}
+	for i, namespace := range namespaces {
+		namespacePartition[namespace] = i % partitions
+	}
+
+	result := make([]KubeResources, partitions)
+	for _, ingress := range resources.Ingresses {
+		i := namespacePartition[ingress.Namespace]
+		result[i].Ingresses = append(result[i].Ingresses, ingress)
+	}
+	for _, service := range resources.Services {
+		i := namespacePartition[service.Namespace]
+		result[i].Services = append(result[i].Services, service)
+	}
+	for _, deployment := range resources.Deployments {
+		i := namespacePartition[deployment.Namespace]
+		result[i].Deployments = append(result[i].Deployments, deployment)
+	}
+	for _, replicaSet := range resources.ReplicaSets {
+		i := namespacePartition[replicaSet.Namespace]
+		result[i].ReplicaSets = append(result[i].ReplicaSets, replicaSet)
+	}
+	for _, pod := range resources.Pods {
+		i := namespacePartition[pod.Namespace]
+		result[i].Pods = append(result[i].Pods, pod)
+	}
+	for _, configMap := range resources.ConfigMaps {
+		i := namespacePartition[configMap.Namespace]
+		result[i].ConfigMaps = append(result[i].ConfigMaps, configMap)
+	}
+	for _, secret := range resources.Secrets {
+		i := namespacePartition[secret.Namespace]
+		result[i].Secrets = append(result[i].Secrets, secret)
+	}
+	for _, claim := range resources.PersistentVolumeClaims {
+		i := namespacePartition[claim.Namespace]
+		result[i].PersistentVolumeClaims = append(result[i].PersistentVolumeClaims, claim)
+	}
+	//Cluster scoped volumes go to partition of their claim
+	for _, volume := range resources.PersistentVolumes {
+		i := namespacePartition[persistentVolumeNamespace(volume)]
+		result[i].PersistentVolumes = append(result[i].PersistentVolumes, volume)
+	}
+	for _, endpoints := range resources.Endpoints {
+		i := namespacePartition[endpoints.Namespace]
+		result[i].Endpoints = append(result[i].Endpoints, endpoints)
+	}
+	for _, endpointSlice := range resources.EndpointSlices {
+		i := namespacePartition[endpointSlice.Namespace]
+		result[i].EndpointSlices = append(result[i].EndpointSlices, endpointSlice)
+	}
+	for _, networkPolicy := range resources.NetworkPolicies {
+		i := namespacePartition[networkPolicy.Namespace]
+		result[i].NetworkPolicies = append(result[i].NetworkPolicies, networkPolicy)
+	}
+	for _, budget := range resources.PodDisruptionBudgets {
+		i := namespacePartition[budget.Namespace]
+		result[i].PodDisruptionBudgets = append(result[i].PodDisruptionBudgets, budget)
+	}
+	for _, serviceAccount := range resources.ServiceAccounts {
+		i := namespacePartition[serviceAccount.Namespace]
+		result[i].ServiceAccounts = append(result[i].ServiceAccounts, serviceAccount)
+	}
+	for _, roleBinding := range resources.RoleBindings {
+		i := namespacePartition[roleBinding.Namespace]
+		result[i].RoleBindings = append(result[i].RoleBindings, roleBinding)
+	}
+	for _, role := range resources.Roles {
+		i := namespacePartition[role.Namespace]
+		result[i].Roles = append(result[i].Roles, role)
+	}
+	return result
+}
@@ -0,0 +1,472 @@
+package kubemap
+
+import (
+	"fmt"
+	"strings"
+
+	apps_v1beta2 "k8s.io/api/apps/v1beta2"
+	core_v1 "k8s.io/api/core/v1"
+	ext_v1beta1 "k8s.io/api/extensions/v1beta1"
+	networking_v1 "k8s.io/api/networking/v1"
+	policy_v1beta1 "k8s.io/api/policy/v1beta1"
+	rbac_v1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+//Projection profiles of ProjectionOptions.Profile
+const (
+	//ProjectionFull keeps objects as they are.
+	ProjectionFull = "full"
+	//ProjectionSummary drops managed fields, last applied configuration annotation and container statuses.
+	ProjectionSummary = "summary"
+	//ProjectionIdentity keeps only fields which identify objects and link them together.
+	ProjectionIdentity = "identity-only"
+)
+
+//ProjectionOptions shrinks k8s objects of mapped resources before they are written to store.
+//Field paths are dot separated, like "status.containerStatuses". Map keys containing dots are put in brackets,
+//like "metadata.annotations[kubectl.kubernetes.io/last-applied-configuration]". Paths through lists apply to every element.
+//Fields used for mapping, like names, labels, owner references and selectors, are always kept.
+type ProjectionOptions struct {
+	//Profile is ProjectionFull (default), ProjectionSummary or ProjectionIdentity.
+	Profile string
+	//Include keeps only given field paths, keyed by kind like "pod" or "deployment".
+	Include map[string][]string
+	//Exclude drops given field paths, keyed by kind like "pod" or "deployment".
+	Exclude map[string][]string
+}
+
+var projectionKinds = []string{"ingress", "service", "deployment", "replicaset", "pod", "configmap", "secret", "persistentvolumeclaim", "persistentvolume", "endpoints", "endpointslice", "networkpolicy", "poddisruptionbudget", "serviceaccount", "rolebinding", "clusterrolebinding", "role", "clusterrole", "node"}
+
+//projectionRequiredPaths are needed to map objects and build store keys.
+var projectionRequiredPaths = map[string][]string{
+	"ingress":    {"spec.backend", "spec.rules", "spec.tls"},
+	"service":    {"spec.selector", "spec.type", "spec.externalName", "spec.ports"},
+	"deployment": append([]string{"spec.selector", "spec.template.metadata.labels"}, podSpecReferencePaths("spec.template.spec")...),
+	"replicaset": append([]string{"spec.selector", "spec.template.metadata.labels"}, podSpecReferencePaths("spec.template.spec")...),
+	"pod":        append([]string{"spec.nodeName"}, podSpecReferencePaths("spec")...),
+	"configmap":  {},
+	"secret":     {},
+
+	"persistentvolumeclaim": {"spec.volumeName", "spec.storageClassName"},
+	"persistentvolume":      {"spec.claimRef", "spec.storageClassName"},
+	"endpoints":             {"subsets"},
+	"endpointslice":         {"endpoints"},
+	"networkpolicy":         {"spec"},
+	"poddisruptionbudget":   {"spec", "status"},
+	"serviceaccount":        {},
+	"rolebinding":           {"subjects", "roleRef"},
+	"clusterrolebinding":    {"subjects", "roleRef"},
+	"role":                  {"rules"},
+	"clusterrole":           {"rules"},
+	"node":                  {},
+}
+
+var projectionCommonRequiredPaths = []string{"apiVersion", "kind", "metadata.name", "metadata.namespace", "metadata.labels", "metadata.ownerReferences"}
+
+var projectionSummaryExcludePaths = []string{
+	"metadata.managedFields",
+	"metadata.annotations[kubectl.kubernetes.io/last-applied-configuration]",
+	"status.containerStatuses",
+	"status.initContainerStatuses",
+}
+
+var projectionIdentityIncludePaths = []string{"metadata.uid"}
+
+//podSpecReferencePaths returns paths of pod spec fields which reference configmaps, secrets & service accounts,
+//and container ports service target ports are checked against.
+func podSpecReferencePaths(path string) []string {
+	var paths []string
+	for _, field := range []string{"volumes", "imagePullSecrets", "containers.env", "containers.envFrom", "containers.ports", "initContainers.env", "initContainers.envFrom", "serviceAccountName", "serviceAccount"} {
+		paths = append(paths, path+"."+field)
+	}
+
+	return paths
+}
+
+//projection is compiled form of ProjectionOptions. Zero value keeps objects as they are.
+type projection struct {
+	include  map[string][][]string
+	exclude  map[string][][]string
+	required map[string][][]string
+}
+
+func newProjection(options ProjectionOptions) (projection, error) {
+	p := projection{}
+
+	var include, exclude []string
+	switch options.Profile {
+	case "", ProjectionFull:
+	case ProjectionSummary:
+		exclude = projectionSummaryExcludePaths
+	case ProjectionIdentity:
+		include = projectionIdentityIncludePaths
+	default:
+		return projection{}, fmt.Errorf("Cannot instantiate Mapper. Invalid projection profile %s provided. Accepted values are '%s', '%s' & '%s'", options.Profile, ProjectionFull, ProjectionSummary, ProjectionIdentity)
+	}
+
+	for _, kindPaths := range []map[string][]string{options.Include, options.Exclude} {
+		for kind := range kindPaths {
+			if _, ok := projectionRequiredPaths[kind]; !ok {
+				return projection{}, fmt.Errorf("Cannot instantiate Mapper. Invalid projection kind %s provided. Accepted values are '%s'", kind, strings.Join(projectionKinds, "', '"))
+			}
+		}
+	}
+
+	if len(include) == 0 && len(exclude) == 0 && len(options.Include) == 0 && len(options.Exclude) == 0 {
+		return p, nil
+	}
+
+	p.include = map[string][][]string{}
+	p.exclude = map[string][][]string{}
+	p.required = map[string][][]string{}
+	for _, kind := range projectionKinds {
+		kindInclude := append(append([]string{}, include...), options.Include[kind]...)
+		kindExclude := append(append([]string{}, exclude...), options.Exclude[kind]...)
+		kindRequired := append(append([]string{}, projectionCommonRequiredPaths...), projectionRequiredPaths[kind]...)
+
+		for _, paths := range []struct {
+			from []string
+			to   map[string][][]string
+		}{{kindInclude, p.include}, {kindExclude, p.exclude}, {kindRequired, p.required}} {
+			for _, path := range paths.from {
+				segments, err := parseFieldPath(path)
+				if err != nil {
+					return projection{}, err
+				}
+				paths.to[kind] = append(paths.to[kind], segments)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+//enabled returns false when objects are kept as they are.
+func (p projection) enabled() bool {
+	return p.required != nil
+}
+
+//applyResults projects mapped resources of results not yet written to store.
+func (p projection) applyResults(results []MapResult) error {
+	for i := range results {
+		if results[i].IsStoreUpdated {
+			continue
+		}
+		mappedResource, err := p.apply(results[i].MappedResource)
+		if err != nil {
+			return err
+		}
+		results[i].MappedResource = mappedResource
+	}
+
+	return nil
+}
+
+//apply returns mapped resource with projected objects. Objects of given mapped resource are not modified.
+func (p projection) apply(mappedResource MappedResource) (MappedResource, error) {
+	if !p.enabled() {
+		return mappedResource, nil
+	}
+
+	kube := mappedResource.Kube
+	mappedResource.Kube = Kube{Events: kube.Events}
+
+	for _, ingress := range kube.Ingresses {
+		var projected ext_v1beta1.Ingress
+		if err := p.project("ingress", &ingress, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.Ingresses = append(mappedResource.Kube.Ingresses, projected)
+	}
+	for _, service := range kube.Services {
+		var projected core_v1.Service
+		if err := p.project("service", &service, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.Services = append(mappedResource.Kube.Services, projected)
+	}
+	for _, deployment := range kube.Deployments {
+		var projected apps_v1beta2.Deployment
+		if err := p.project("deployment", &deployment, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.Deployments = append(mappedResource.Kube.Deployments, projected)
+	}
+	for _, replicaSet := range kube.ReplicaSets {
+		var projected ext_v1beta1.ReplicaSet
+		if err := p.project("replicaset", &replicaSet, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.ReplicaSets = append(mappedResource.Kube.ReplicaSets, projected)
+	}
+	for _, pod := range kube.Pods {
+		var projected core_v1.Pod
+		if err := p.project("pod", &pod, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.Pods = append(mappedResource.Kube.Pods, projected)
+	}
+	for _, configMap := range kube.ConfigMaps {
+		var projected core_v1.ConfigMap
+		if err := p.project("configmap", &configMap, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.ConfigMaps = append(mappedResource.Kube.ConfigMaps, projected)
+	}
+	for _, secret := range kube.Secrets {
+		var projected core_v1.Secret
+		if err := p.project("secret", &secret, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.Secrets = append(mappedResource.Kube.Secrets, projected)
+	}
+	for _, claim := range kube.PersistentVolumeClaims {
+		var projected core_v1.PersistentVolumeClaim
+		if err := p.project("persistentvolumeclaim", &claim, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.PersistentVolumeClaims = append(mappedResource.Kube.PersistentVolumeClaims, projected)
+	}
+	for _, volume := range kube.PersistentVolumes {
+		var projected core_v1.PersistentVolume
+		if err := p.project("persistentvolume", &volume, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.PersistentVolumes = append(mappedResource.Kube.PersistentVolumes, projected)
+	}
+	for _, endpoints := range kube.Endpoints {
+		var projected core_v1.Endpoints
+		if err := p.project("endpoints", &endpoints, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.Endpoints = append(mappedResource.Kube.Endpoints, projected)
+	}
+	for _, endpointSlice := range kube.EndpointSlices {
+		var projected EndpointSlice
+		if err := p.project("endpointslice", &endpointSlice, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.EndpointSlices = append(mappedResource.Kube.EndpointSlices, projected)
+	}
+	for _, networkPolicy := range kube.NetworkPolicies {
+		var projected networking_v1.NetworkPolicy
+		if err := p.project("networkpolicy", &networkPolicy, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.NetworkPolicies = append(mappedResource.Kube.NetworkPolicies, projected)
+	}
+	for _, budget := range kube.PodDisruptionBudgets {
+		var projected policy_v1beta1.PodDisruptionBudget
+		if err := p.project("poddisruptionbudget", &budget, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.PodDisruptionBudgets = append(mappedResource.Kube.PodDisruptionBudgets, projected)
+	}
+	for _, serviceAccount := range kube.ServiceAccounts {
+		var projected core_v1.ServiceAccount
+		if err := p.project("serviceaccount", &serviceAccount, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.ServiceAccounts = append(mappedResource.Kube.ServiceAccounts, projected)
+	}
+	for _, roleBinding := range kube.RoleBindings {
+		var projected rbac_v1.RoleBinding
+		if err := p.project("rolebinding", &roleBinding, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.RoleBindings = append(mappedResource.Kube.RoleBindings, projected)
+	}
+	for _, clusterRoleBinding := range kube.ClusterRoleBindings {
+		var projected rbac_v1.ClusterRoleBinding
+		if err := p.project("clusterrolebinding", &clusterRoleBinding, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.ClusterRoleBindings = append(mappedResource.Kube.ClusterRoleBindings, projected)
+	}
+	for _, role := range kube.Roles {
+		var projected rbac_v1.Role
+		if err := p.project("role", &role, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.Roles = append(mappedResource.Kube.Roles, projected)
+	}
+	for _, clusterRole := range kube.ClusterRoles {
+		var projected rbac_v1.ClusterRole
+		if err := p.project("clusterrole", &clusterRole, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.ClusterRoles = append(mappedResource.Kube.ClusterRoles, projected)
+	}
+	for _, node := range kube.Nodes {
+		var projected core_v1.Node
+		if err := p.project("node", &node, &projected); err != nil {
+			return MappedResource{}, err
+		}
+		mappedResource.Kube.Nodes = append(mappedResource.Kube.Nodes, projected)
+	}
+
+	return mappedResource, nil
+}
+
+//project writes obj with include, exclude and required field paths of kind applied to projected.
+func (p projection) project(kind string, obj interface{}, projected interface{}) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return fmt.Errorf("Cannot project %s - %v", kind, err)
+	}
+
+	fields := content
+	if len(p.include[kind]) > 0 {
+		fields = map[string]interface{}{}
+		for _, segments := range p.include[kind] {
+			copyFieldPath(content, fields, segments)
+		}
+	} else {
+		//Exclude removes fields in place. Keep original to restore required fields.
+		fields = runtime.DeepCopyJSON(content)
+	}
+
+	for _, segments := range p.exclude[kind] {
+		removeFieldPath(fields, segments)
+	}
+	for _, segments := range p.required[kind] {
+		copyFieldPath(content, fields, segments)
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(fields, projected); err != nil {
+		return fmt.Errorf("Cannot project %s - %v", kind, err)
+	}
+
+	return nil
+}
+
+//parseFieldPath splits path like "metadata.annotations[example.com/key]" to segments.
+func parseFieldPath(path string) ([]string, error) {
+	var segments []string
+	var segment strings.Builder
+
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '.':
+			if segment.Len() > 0 {
+				segments = append(segments, segment.String())
+				segment.Reset()
+			}
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("Cannot instantiate Mapper. Invalid projection field path %s provided. Missing ']'", path)
+			}
+			if segment.Len() > 0 {
+				segments = append(segments, segment.String())
+				segment.Reset()
+			}
+			segments = append(segments, path[i+1:i+end])
+			i += end
+		default:
+			segment.WriteByte(path[i])
+		}
+	}
+	if segment.Len() > 0 {
+		segments = append(segments, segment.String())
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("Cannot instantiate Mapper. Empty projection field path provided")
+	}
+
+	return segments, nil
+}
+
+//copyFieldPath copies field at path from src to dst, creating parent fields in dst.
+func copyFieldPath(src map[string]interface{}, dst map[string]interface{}, segments []string) {
+	value, ok := src[segments[0]]
+	if !ok {
+		return
+	}
+
+	if len(segments) == 1 {
+		dst[segments[0]] = value
+		return
+	}
+
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		dstValue, ok := dst[segments[0]].(map[string]interface{})
+		if !ok {
+			dstValue = map[string]interface{}{}
+			dst[segments[0]] = dstValue
+		}
+		copyFieldPath(typedValue, dstValue, segments[1:])
+	case []interface{}:
+		if !hasFieldPath(typedValue, segments[1:]) {
+			return
+		}
+		dstValue, ok := dst[segments[0]].([]interface{})
+		if !ok || len(dstValue) != len(typedValue) {
+			dstValue = make([]interface{}, len(typedValue))
+			dst[segments[0]] = dstValue
+		}
+		for i, element := range typedValue {
+			srcElement, ok := element.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			dstElement, ok := dstValue[i].(map[string]interface{})
+			if !ok {
+				dstElement = map[string]interface{}{}
+				dstValue[i] = dstElement
+			}
+			copyFieldPath(srcElement, dstElement, segments[1:])
+		}
+	}
+}
+
+//hasFieldPath returns true when field at path is set in any element of list.
+func hasFieldPath(list []interface{}, segments []string) bool {
+	for _, element := range list {
+		elementValue, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, ok := elementValue[segments[0]]
+		if !ok {
+			continue
+		}
+		if len(segments) == 1 {
+			return true
+		}
+		switch typedValue := value.(type) {
+		case map[string]interface{}:
+			if hasFieldPath([]interface{}{typedValue}, segments[1:]) {
+				return true
+			}
+		case []interface{}:
+			if hasFieldPath(typedValue, segments[1:]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//removeFieldPath removes field at path from obj.
+func removeFieldPath(obj map[string]interface{}, segments []string) {
+	if len(segments) == 1 {
+		delete(obj, segments[0])
+		return
+	}
+
+	switch typedValue := obj[segments[0]].(type) {
+	case map[string]interface{}:
+		removeFieldPath(typedValue, segments[1:])
+	case []interface{}:
+		for _, element := range typedValue {
+			if elementValue, ok := element.(map[string]interface{}); ok {
+				removeFieldPath(elementValue, segments[1:])
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package kubemap
+
+import (
+	"fmt"
+	"sort"
+
+	rbac_v1 "k8s.io/api/rbac/v1"
+)
+
+//defaultServiceAccount runs pods which do not set a service account.
+const defaultServiceAccount = "default"
+
+//Permission is verbs a service account of group is granted on a resource, or on a non-resource URL.
+type Permission struct {
+	ServiceAccount string `json:"serviceAccount"`
+	//Namespace is namespace permission applies in. It is empty for permissions granted by ClusterRoleBindings, which apply in all namespaces.
+	Namespace      string   `json:"namespace,omitempty"`
+	APIGroup       string   `json:"apiGroup,omitempty"`
+	Resource       string   `json:"resource,omitempty"`
+	ResourceName   string   `json:"resourceName,omitempty"`
+	NonResourceURL string   `json:"nonResourceURL,omitempty"`
+	Verbs          []string `json:"verbs"`
+}
+
+//Permissions returns effective permissions of service accounts of group, flattened to one entry per resource.
+//Rules of Roles and ClusterRoles bound to them through RoleBindings and ClusterRoleBindings of group are merged.
+//RoleBindings in other namespaces than group's are not mapped, so permissions they grant are not part of result.
+func Permissions(mappedResource MappedResource) []Permission {
+	roles := map[string][]rbac_v1.PolicyRule{}
+	for _, role := range mappedResource.Kube.Roles {
+		roles[role.Name] = role.Rules
+	}
+	clusterRoles := map[string][]rbac_v1.PolicyRule{}
+	for _, clusterRole := range mappedResource.Kube.ClusterRoles {
+		clusterRoles[clusterRole.Name] = clusterRole.Rules
+	}
+	roleRules := func(roleRef rbac_v1.RoleRef) []rbac_v1.PolicyRule {
+		if roleRef.Kind == "ClusterRole" {
+			return clusterRoles[roleRef.Name]
+		}
+		return roles[roleRef.Name]
+	}
+
+	//Verbs of permissions keyed by their other fields
+	type permissionKey struct {
+		serviceAccount, namespace, apiGroup, resource, resourceName, nonResourceURL string
+	}
+	permissions := map[permissionKey]map[string]bool{}
+	add := func(serviceAccount string, namespace string, rules []rbac_v1.PolicyRule) {
+		for _, rule := range rules {
+			for _, permission := range flattenRule(rule) {
+				key := permissionKey{serviceAccount, namespace, permission.APIGroup, permission.Resource, permission.ResourceName, permission.NonResourceURL}
+				if permissions[key] == nil {
+					permissions[key] = map[string]bool{}
+				}
+				for _, verb := range rule.Verbs {
+					permissions[key][verb] = true
+				}
+			}
+		}
+	}
+
+	for serviceAccount := range groupServiceAccountNames(mappedResource) {
+		for _, roleBinding := range mappedResource.Kube.RoleBindings {
+			if bindsServiceAccount(roleBinding.Subjects, roleBinding.Namespace, mappedResource.Namespace, serviceAccount) {
+				add(serviceAccount, roleBinding.Namespace, roleRules(roleBinding.RoleRef))
+			}
+		}
+		for _, clusterRoleBinding := range mappedResource.Kube.ClusterRoleBindings {
+			if clusterRoleBinding.RoleRef.Kind == "ClusterRole" && bindsServiceAccount(clusterRoleBinding.Subjects, "", mappedResource.Namespace, serviceAccount) {
+				add(serviceAccount, "", clusterRoles[clusterRoleBinding.RoleRef.Name])
+			}
+		}
+	}
+
+	var flattened []Permission
+	for key, verbs := range permissions {
+		permission := Permission{
+			ServiceAccount: key.serviceAccount,
+			Namespace:      key.namespace,
+			APIGroup:       key.apiGroup,
+			Resource:       key.resource,
+			ResourceName:   key.resourceName,
+			NonResourceURL: key.nonResourceURL,
+		}
+		if verbs[rbac_v1.VerbAll] {
+			verbs = map[string]bool{rbac_v1.VerbAll: true}
+		}
+		for verb := range verbs {
+			permission.Verbs = append(permission.Verbs, verb)
+		}
+		sort.Strings(permission.Verbs)
+		flattened = append(flattened, permission)
+	}
+
+	sort.Slice(flattened, func(i, j int) bool {
+		a, b := flattened[i], flattened[j]
+		if a.ServiceAccount != b.ServiceAccount {
+			return a.ServiceAccount < b.ServiceAccount
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.APIGroup != b.APIGroup {
+			return a.APIGroup < b.APIGroup
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		if a.ResourceName != b.ResourceName {
+			return a.ResourceName < b.ResourceName
+		}
+		return a.NonResourceURL < b.NonResourceURL
+	})
+
+	return flattened
+}
+
+//flattenRule returns one permission, without verbs, per API group, resource and resource name of rule, or per non-resource URL.
+func flattenRule(rule rbac_v1.PolicyRule) []Permission {
+	var permissions []Permission
+	for _, url := range rule.NonResourceURLs {
+		permissions = append(permissions, Permission{NonResourceURL: url})
+	}
+
+	resourceNames := rule.ResourceNames
+	if len(resourceNames) == 0 {
+		resourceNames = []string{""}
+	}
+	for _, apiGroup := range rule.APIGroups {
+		for _, resource := range rule.Resources {
+			for _, resourceName := range resourceNames {
+				permissions = append(permissions, Permission{APIGroup: apiGroup, Resource: resource, ResourceName: resourceName})
+			}
+		}
+	}
+
+	return permissions
+}
+
+//groupServiceAccountNames returns names of service accounts pods of group run as.
+func groupServiceAccountNames(mappedResource MappedResource) map[string]bool {
+	names := map[string]bool{}
+	for _, podSpec := range groupPodSpecs(mappedResource) {
+		switch {
+		case podSpec.ServiceAccountName != "":
+			names[podSpec.ServiceAccountName] = true
+		case podSpec.DeprecatedServiceAccount != "":
+			names[podSpec.DeprecatedServiceAccount] = true
+		default:
+			names[defaultServiceAccount] = true
+		}
+	}
+
+	return names
+}
+
+//bindsGroup returns true when subjects of a binding include a service account of group.
+//bindingNamespace is namespace of RoleBinding, and is empty for ClusterRoleBindings.
+func bindsGroup(mappedResource MappedResource, subjects []rbac_v1.Subject, bindingNamespace string) bool {
+	for serviceAccount := range groupServiceAccountNames(mappedResource) {
+		if bindsServiceAccount(subjects, bindingNamespace, mappedResource.Namespace, serviceAccount) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//bindsServiceAccount returns true when subjects include service account, directly, as its user name or through service account groups.
+func bindsServiceAccount(subjects []rbac_v1.Subject, bindingNamespace string, namespace string, name string) bool {
+	for _, subject := range subjects {
+		switch subject.Kind {
+		case rbac_v1.ServiceAccountKind:
+			subjectNamespace := subject.Namespace
+			if subjectNamespace == "" {
+				subjectNamespace = bindingNamespace
+			}
+			if subjectNamespace == namespace && subject.Name == name {
+				return true
+			}
+		case rbac_v1.UserKind:
+			if subject.Name == fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name) {
+				return true
+			}
+		case rbac_v1.GroupKind:
+			if subject.Name == "system:serviceaccounts" || subject.Name == "system:serviceaccounts:"+namespace {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//groupRoleNames returns names of roles of given kind, "Role" or "ClusterRole", bindings of group refer to.
+func groupRoleNames(mappedResource MappedResource, kind string) map[string]bool {
+	names := map[string]bool{}
+	for _, roleBinding := range mappedResource.Kube.RoleBindings {
+		if roleBinding.RoleRef.Kind == kind {
+			names[roleBinding.RoleRef.Name] = true
+		}
+	}
+	for _, clusterRoleBinding := range mappedResource.Kube.ClusterRoleBindings {
+		if clusterRoleBinding.RoleRef.Kind == kind {
+			names[clusterRoleBinding.RoleRef.Name] = true
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,288 @@
+package kubemap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//RedactedValue replaces masked values.
+	RedactedValue = "<redacted>"
+	//RedactedAnnotation is set on objects with masked values. It lists paths of masked fields, comma separated.
+	RedactedAnnotation = "kubemap.io/redacted"
+
+	lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+)
+
+//RedactionOptions masks sensitive values of k8s objects before they are written to store and returned in results.
+//Data of Secrets is always masked unless KeepSecretData is set.
+type RedactionOptions struct {
+	//EnvValues masks values of container environment variables. Last applied configuration annotation, which holds them too, is masked as well.
+	EnvValues bool
+	//Annotations lists annotation keys whose values are masked.
+	Annotations []string
+	//KeepSecretData keeps data & stringData of Secrets.
+	KeepSecretData bool
+}
+
+//redaction is compiled form of RedactionOptions. Zero value masks data of Secrets only.
+type redaction struct {
+	envValues      bool
+	annotations    map[string]bool
+	keepSecretData bool
+}
+
+func newRedaction(options RedactionOptions) redaction {
+	r := redaction{
+		envValues:      options.EnvValues,
+		annotations:    map[string]bool{},
+		keepSecretData: options.KeepSecretData,
+	}
+
+	for _, annotation := range options.Annotations {
+		r.annotations[annotation] = true
+	}
+	if options.EnvValues {
+		r.annotations[lastAppliedAnnotation] = true
+	}
+
+	return r
+}
+
+//applyResults masks mapped resources of results not yet written to store.
+func (r redaction) applyResults(results []MapResult) {
+	for i := range results {
+		if !results[i].IsStoreUpdated {
+			results[i].MappedResource = r.apply(results[i].MappedResource)
+		}
+	}
+}
+
+//apply returns mapped resource with sensitive values masked. Objects of given mapped resource are not modified.
+func (r redaction) apply(mappedResource MappedResource) MappedResource {
+	if !r.envValues && len(r.annotations) == 0 && (r.keepSecretData || len(mappedResource.Kube.Secrets) == 0) {
+		return mappedResource
+	}
+
+	kube := mappedResource.Kube
+	mappedResource.Kube = Kube{Events: kube.Events}
+
+	for _, ingress := range kube.Ingresses {
+		ingress = *ingress.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &ingress.ObjectMeta, redacted)
+		setRedactedAnnotation(&ingress.ObjectMeta, redacted)
+		mappedResource.Kube.Ingresses = append(mappedResource.Kube.Ingresses, ingress)
+	}
+	for _, service := range kube.Services {
+		service = *service.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &service.ObjectMeta, redacted)
+		setRedactedAnnotation(&service.ObjectMeta, redacted)
+		mappedResource.Kube.Services = append(mappedResource.Kube.Services, service)
+	}
+	for _, deployment := range kube.Deployments {
+		deployment = *deployment.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &deployment.ObjectMeta, redacted)
+		redacted = r.redactAnnotations("spec.template.metadata", &deployment.Spec.Template.ObjectMeta, redacted)
+		redacted = r.redactPodSpec("spec.template.spec", &deployment.Spec.Template.Spec, redacted)
+		setRedactedAnnotation(&deployment.ObjectMeta, redacted)
+		mappedResource.Kube.Deployments = append(mappedResource.Kube.Deployments, deployment)
+	}
+	for _, replicaSet := range kube.ReplicaSets {
+		replicaSet = *replicaSet.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &replicaSet.ObjectMeta, redacted)
+		redacted = r.redactAnnotations("spec.template.metadata", &replicaSet.Spec.Template.ObjectMeta, redacted)
+		redacted = r.redactPodSpec("spec.template.spec", &replicaSet.Spec.Template.Spec, redacted)
+		setRedactedAnnotation(&replicaSet.ObjectMeta, redacted)
+		mappedResource.Kube.ReplicaSets = append(mappedResource.Kube.ReplicaSets, replicaSet)
+	}
+	for _, pod := range kube.Pods {
+		pod = *pod.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &pod.ObjectMeta, redacted)
+		redacted = r.redactPodSpec("spec", &pod.Spec, redacted)
+		setRedactedAnnotation(&pod.ObjectMeta, redacted)
+		mappedResource.Kube.Pods = append(mappedResource.Kube.Pods, pod)
+	}
+	for _, configMap := range kube.ConfigMaps {
+		configMap = *configMap.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &configMap.ObjectMeta, redacted)
+		setRedactedAnnotation(&configMap.ObjectMeta, redacted)
+		mappedResource.Kube.ConfigMaps = append(mappedResource.Kube.ConfigMaps, configMap)
+	}
+	for _, secret := range kube.Secrets {
+		secret = *secret.DeepCopy()
+		r.redactSecret(&secret)
+		mappedResource.Kube.Secrets = append(mappedResource.Kube.Secrets, secret)
+	}
+	for _, claim := range kube.PersistentVolumeClaims {
+		claim = *claim.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &claim.ObjectMeta, redacted)
+		setRedactedAnnotation(&claim.ObjectMeta, redacted)
+		mappedResource.Kube.PersistentVolumeClaims = append(mappedResource.Kube.PersistentVolumeClaims, claim)
+	}
+	for _, volume := range kube.PersistentVolumes {
+		volume = *volume.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &volume.ObjectMeta, redacted)
+		setRedactedAnnotation(&volume.ObjectMeta, redacted)
+		mappedResource.Kube.PersistentVolumes = append(mappedResource.Kube.PersistentVolumes, volume)
+	}
+	for _, endpoints := range kube.Endpoints {
+		endpoints = *endpoints.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &endpoints.ObjectMeta, redacted)
+		setRedactedAnnotation(&endpoints.ObjectMeta, redacted)
+		mappedResource.Kube.Endpoints = append(mappedResource.Kube.Endpoints, endpoints)
+	}
+	for _, endpointSlice := range kube.EndpointSlices {
+		endpointSlice = *endpointSlice.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &endpointSlice.ObjectMeta, redacted)
+		setRedactedAnnotation(&endpointSlice.ObjectMeta, redacted)
+		mappedResource.Kube.EndpointSlices = append(mappedResource.Kube.EndpointSlices, endpointSlice)
+	}
+	for _, networkPolicy := range kube.NetworkPolicies {
+		networkPolicy = *networkPolicy.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &networkPolicy.ObjectMeta, redacted)
+		setRedactedAnnotation(&networkPolicy.ObjectMeta, redacted)
+		mappedResource.Kube.NetworkPolicies = append(mappedResource.Kube.NetworkPolicies, networkPolicy)
+	}
+	for _, budget := range kube.PodDisruptionBudgets {
+		budget = *budget.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &budget.ObjectMeta, redacted)
+		setRedactedAnnotation(&budget.ObjectMeta, redacted)
+		mappedResource.Kube.PodDisruptionBudgets = append(mappedResource.Kube.PodDisruptionBudgets, budget)
+	}
+	for _, serviceAccount := range kube.ServiceAccounts {
+		serviceAccount = *serviceAccount.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &serviceAccount.ObjectMeta, redacted)
+		setRedactedAnnotation(&serviceAccount.ObjectMeta, redacted)
+		mappedResource.Kube.ServiceAccounts = append(mappedResource.Kube.ServiceAccounts, serviceAccount)
+	}
+	for _, roleBinding := range kube.RoleBindings {
+		roleBinding = *roleBinding.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &roleBinding.ObjectMeta, redacted)
+		setRedactedAnnotation(&roleBinding.ObjectMeta, redacted)
+		mappedResource.Kube.RoleBindings = append(mappedResource.Kube.RoleBindings, roleBinding)
+	}
+	for _, clusterRoleBinding := range kube.ClusterRoleBindings {
+		clusterRoleBinding = *clusterRoleBinding.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &clusterRoleBinding.ObjectMeta, redacted)
+		setRedactedAnnotation(&clusterRoleBinding.ObjectMeta, redacted)
+		mappedResource.Kube.ClusterRoleBindings = append(mappedResource.Kube.ClusterRoleBindings, clusterRoleBinding)
+	}
+	for _, role := range kube.Roles {
+		role = *role.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &role.ObjectMeta, redacted)
+		setRedactedAnnotation(&role.ObjectMeta, redacted)
+		mappedResource.Kube.Roles = append(mappedResource.Kube.Roles, role)
+	}
+	for _, clusterRole := range kube.ClusterRoles {
+		clusterRole = *clusterRole.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &clusterRole.ObjectMeta, redacted)
+		setRedactedAnnotation(&clusterRole.ObjectMeta, redacted)
+		mappedResource.Kube.ClusterRoles = append(mappedResource.Kube.ClusterRoles, clusterRole)
+	}
+	for _, node := range kube.Nodes {
+		node = *node.DeepCopy()
+		var redacted []string
+		redacted = r.redactAnnotations("metadata", &node.ObjectMeta, redacted)
+		setRedactedAnnotation(&node.ObjectMeta, redacted)
+		mappedResource.Kube.Nodes = append(mappedResource.Kube.Nodes, node)
+	}
+
+	return mappedResource
+}
+
+//redactSecret masks data and stringData of secret in place, unless secret data is kept.
+func (r redaction) redactSecret(secret *core_v1.Secret) {
+	var redacted []string
+	redacted = r.redactAnnotations("metadata", &secret.ObjectMeta, redacted)
+
+	if !r.keepSecretData {
+		//Last applied configuration of a secret holds its data.
+		if _, ok := secret.Annotations[lastAppliedAnnotation]; ok && secret.Annotations[lastAppliedAnnotation] != RedactedValue {
+			secret.Annotations[lastAppliedAnnotation] = RedactedValue
+			redacted = append(redacted, fmt.Sprintf("metadata.annotations[%s]", lastAppliedAnnotation))
+		}
+		for key := range secret.Data {
+			secret.Data[key] = []byte(RedactedValue)
+			redacted = append(redacted, fmt.Sprintf("data[%s]", key))
+		}
+		for key := range secret.StringData {
+			secret.StringData[key] = RedactedValue
+			redacted = append(redacted, fmt.Sprintf("stringData[%s]", key))
+		}
+	}
+
+	setRedactedAnnotation(&secret.ObjectMeta, redacted)
+}
+
+func (r redaction) redactAnnotations(path string, objectMeta *meta_v1.ObjectMeta, redacted []string) []string {
+	for key, value := range objectMeta.Annotations {
+		if r.annotations[key] && value != RedactedValue {
+			objectMeta.Annotations[key] = RedactedValue
+			redacted = append(redacted, fmt.Sprintf("%s.annotations[%s]", path, key))
+		}
+	}
+
+	return redacted
+}
+
+func (r redaction) redactPodSpec(path string, podSpec *core_v1.PodSpec, redacted []string) []string {
+	if !r.envValues {
+		return redacted
+	}
+
+	for _, containers := range []struct {
+		path       string
+		containers []core_v1.Container
+	}{{path + ".initContainers", podSpec.InitContainers}, {path + ".containers", podSpec.Containers}} {
+		for _, container := range containers.containers {
+			for i, env := range container.Env {
+				if env.Value != "" && env.Value != RedactedValue {
+					container.Env[i].Value = RedactedValue
+					redacted = append(redacted, fmt.Sprintf("%s[%s].env[%s]", containers.path, container.Name, env.Name))
+				}
+			}
+		}
+	}
+
+	return redacted
+}
+
+//setRedactedAnnotation adds paths of masked fields to marker annotation of object.
+//Paths masked earlier, like before object was written to store, are kept.
+func setRedactedAnnotation(objectMeta *meta_v1.ObjectMeta, redacted []string) {
+	if len(redacted) == 0 {
+		return
+	}
+
+	if existing := objectMeta.Annotations[RedactedAnnotation]; existing != "" {
+		redacted = append(redacted, strings.Split(existing, ",")...)
+	}
+	redacted = removeDuplicateStrings(redacted)
+	sort.Strings(redacted)
+
+	if objectMeta.Annotations == nil {
+		objectMeta.Annotations = map[string]string{}
+	}
+	objectMeta.Annotations[RedactedAnnotation] = strings.Join(redacted, ",")
+}
@@ -0,0 +1,985 @@
+package kubemap
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+
+	core_v1 "k8s.io/api/core/v1"
+	networking_v1 "k8s.io/api/networking/v1"
+	policy_v1beta1 "k8s.io/api/policy/v1beta1"
+	rbac_v1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/tools/cache"
+)
+
+//satelliteKind describes objects, like ConfigMaps, which are attached to every group referencing them.
+//Satellites never link groups together. Shared satellite is copied to each group using it.
+//Satellite which is not referenced by any group is kept in a standalone group of its own.
+type satelliteKind struct {
+	kind string
+	//objects returns satellites of kind in group, keyed by name.
+	objects func(kube Kube) map[string]interface{}
+	//setObjects replaces satellites of kind in group.
+	setObjects func(kube *Kube, objects []interface{})
+	//references returns names of satellites of kind referenced by members of group.
+	references func(mappedResource MappedResource) map[string]bool
+	//eventObject returns satellite carried by a resource event.
+	eventObject func(event interface{}) (interface{}, bool)
+	//namespace returns namespace of groups a cluster scoped satellite belongs to. It is nil for namespaced kinds.
+	namespace func(object interface{}) string
+	//referenceName returns name groups reference satellite by, like service name of an EndpointSlice. It is nil when it is name of satellite.
+	referenceName func(object interface{}) string
+	//selects returns true when satellite selects members of group, like a NetworkPolicy selecting its pods.
+	//It replaces references for kinds which reference groups, rather than being referenced by them.
+	selects func(mappedResource MappedResource, object interface{}) bool
+	//isClusterWide is true for cluster scoped satellites used by groups of any namespace, like ClusterRoles.
+	//They are always kept in a standalone group without namespace, which groups attach them from.
+	isClusterWide bool
+}
+
+//satelliteKinds are attached in order. Kinds referenced by satellites, like PersistentVolumes by PersistentVolumeClaims, come after them.
+var satelliteKinds = []satelliteKind{
+	{
+		kind: "configmap",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, configMap := range kube.ConfigMaps {
+				objects[configMap.Name] = configMap
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.ConfigMaps = nil
+			for _, object := range objects {
+				kube.ConfigMaps = append(kube.ConfigMaps, object.(core_v1.ConfigMap))
+			}
+		},
+		references: func(mappedResource MappedResource) map[string]bool {
+			references := map[string]bool{}
+			for _, podSpec := range groupPodSpecs(mappedResource) {
+				podSpecReferences(podSpec, references, nil)
+			}
+			return references
+		},
+		eventObject: func(event interface{}) (interface{}, bool) {
+			configMap, ok := event.(*core_v1.ConfigMap)
+			if !ok {
+				return nil, false
+			}
+			return *configMap.DeepCopy(), true
+		},
+	},
+	{
+		kind: "secret",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, secret := range kube.Secrets {
+				objects[secret.Name] = secret
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.Secrets = nil
+			for _, object := range objects {
+				kube.Secrets = append(kube.Secrets, object.(core_v1.Secret))
+			}
+		},
+		references: func(mappedResource MappedResource) map[string]bool {
+			references := map[string]bool{}
+			for _, podSpec := range groupPodSpecs(mappedResource) {
+				podSpecReferences(podSpec, nil, references)
+			}
+			for _, ingress := range mappedResource.Kube.Ingresses {
+				for _, tls := range ingress.Spec.TLS {
+					if tls.SecretName != "" {
+						references[tls.SecretName] = true
+					}
+				}
+			}
+			return references
+		},
+		eventObject: func(event interface{}) (interface{}, bool) {
+			secret, ok := event.(*core_v1.Secret)
+			if !ok {
+				return nil, false
+			}
+			return *secret.DeepCopy(), true
+		},
+	},
+	{
+		kind: "persistentvolumeclaim",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, claim := range kube.PersistentVolumeClaims {
+				objects[claim.Name] = claim
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.PersistentVolumeClaims = nil
+			for _, object := range objects {
+				kube.PersistentVolumeClaims = append(kube.PersistentVolumeClaims, object.(core_v1.PersistentVolumeClaim))
+			}
+		},
+		references: func(mappedResource MappedResource) map[string]bool {
+			references := map[string]bool{}
+			for _, podSpec := range groupPodSpecs(mappedResource) {
+				for _, volume := range podSpec.Volumes {
+					if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName != "" {
+						references[volume.PersistentVolumeClaim.ClaimName] = true
+					}
+				}
+			}
+			return references
+		},
+		eventObject: func(event interface{}) (interface{}, bool) {
+			claim, ok := event.(*core_v1.PersistentVolumeClaim)
+			if !ok {
+				return nil, false
+			}
+			return *claim.DeepCopy(), true
+		},
+	},
+	{
+		kind: "persistentvolume",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, volume := range kube.PersistentVolumes {
+				objects[volume.Name] = volume
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.PersistentVolumes = nil
+			for _, object := range objects {
+				kube.PersistentVolumes = append(kube.PersistentVolumes, object.(core_v1.PersistentVolume))
+			}
+		},
+		references: func(mappedResource MappedResource) map[string]bool {
+			references := map[string]bool{}
+			for _, claim := range mappedResource.Kube.PersistentVolumeClaims {
+				if claim.Spec.VolumeName != "" {
+					references[claim.Spec.VolumeName] = true
+				}
+			}
+			return references
+		},
+		eventObject: func(event interface{}) (interface{}, bool) {
+			volume, ok := event.(*core_v1.PersistentVolume)
+			if !ok {
+				return nil, false
+			}
+			return *volume.DeepCopy(), true
+		},
+		namespace: func(object interface{}) string {
+			return persistentVolumeNamespace(object.(core_v1.PersistentVolume))
+		},
+	},
+	{
+		kind: "endpoints",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, endpoints := range kube.Endpoints {
+				objects[endpoints.Name] = endpoints
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.Endpoints = nil
+			for _, object := range objects {
+				kube.Endpoints = append(kube.Endpoints, object.(core_v1.Endpoints))
+			}
+		},
+		references: groupServiceNames,
+		eventObject: func(event interface{}) (interface{}, bool) {
+			endpoints, ok := event.(*core_v1.Endpoints)
+			if !ok {
+				return nil, false
+			}
+			return *endpoints.DeepCopy(), true
+		},
+	},
+	{
+		kind: "endpointslice",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, endpointSlice := range kube.EndpointSlices {
+				objects[endpointSlice.Name] = endpointSlice
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.EndpointSlices = nil
+			for _, object := range objects {
+				kube.EndpointSlices = append(kube.EndpointSlices, object.(EndpointSlice))
+			}
+		},
+		references: groupServiceNames,
+		eventObject: func(event interface{}) (interface{}, bool) {
+			return endpointSliceFromEvent(event)
+		},
+		referenceName: func(object interface{}) string {
+			return object.(EndpointSlice).Labels[endpointSliceServiceLabel]
+		},
+	},
+	{
+		kind: "networkpolicy",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, networkPolicy := range kube.NetworkPolicies {
+				objects[networkPolicy.Name] = networkPolicy
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.NetworkPolicies = nil
+			for _, object := range objects {
+				kube.NetworkPolicies = append(kube.NetworkPolicies, object.(networking_v1.NetworkPolicy))
+			}
+		},
+		eventObject: func(event interface{}) (interface{}, bool) {
+			networkPolicy, ok := event.(*networking_v1.NetworkPolicy)
+			if !ok {
+				return nil, false
+			}
+			return *networkPolicy.DeepCopy(), true
+		},
+		selects: func(mappedResource MappedResource, object interface{}) bool {
+			networkPolicy := object.(networking_v1.NetworkPolicy)
+			return selectsGroupPods(mappedResource, &networkPolicy.Spec.PodSelector)
+		},
+	},
+	{
+		kind: "poddisruptionbudget",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, budget := range kube.PodDisruptionBudgets {
+				objects[budget.Name] = budget
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.PodDisruptionBudgets = nil
+			for _, object := range objects {
+				kube.PodDisruptionBudgets = append(kube.PodDisruptionBudgets, object.(policy_v1beta1.PodDisruptionBudget))
+			}
+		},
+		eventObject: func(event interface{}) (interface{}, bool) {
+			budget, ok := event.(*policy_v1beta1.PodDisruptionBudget)
+			if !ok {
+				return nil, false
+			}
+			return *budget.DeepCopy(), true
+		},
+		selects: func(mappedResource MappedResource, object interface{}) bool {
+			budget := object.(policy_v1beta1.PodDisruptionBudget)
+			return !isEmptySelector(budget.Spec.Selector) && selectsGroupPods(mappedResource, budget.Spec.Selector)
+		},
+	},
+	{
+		kind: "serviceaccount",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, serviceAccount := range kube.ServiceAccounts {
+				objects[serviceAccount.Name] = serviceAccount
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.ServiceAccounts = nil
+			for _, object := range objects {
+				kube.ServiceAccounts = append(kube.ServiceAccounts, object.(core_v1.ServiceAccount))
+			}
+		},
+		references: groupServiceAccountNames,
+		eventObject: func(event interface{}) (interface{}, bool) {
+			serviceAccount, ok := event.(*core_v1.ServiceAccount)
+			if !ok {
+				return nil, false
+			}
+			return *serviceAccount.DeepCopy(), true
+		},
+	},
+	{
+		kind: "rolebinding",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, roleBinding := range kube.RoleBindings {
+				objects[roleBinding.Name] = roleBinding
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.RoleBindings = nil
+			for _, object := range objects {
+				kube.RoleBindings = append(kube.RoleBindings, object.(rbac_v1.RoleBinding))
+			}
+		},
+		eventObject: func(event interface{}) (interface{}, bool) {
+			roleBinding, ok := event.(*rbac_v1.RoleBinding)
+			if !ok {
+				return nil, false
+			}
+			return *roleBinding.DeepCopy(), true
+		},
+		selects: func(mappedResource MappedResource, object interface{}) bool {
+			roleBinding := object.(rbac_v1.RoleBinding)
+			return bindsGroup(mappedResource, roleBinding.Subjects, roleBinding.Namespace)
+		},
+	},
+	{
+		kind: "clusterrolebinding",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, clusterRoleBinding := range kube.ClusterRoleBindings {
+				objects[clusterRoleBinding.Name] = clusterRoleBinding
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.ClusterRoleBindings = nil
+			for _, object := range objects {
+				kube.ClusterRoleBindings = append(kube.ClusterRoleBindings, object.(rbac_v1.ClusterRoleBinding))
+			}
+		},
+		eventObject: func(event interface{}) (interface{}, bool) {
+			clusterRoleBinding, ok := event.(*rbac_v1.ClusterRoleBinding)
+			if !ok {
+				return nil, false
+			}
+			return *clusterRoleBinding.DeepCopy(), true
+		},
+		selects: func(mappedResource MappedResource, object interface{}) bool {
+			return bindsGroup(mappedResource, object.(rbac_v1.ClusterRoleBinding).Subjects, "")
+		},
+		isClusterWide: true,
+	},
+	{
+		kind: "role",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, role := range kube.Roles {
+				objects[role.Name] = role
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.Roles = nil
+			for _, object := range objects {
+				kube.Roles = append(kube.Roles, object.(rbac_v1.Role))
+			}
+		},
+		references: func(mappedResource MappedResource) map[string]bool {
+			return groupRoleNames(mappedResource, "Role")
+		},
+		eventObject: func(event interface{}) (interface{}, bool) {
+			role, ok := event.(*rbac_v1.Role)
+			if !ok {
+				return nil, false
+			}
+			return *role.DeepCopy(), true
+		},
+	},
+	{
+		kind: "clusterrole",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, clusterRole := range kube.ClusterRoles {
+				objects[clusterRole.Name] = clusterRole
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.ClusterRoles = nil
+			for _, object := range objects {
+				kube.ClusterRoles = append(kube.ClusterRoles, object.(rbac_v1.ClusterRole))
+			}
+		},
+		references: func(mappedResource MappedResource) map[string]bool {
+			return groupRoleNames(mappedResource, "ClusterRole")
+		},
+		eventObject: func(event interface{}) (interface{}, bool) {
+			clusterRole, ok := event.(*rbac_v1.ClusterRole)
+			if !ok {
+				return nil, false
+			}
+			return *clusterRole.DeepCopy(), true
+		},
+		isClusterWide: true,
+	},
+	{
+		kind: "node",
+		objects: func(kube Kube) map[string]interface{} {
+			objects := map[string]interface{}{}
+			for _, node := range kube.Nodes {
+				objects[node.Name] = node
+			}
+			return objects
+		},
+		setObjects: func(kube *Kube, objects []interface{}) {
+			kube.Nodes = nil
+			for _, object := range objects {
+				kube.Nodes = append(kube.Nodes, object.(core_v1.Node))
+			}
+		},
+		eventObject: func(event interface{}) (interface{}, bool) {
+			node, ok := event.(*core_v1.Node)
+			if !ok {
+				return nil, false
+			}
+			return topologyNode(*node), true
+		},
+		selects: func(mappedResource MappedResource, object interface{}) bool {
+			return groupNodeNames(mappedResource)[object.(core_v1.Node).Name]
+		},
+		isClusterWide: true,
+	},
+}
+
+//satelliteObject is a satellite found in a group.
+type satelliteObject struct {
+	namespace string
+	name      string
+	object    interface{}
+}
+
+func getSatelliteKind(kind string) (satelliteKind, bool) {
+	for _, satellite := range satelliteKinds {
+		if satellite.kind == kind {
+			return satellite, true
+		}
+	}
+
+	return satelliteKind{}, false
+}
+
+//attachedSatelliteKinds returns satellite kinds attached to groups changed by event of given kind.
+//Events of satellites attach them on their own, so only kinds coming after them are attached.
+func attachedSatelliteKinds(kind string) []satelliteKind {
+	for i, satellite := range satelliteKinds {
+		if satellite.kind == kind {
+			return satelliteKinds[i+1:]
+		}
+	}
+
+	return satelliteKinds
+}
+
+//isClusterScoped returns true when satellites of kind are attached to groups of other namespaces than their own.
+func (s satelliteKind) isClusterScoped() bool {
+	return s.namespace != nil || s.isClusterWide
+}
+
+//groupReferences returns names of satellites referenced by members of group. It is nil for kinds selecting groups.
+func (s satelliteKind) groupReferences(mappedResource MappedResource) map[string]bool {
+	if s.references == nil {
+		return nil
+	}
+
+	return s.references(mappedResource)
+}
+
+//isReferenced returns true when group, whose references are given, references satellite, or satellite selects members of group.
+func (s satelliteKind) isReferenced(mappedResource MappedResource, references map[string]bool, name string, object interface{}) bool {
+	if s.selects != nil {
+		return s.selects(mappedResource, object)
+	}
+	if s.referenceName != nil {
+		name = s.referenceName(object)
+	}
+
+	return references[name]
+}
+
+//groupServiceNames returns names of services in group. Endpoints are referenced by them.
+func groupServiceNames(mappedResource MappedResource) map[string]bool {
+	names := map[string]bool{}
+	for _, service := range mappedResource.Kube.Services {
+		names[service.Name] = true
+	}
+
+	return names
+}
+
+//groupPodSpecs returns pod specs of pods and pod templates of workloads in group.
+func groupPodSpecs(mappedResource MappedResource) []core_v1.PodSpec {
+	var podSpecs []core_v1.PodSpec
+	for _, deployment := range mappedResource.Kube.Deployments {
+		podSpecs = append(podSpecs, deployment.Spec.Template.Spec)
+	}
+	for _, replicaSet := range mappedResource.Kube.ReplicaSets {
+		podSpecs = append(podSpecs, replicaSet.Spec.Template.Spec)
+	}
+	for _, pod := range mappedResource.Kube.Pods {
+		podSpecs = append(podSpecs, pod.Spec)
+	}
+
+	return podSpecs
+}
+
+//podSpecReferences adds names of ConfigMaps and Secrets used by volumes, environment and image pull secrets of pod spec.
+func podSpecReferences(podSpec core_v1.PodSpec, configMaps map[string]bool, secrets map[string]bool) {
+	add := func(references map[string]bool, name string) {
+		if references != nil && name != "" {
+			references[name] = true
+		}
+	}
+
+	for _, volume := range podSpec.Volumes {
+		if volume.ConfigMap != nil {
+			add(configMaps, volume.ConfigMap.Name)
+		}
+		if volume.Secret != nil {
+			add(secrets, volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					add(configMaps, source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					add(secrets, source.Secret.Name)
+				}
+			}
+		}
+	}
+
+	for _, container := range append(append([]core_v1.Container{}, podSpec.InitContainers...), podSpec.Containers...) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				add(configMaps, envFrom.ConfigMapRef.Name)
+			}
+			if envFrom.SecretRef != nil {
+				add(secrets, envFrom.SecretRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				add(configMaps, env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				add(secrets, env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+
+	for _, imagePullSecret := range podSpec.ImagePullSecrets {
+		add(secrets, imagePullSecret.Name)
+	}
+}
+
+//isStandaloneSatellite returns true when group is the standalone group of a satellite of given kind.
+//Standalone group may hold satellites of other kinds, like PersistentVolume of a standalone PersistentVolumeClaim.
+func isStandaloneSatellite(mappedResource MappedResource, satellite satelliteKind) bool {
+	kube := mappedResource.Kube
+	return mappedResource.CurrentType == satellite.kind &&
+		len(kube.Ingresses) == 0 && len(kube.Services) == 0 && len(kube.Deployments) == 0 && len(kube.ReplicaSets) == 0 && len(kube.Pods) == 0
+}
+
+func newStandaloneSatellite(satellite satelliteKind, namespace string, name string, object interface{}) MappedResource {
+	mappedResource := MappedResource{
+		CommonLabel: fmt.Sprintf("%s-%s", satellite.kind, name),
+		Namespace:   namespace,
+		CurrentType: satellite.kind,
+	}
+	satellite.setObjects(&mappedResource.Kube, []interface{}{object})
+
+	return mappedResource
+}
+
+//sortedSatellites returns objects ordered by name, so that groups holding same satellites are equal.
+func sortedSatellites(objects map[string]interface{}) []interface{} {
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sorted := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		sorted = append(sorted, objects[name])
+	}
+
+	return sorted
+}
+
+//mapSatelliteObj attaches satellite of event to groups referencing it, or keeps it standalone.
+func (m *Mapper) mapSatelliteObj(obj ResourceEvent, store cache.Store, satellite satelliteKind) ([]MapResult, error) {
+	var object interface{}
+	if obj.Event != nil {
+		eventObject, ok := satellite.eventObject(obj.Event)
+		if !ok {
+			return []MapResult{}, invalidEventError(obj)
+		}
+		object = eventObject
+	}
+
+	//Groups hold copies of cluster wide satellites. Unless standalone one changes, none of them does, so store is not scanned.
+	if satellite.isClusterWide && object != nil {
+		unchanged, err := unchangedClusterWideSatellite(store, satellite, obj.Name, object)
+		if err != nil || unchanged.IsMapped {
+			return []MapResult{unchanged}, err
+		}
+	}
+
+	var mapResults []MapResult
+	var standaloneKey string
+	var standalone MappedResource
+	isReferenced := false
+
+	namespace := obj.Namespace
+	namespaceKeys, err := getNamespaceKeys(obj.Namespace, store)
+	if satellite.namespace != nil || satellite.isClusterWide {
+		//Cluster scoped satellite may have moved between namespaces, and namespace of a deleted one is not known.
+		namespaceKeys, err = getAllKeys(store)
+		if object != nil && satellite.namespace != nil {
+			namespace = satellite.namespace(object)
+		}
+	}
+	if err != nil {
+		return []MapResult{}, err
+	}
+
+	for _, namespaceKey := range namespaceKeys {
+		mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+		if err != nil {
+			return []MapResult{}, err
+		}
+
+		objects := satellite.objects(mappedResource.Kube)
+		_, isHeld := objects[obj.Name]
+		if isStandaloneSatellite(mappedResource, satellite) {
+			if isHeld {
+				standaloneKey = namespaceKey
+				standalone = mappedResource
+			}
+			continue
+		}
+
+		isGroupReferencing := object != nil && (satellite.isClusterWide || mappedResource.Namespace == namespace) && satellite.isReferenced(mappedResource, satellite.groupReferences(mappedResource), obj.Name, object)
+		if !isGroupReferencing && !isHeld {
+			continue
+		}
+
+		message := fmt.Sprintf("%s %s is removed from Common Label %s", satellite.kind, obj.Name, mappedResource.CommonLabel)
+		if isGroupReferencing {
+			isReferenced = true
+			objects[obj.Name] = object
+			message = fmt.Sprintf("%s %s is updated in Common Label %s", satellite.kind, obj.Name, mappedResource.CommonLabel)
+		} else {
+			delete(objects, obj.Name)
+		}
+		satellite.setObjects(&mappedResource.Kube, sortedSatellites(objects))
+
+		mapResults = append(mapResults, MapResult{
+			Action:         "Updated",
+			Key:            namespaceKey,
+			IsMapped:       true,
+			CommonLabel:    mappedResource.CommonLabel,
+			MappedResource: mappedResource,
+			Message:        message,
+		})
+	}
+
+	isStandalone := object != nil && (!isReferenced || satellite.isClusterWide)
+	isStandaloneKept := standaloneKey != "" && isStandalone && standalone.Namespace == namespace
+	if standaloneKey != "" && !isStandaloneKept {
+		mapResults = append(mapResults, MapResult{
+			Action:         "Deleted",
+			Key:            standaloneKey,
+			IsMapped:       true,
+			CommonLabel:    standalone.CommonLabel,
+			MappedResource: standalone,
+			Message:        fmt.Sprintf("Standalone %s %s is deleted", satellite.kind, obj.Name),
+		})
+	}
+
+	switch {
+	case isStandaloneKept:
+		satellite.setObjects(&standalone.Kube, []interface{}{object})
+		mapResults = append(mapResults, MapResult{
+			Action:         "Updated",
+			Key:            standaloneKey,
+			IsMapped:       true,
+			CommonLabel:    standalone.CommonLabel,
+			MappedResource: standalone,
+			Message:        fmt.Sprintf("Standalone %s %s is updated", satellite.kind, obj.Name),
+		})
+	case isStandalone:
+		mappedResource := newStandaloneSatellite(satellite, namespace, obj.Name, object)
+		mapResults = append(mapResults, MapResult{
+			Action:         "Added",
+			IsMapped:       true,
+			CommonLabel:    mappedResource.CommonLabel,
+			MappedResource: mappedResource,
+			Message:        fmt.Sprintf("New %s %s is added with Common Label %s", satellite.kind, obj.Name, mappedResource.CommonLabel),
+		})
+	}
+
+	return mapResults, nil
+}
+
+//unchangedClusterWideSatellite returns 'Unchanged' result for standalone group of cluster wide satellite when it already holds object.
+//Result is not mapped when it does not.
+func unchangedClusterWideSatellite(store cache.Store, satellite satelliteKind, name string, object interface{}) (MapResult, error) {
+	namespaceKeys, err := getNamespaceKeys("", store)
+	if err != nil {
+		return MapResult{}, err
+	}
+
+	for _, namespaceKey := range namespaceKeys {
+		mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+		if err != nil {
+			return MapResult{}, err
+		}
+		if !isStandaloneSatellite(mappedResource, satellite) {
+			continue
+		}
+
+		held, isHeld := satellite.objects(mappedResource.Kube)[name]
+		if !isHeld {
+			continue
+		}
+		if !equality.Semantic.DeepEqual(held, object) {
+			return MapResult{}, nil
+		}
+
+		return MapResult{
+			Action:         "Unchanged",
+			Key:            namespaceKey,
+			IsMapped:       true,
+			CommonLabel:    mappedResource.CommonLabel,
+			MappedResource: mappedResource,
+			Message:        fmt.Sprintf("%s %s is unchanged", satellite.kind, name),
+		}, nil
+	}
+
+	return MapResult{}, nil
+}
+
+//attachSatellites sets satellites of given kinds in groups of results to the ones their members reference.
+//Satellites no group holds anymore are kept in standalone groups, and standalone groups of satellites
+//which are now held by a group are deleted. Results for them are appended.
+func attachSatellites(results []MapResult, store cache.Store, kinds []satelliteKind) ([]MapResult, error) {
+	type storeGroup struct {
+		key            string
+		mappedResource MappedResource
+	}
+
+	//Groups replaced by results and namespaces they belong to.
+	replacedKeys := map[string]bool{}
+	var replacedGroups []MappedResource
+	namespaces := map[string]bool{}
+	for _, result := range results {
+		if !result.IsMapped || result.IsStoreUpdated {
+			continue
+		}
+		namespaces[result.MappedResource.Namespace] = true
+
+		keys := result.DeleteKeys
+		if result.Key != "" {
+			keys = []string{result.Key}
+		}
+		for _, key := range keys {
+			replacedKeys[key] = true
+			mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(key)), store)
+			if err != nil {
+				return results, err
+			}
+			replacedGroups = append(replacedGroups, mappedResource)
+			namespaces[mappedResource.Namespace] = true
+		}
+	}
+	if len(namespaces) == 0 {
+		return results, nil
+	}
+
+	var storeGroups []storeGroup
+	for namespace := range namespaces {
+		namespaceKeys, err := getNamespaceKeys(namespace, store)
+		if err != nil {
+			return results, err
+		}
+		for _, namespaceKey := range namespaceKeys {
+			if replacedKeys[namespaceKey] {
+				continue
+			}
+			mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+			if err != nil {
+				return results, err
+			}
+			storeGroups = append(storeGroups, storeGroup{key: namespaceKey, mappedResource: mappedResource})
+		}
+	}
+
+	isAttached := func(result MapResult) bool {
+		return result.IsMapped && !result.IsStoreUpdated && (result.Action == "Added" || result.Action == "Updated")
+	}
+
+	for _, satellite := range kinds {
+		if satellite.isClusterWide {
+			if err := attachClusterWideSatellites(results, store, satellite); err != nil {
+				return results, err
+			}
+			continue
+		}
+
+		//Satellites known before results are applied, keyed by namespace and name.
+		available := map[string]satelliteObject{}
+		addAvailable := func(mappedResource MappedResource) {
+			for name, object := range satellite.objects(mappedResource.Kube) {
+				available[historyGroupKey(mappedResource.Namespace, name)] = satelliteObject{namespace: mappedResource.Namespace, name: name, object: object}
+			}
+		}
+		for _, mappedResource := range replacedGroups {
+			addAvailable(mappedResource)
+		}
+		for _, group := range storeGroups {
+			addAvailable(group.mappedResource)
+		}
+		for _, result := range results {
+			if isAttached(result) {
+				addAvailable(result.MappedResource)
+			}
+		}
+
+		//Satellites held by a group once results are applied.
+		held := map[string]bool{}
+		standaloneKeys := map[string]storeGroup{}
+		for _, group := range storeGroups {
+			for name := range satellite.objects(group.mappedResource.Kube) {
+				held[historyGroupKey(group.mappedResource.Namespace, name)] = true
+				if isStandaloneSatellite(group.mappedResource, satellite) {
+					standaloneKeys[historyGroupKey(group.mappedResource.Namespace, name)] = group
+				}
+			}
+		}
+
+		for i, result := range results {
+			if !isAttached(result) || isStandaloneSatellite(result.MappedResource, satellite) {
+				continue
+			}
+
+			objects := map[string]interface{}{}
+			references := satellite.groupReferences(result.MappedResource)
+			for _, available := range available {
+				if available.namespace == result.MappedResource.Namespace && satellite.isReferenced(result.MappedResource, references, available.name, available.object) {
+					objects[available.name] = available.object
+				}
+			}
+			satellite.setObjects(&results[i].MappedResource.Kube, sortedSatellites(objects))
+
+			for name := range objects {
+				satelliteKey := historyGroupKey(result.MappedResource.Namespace, name)
+				held[satelliteKey] = true
+
+				//Satellite is not standalone anymore
+				if group, ok := standaloneKeys[satelliteKey]; ok {
+					delete(standaloneKeys, satelliteKey)
+					results = append(results, MapResult{
+						Action:         "Deleted",
+						Key:            group.key,
+						IsMapped:       true,
+						CommonLabel:    group.mappedResource.CommonLabel,
+						MappedResource: group.mappedResource,
+						Message:        fmt.Sprintf("Standalone %s %s is deleted as Common Label %s uses it", satellite.kind, name, result.MappedResource.CommonLabel),
+					})
+				}
+			}
+		}
+
+		for _, result := range results {
+			if isAttached(result) {
+				for name := range satellite.objects(result.MappedResource.Kube) {
+					held[historyGroupKey(result.MappedResource.Namespace, name)] = true
+				}
+			}
+		}
+
+		//Satellites of replaced groups which no group holds anymore
+		var orphanKeys []string
+		for satelliteKey := range available {
+			if !held[satelliteKey] {
+				orphanKeys = append(orphanKeys, satelliteKey)
+			}
+		}
+		sort.Strings(orphanKeys)
+
+		for _, satelliteKey := range orphanKeys {
+			orphan := available[satelliteKey]
+			mappedResource := newStandaloneSatellite(satellite, orphan.namespace, orphan.name, orphan.object)
+			results = append(results, MapResult{
+				Action:         "Added",
+				IsMapped:       true,
+				CommonLabel:    mappedResource.CommonLabel,
+				MappedResource: mappedResource,
+				Message:        fmt.Sprintf("%s %s is not used anymore. Added with Common Label %s", satellite.kind, orphan.name, mappedResource.CommonLabel),
+			})
+		}
+	}
+
+	return results, nil
+}
+
+//attachClusterWideSatellites sets cluster wide satellites of given kind in groups of results to the ones their members reference.
+//Satellites are taken from their standalone groups, which are kept whether groups reference them or not.
+func attachClusterWideSatellites(results []MapResult, store cache.Store, satellite satelliteKind) error {
+	replacedKeys := map[string]bool{}
+	for _, result := range results {
+		if result.Key != "" {
+			replacedKeys[result.Key] = true
+		}
+		for _, key := range result.DeleteKeys {
+			replacedKeys[key] = true
+		}
+	}
+
+	namespaceKeys, err := getNamespaceKeys("", store)
+	if err != nil {
+		return err
+	}
+
+	available := map[string]interface{}{}
+	for _, namespaceKey := range namespaceKeys {
+		if replacedKeys[namespaceKey] {
+			continue
+		}
+		mappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(namespaceKey)), store)
+		if err != nil {
+			return err
+		}
+		if isStandaloneSatellite(mappedResource, satellite) {
+			for name, object := range satellite.objects(mappedResource.Kube) {
+				available[name] = object
+			}
+		}
+	}
+	for _, result := range results {
+		if result.IsMapped && !result.IsStoreUpdated && result.Action != "Deleted" && isStandaloneSatellite(result.MappedResource, satellite) {
+			for name, object := range satellite.objects(result.MappedResource.Kube) {
+				available[name] = object
+			}
+		}
+	}
+
+	for i, result := range results {
+		if !result.IsMapped || result.IsStoreUpdated || (result.Action != "Added" && result.Action != "Updated") || isStandaloneSatellite(result.MappedResource, satellite) {
+			continue
+		}
+
+		objects := map[string]interface{}{}
+		references := satellite.groupReferences(result.MappedResource)
+		for name, object := range available {
+			if satellite.isReferenced(result.MappedResource, references, name, object) {
+				objects[name] = object
+			}
+		}
+		satellite.setObjects(&results[i].MappedResource.Kube, sortedSatellites(objects))
+	}
+
+	return nil
+}
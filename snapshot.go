@@ -0,0 +1,95 @@
+package kubemap
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/tools/cache"
+)
+
+//snapshotVersion is bumped whenever format of snapshot changes in incompatible way.
+const snapshotVersion = 1
+
+//snapshot is serialized form of Mapper's store.
+type snapshot struct {
+	Version         int              `json:"version"`
+	MappedResources []MappedResource `json:"mappedResources"`
+}
+
+//Snapshot writes all mapped resources in store to w.
+//Snapshot is not atomic with respect to concurrent StoreMap calls.
+func (m *Mapper) Snapshot(w io.Writer) error {
+	content := snapshot{
+		Version:         snapshotVersion,
+		MappedResources: getAllMappedResources(m.store).MappedResource,
+	}
+
+	if err := json.NewEncoder(w).Encode(content); err != nil {
+		return fmt.Errorf("Cannot write snapshot - %v", err)
+	}
+
+	return nil
+}
+
+//Restore replaces content of store with mapped resources read from a snapshot written by Snapshot.
+//Restore must not be called while resources are being mapped.
+func (m *Mapper) Restore(r io.Reader) error {
+	var content snapshot
+	if err := json.NewDecoder(r).Decode(&content); err != nil {
+		return fmt.Errorf("Cannot read snapshot - %v", err)
+	}
+
+	if content.Version != snapshotVersion {
+		return fmt.Errorf("Cannot restore snapshot. Unsupported version %d, expected %d", content.Version, snapshotVersion)
+	}
+
+	items := make([]interface{}, 0, len(content.MappedResources))
+	restored := make([]MappedResource, 0, len(content.MappedResources))
+	for _, mappedResource := range content.MappedResources {
+		mappedResource, err := m.projection.apply(mappedResource)
+		if err != nil {
+			return fmt.Errorf("Cannot restore snapshot - %v", err)
+		}
+		mappedResource = m.redaction.apply(mappedResource)
+		items = append(items, mappedResource)
+		restored = append(restored, mappedResource)
+	}
+
+	replaced := getAllMappedResources(m.store).MappedResource
+	if err := m.store.Replace(items, ""); err != nil {
+		return fmt.Errorf("Cannot restore snapshot - %v", err)
+	}
+	m.metrics.storeUpdated(restored, replaced)
+
+	m.info("Store restored from snapshot", "groups", len(items))
+	return nil
+}
+
+//markUnchangedResults sets action of results which would replace a mapped resource with an identical one to "Unchanged".
+//Store is not updated for such results. This keeps resync of already mapped resources, like after Restore, from emitting updates.
+func markUnchangedResults(results []MapResult, store cache.Store) {
+	for i, result := range results {
+		if result.Action != "Updated" || result.IsStoreUpdated {
+			continue
+		}
+
+		existingKey := result.Key
+		if existingKey == "" && len(result.DeleteKeys) == 1 {
+			existingKey = result.DeleteKeys[0]
+		} else if existingKey == "" || len(result.DeleteKeys) > 0 {
+			continue
+		}
+
+		existingMappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(existingKey)), store)
+		if err != nil {
+			continue
+		}
+
+		if equality.Semantic.DeepEqual(existingMappedResource, result.MappedResource) {
+			results[i].Action = "Unchanged"
+		}
+	}
+}
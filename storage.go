@@ -0,0 +1,57 @@
+package kubemap
+
+import (
+	core_v1 "k8s.io/api/core/v1"
+)
+
+//VolumeClaim links a PersistentVolumeClaim of a group to its bound PersistentVolume and StorageClass.
+type VolumeClaim struct {
+	Name string `json:"name"`
+	//VolumeName is name of bound PersistentVolume. It is empty while claim is pending.
+	VolumeName string `json:"volumeName,omitempty"`
+	//StorageClassName is storage class of claim, or of bound volume when claim does not set it.
+	StorageClassName string `json:"storageClassName,omitempty"`
+	//IsVolumeMapped is true when bound PersistentVolume is in group.
+	IsVolumeMapped bool `json:"isVolumeMapped"`
+}
+
+//VolumeClaims returns PersistentVolumeClaims of mapped resource with their volumes and storage classes.
+//Claims of StatefulSet volumeClaimTemplates are mapped through volumes StatefulSet controller adds to its pods.
+func VolumeClaims(mappedResource MappedResource) []VolumeClaim {
+	volumes := map[string]core_v1.PersistentVolume{}
+	for _, volume := range mappedResource.Kube.PersistentVolumes {
+		volumes[volume.Name] = volume
+	}
+
+	var claims []VolumeClaim
+	for _, claim := range mappedResource.Kube.PersistentVolumeClaims {
+		volumeClaim := VolumeClaim{
+			Name:       claim.Name,
+			VolumeName: claim.Spec.VolumeName,
+		}
+		if claim.Spec.StorageClassName != nil {
+			volumeClaim.StorageClassName = *claim.Spec.StorageClassName
+		}
+
+		if volume, ok := volumes[claim.Spec.VolumeName]; ok && claim.Spec.VolumeName != "" {
+			volumeClaim.IsVolumeMapped = true
+			if volumeClaim.StorageClassName == "" {
+				volumeClaim.StorageClassName = volume.Spec.StorageClassName
+			}
+		}
+
+		claims = append(claims, volumeClaim)
+	}
+
+	return claims
+}
+
+//persistentVolumeNamespace returns namespace of claim bound to volume. PersistentVolumes are cluster scoped,
+//and are mapped with groups of their claim's namespace. Unbound volumes have no namespace.
+func persistentVolumeNamespace(volume core_v1.PersistentVolume) string {
+	if volume.Spec.ClaimRef == nil {
+		return ""
+	}
+
+	return volume.Spec.ClaimRef.Namespace
+}
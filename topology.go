@@ -0,0 +1,161 @@
+package kubemap
+
+import (
+	"sort"
+
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//Labels of nodes naming their topology domains. Beta failure-domain labels are read when they are not set.
+const (
+	topologyZoneLabel   = "topology.kubernetes.io/zone"
+	topologyRegionLabel = "topology.kubernetes.io/region"
+)
+
+//topologyNodeLabels are labels of nodes kept in groups. Rest of node, like its status, changes with every heartbeat and is left out.
+var topologyNodeLabels = []string{
+	topologyZoneLabel,
+	topologyRegionLabel,
+	core_v1.LabelZoneFailureDomain,
+	core_v1.LabelZoneRegion,
+	core_v1.LabelHostname,
+}
+
+//TopologyDomain is number of pods of a group in a node, zone or region.
+type TopologyDomain struct {
+	Name string `json:"name"`
+	Pods int    `json:"pods"`
+}
+
+//GroupTopology is spread of pods of a group across nodes, zones and regions.
+type GroupTopology struct {
+	Namespace   string `json:"namespace"`
+	CommonLabel string `json:"commonLabel"`
+	//Pods are scheduled pods of group which are neither terminating nor finished.
+	Pods int `json:"pods"`
+	//UnscheduledPods are pods of group without a node yet.
+	UnscheduledPods int              `json:"unscheduledPods,omitempty"`
+	Nodes           []TopologyDomain `json:"nodes,omitempty"`
+	//Zones and Regions only count pods on mapped nodes having zone and region labels.
+	Zones   []TopologyDomain `json:"zones,omitempty"`
+	Regions []TopologyDomain `json:"regions,omitempty"`
+	//IsSingleNode is true when group has several pods and all of them run on one node.
+	IsSingleNode bool `json:"isSingleNode"`
+	//IsSingleZone is true when group has several pods and all of them run in one zone.
+	IsSingleZone bool `json:"isSingleZone"`
+}
+
+//Topology returns spread of pods of groups having pods across nodes attached to them, ordered by namespace and common label.
+func Topology(mappedResources MappedResources) []GroupTopology {
+	var groups []GroupTopology
+	for _, mappedResource := range mappedResources.MappedResource {
+		if len(mappedResource.Kube.Pods) == 0 {
+			continue
+		}
+		groups = append(groups, groupTopology(mappedResource))
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Namespace != groups[j].Namespace {
+			return groups[i].Namespace < groups[j].Namespace
+		}
+		return groups[i].CommonLabel < groups[j].CommonLabel
+	})
+
+	return groups
+}
+
+//Topology returns spread of pods of groups in store across nodes, zones and regions.
+func (m *Mapper) Topology() []GroupTopology {
+	return Topology(getAllMappedResources(m.store))
+}
+
+func groupTopology(mappedResource MappedResource) GroupTopology {
+	nodes := map[string]core_v1.Node{}
+	for _, node := range mappedResource.Kube.Nodes {
+		nodes[node.Name] = node
+	}
+
+	topology := GroupTopology{Namespace: mappedResource.Namespace, CommonLabel: mappedResource.CommonLabel}
+	nodePods, zonePods, regionPods := map[string]int{}, map[string]int{}, map[string]int{}
+	for _, pod := range mappedResource.Kube.Pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == core_v1.PodSucceeded || pod.Status.Phase == core_v1.PodFailed {
+			continue
+		}
+		if pod.Spec.NodeName == "" {
+			topology.UnscheduledPods++
+			continue
+		}
+
+		topology.Pods++
+		nodePods[pod.Spec.NodeName]++
+		node, ok := nodes[pod.Spec.NodeName]
+		if !ok {
+			continue
+		}
+		if zone := nodeLabel(node, topologyZoneLabel, core_v1.LabelZoneFailureDomain); zone != "" {
+			zonePods[zone]++
+		}
+		if region := nodeLabel(node, topologyRegionLabel, core_v1.LabelZoneRegion); region != "" {
+			regionPods[region]++
+		}
+	}
+
+	topology.Nodes = topologyDomains(nodePods)
+	topology.Zones = topologyDomains(zonePods)
+	topology.Regions = topologyDomains(regionPods)
+	topology.IsSingleNode = topology.Pods > 1 && len(topology.Nodes) == 1
+	topology.IsSingleZone = topology.Pods > 1 && len(topology.Zones) == 1 && topology.Zones[0].Pods == topology.Pods
+
+	return topology
+}
+
+//nodeLabel returns value of label of node, or of legacy label when label is not set.
+func nodeLabel(node core_v1.Node, label string, legacyLabel string) string {
+	if value := node.Labels[label]; value != "" {
+		return value
+	}
+
+	return node.Labels[legacyLabel]
+}
+
+//topologyDomains returns domains of given pod counts, ordered by name.
+func topologyDomains(pods map[string]int) []TopologyDomain {
+	var domains []TopologyDomain
+	for name, count := range pods {
+		domains = append(domains, TopologyDomain{Name: name, Pods: count})
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		return domains[i].Name < domains[j].Name
+	})
+
+	return domains
+}
+
+//topologyNode returns copy of node with its name and topology labels only.
+func topologyNode(node core_v1.Node) core_v1.Node {
+	topology := core_v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: node.Name}}
+	for _, label := range topologyNodeLabels {
+		if value, ok := node.Labels[label]; ok {
+			if topology.Labels == nil {
+				topology.Labels = map[string]string{}
+			}
+			topology.Labels[label] = value
+		}
+	}
+
+	return topology
+}
+
+//groupNodeNames returns names of nodes pods of group are scheduled on.
+func groupNodeNames(mappedResource MappedResource) map[string]bool {
+	names := map[string]bool{}
+	for _, pod := range mappedResource.Kube.Pods {
+		if pod.Spec.NodeName != "" {
+			names[pod.Spec.NodeName] = true
+		}
+	}
+
+	return names
+}
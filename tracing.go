@@ -0,0 +1,77 @@
+package kubemap
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/apollocse/kubemap"
+
+//Span attribute keys
+const (
+	kindAttribute        = attribute.Key("kubemap.kind")
+	namespaceAttribute   = attribute.Key("kubemap.namespace")
+	nameAttribute        = attribute.Key("kubemap.name")
+	eventTypeAttribute   = attribute.Key("kubemap.event_type")
+	groupsAttribute      = attribute.Key("kubemap.groups")
+	actionsAttribute     = attribute.Key("kubemap.actions")
+	deletedKeysAttribute = attribute.Key("kubemap.deleted_keys")
+	groupAttribute       = attribute.Key("kubemap.group")
+	actionAttribute      = attribute.Key("kubemap.action")
+)
+
+//newTracer returns tracer of given provider. Without provider, global one set with otel.SetTracerProvider is used.
+func newTracer(tracerProvider trace.TracerProvider) trace.Tracer {
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+
+	return tracerProvider.Tracer(tracerName)
+}
+
+func (m *Mapper) startSpan(ctx context.Context, spanName string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := m.tracer
+	if tracer == nil {
+		tracer = newTracer(nil)
+	}
+
+	return tracer.Start(ctx, spanName, trace.WithAttributes(attributes...))
+}
+
+func eventAttributes(obj ResourceEvent) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		kindAttribute.String(obj.ResourceType),
+		namespaceAttribute.String(obj.Namespace),
+		nameAttribute.String(obj.Name),
+		eventTypeAttribute.String(obj.EventType),
+	}
+}
+
+//setResultAttributes records groups matched by an event, actions taken on them and number of store keys deleted.
+func setResultAttributes(span trace.Span, mapResults []MapResult) {
+	var groups, actions []string
+	deletedKeys := 0
+	for _, mapResult := range mapResults {
+		groups = append(groups, mapResult.MappedResource.CommonLabel)
+		actions = append(actions, mapResult.Action)
+		deletedKeys += len(mapResult.DeleteKeys)
+	}
+
+	span.SetAttributes(
+		groupsAttribute.StringSlice(groups),
+		actionsAttribute.StringSlice(actions),
+		deletedKeysAttribute.Int(deletedKeys),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -1,10 +1,17 @@
 package kubemap
 
 import (
-	"go.uber.org/zap"
+	"log/slog"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
 	apps_v1beta2 "k8s.io/api/apps/v1beta2"
 	core_v1 "k8s.io/api/core/v1"
 	ext_v1beta1 "k8s.io/api/extensions/v1beta1"
+	networking_v1 "k8s.io/api/networking/v1"
+	policy_v1beta1 "k8s.io/api/policy/v1beta1"
+	rbac_v1 "k8s.io/api/rbac/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
@@ -12,11 +19,25 @@ import (
 //KubeResources is collection of different types of k8s resource for mapping.
 //ToDo : Add support for other k8s resources.
 type KubeResources struct {
-	Ingresses   []ext_v1beta1.Ingress
-	Services    []core_v1.Service
-	Deployments []apps_v1beta2.Deployment
-	ReplicaSets []ext_v1beta1.ReplicaSet
-	Pods        []core_v1.Pod
+	Ingresses              []ext_v1beta1.Ingress
+	Services               []core_v1.Service
+	Deployments            []apps_v1beta2.Deployment
+	ReplicaSets            []ext_v1beta1.ReplicaSet
+	Pods                   []core_v1.Pod
+	ConfigMaps             []core_v1.ConfigMap
+	Secrets                []core_v1.Secret
+	PersistentVolumeClaims []core_v1.PersistentVolumeClaim
+	PersistentVolumes      []core_v1.PersistentVolume
+	Endpoints              []core_v1.Endpoints
+	EndpointSlices         []EndpointSlice
+	NetworkPolicies        []networking_v1.NetworkPolicy
+	PodDisruptionBudgets   []policy_v1beta1.PodDisruptionBudget
+	ServiceAccounts        []core_v1.ServiceAccount
+	RoleBindings           []rbac_v1.RoleBinding
+	ClusterRoleBindings    []rbac_v1.ClusterRoleBinding
+	Roles                  []rbac_v1.Role
+	ClusterRoles           []rbac_v1.ClusterRole
+	Nodes                  []core_v1.Node
 }
 
 //MappedResource is final mapped output of interlinked K8s resources
@@ -26,16 +47,34 @@ type MappedResource struct {
 	CurrentType string `json:"currentType,omitempty"`
 	EventType   string `json:"eventType,omitempty"`
 	Kube        Kube   `json:"kube,omitempty"`
+	//ExternalDependencies are targets outside of cluster which services of group route to.
+	ExternalDependencies []ExternalDependency `json:"externalDependencies,omitempty"`
+	//Diagnostics are dangling references between members of group, like an ingress backend port which service does not have.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
 }
 
 //Kube ...
 type Kube struct {
-	Ingresses   []ext_v1beta1.Ingress     `json:"ingresses,omitempty"`
-	Services    []core_v1.Service         `json:"services,omitempty"`
-	Deployments []apps_v1beta2.Deployment `json:"deployments,omitempty"`
-	ReplicaSets []ext_v1beta1.ReplicaSet  `json:"replicaSets,omitempty"`
-	Pods        []core_v1.Pod             `json:"pods,omitempty"`
-	Events      []core_v1.Event           `json:"events,omitempty"`
+	Ingresses              []ext_v1beta1.Ingress                `json:"ingresses,omitempty"`
+	Services               []core_v1.Service                    `json:"services,omitempty"`
+	Deployments            []apps_v1beta2.Deployment            `json:"deployments,omitempty"`
+	ReplicaSets            []ext_v1beta1.ReplicaSet             `json:"replicaSets,omitempty"`
+	Pods                   []core_v1.Pod                        `json:"pods,omitempty"`
+	Events                 []core_v1.Event                      `json:"events,omitempty"`
+	ConfigMaps             []core_v1.ConfigMap                  `json:"configMaps,omitempty"`
+	Secrets                []core_v1.Secret                     `json:"secrets,omitempty"`
+	PersistentVolumeClaims []core_v1.PersistentVolumeClaim      `json:"persistentVolumeClaims,omitempty"`
+	PersistentVolumes      []core_v1.PersistentVolume           `json:"persistentVolumes,omitempty"`
+	Endpoints              []core_v1.Endpoints                  `json:"endpoints,omitempty"`
+	EndpointSlices         []EndpointSlice                      `json:"endpointSlices,omitempty"`
+	NetworkPolicies        []networking_v1.NetworkPolicy        `json:"networkPolicies,omitempty"`
+	PodDisruptionBudgets   []policy_v1beta1.PodDisruptionBudget `json:"podDisruptionBudgets,omitempty"`
+	ServiceAccounts        []core_v1.ServiceAccount             `json:"serviceAccounts,omitempty"`
+	RoleBindings           []rbac_v1.RoleBinding                `json:"roleBindings,omitempty"`
+	ClusterRoleBindings    []rbac_v1.ClusterRoleBinding         `json:"clusterRoleBindings,omitempty"`
+	Roles                  []rbac_v1.Role                       `json:"roles,omitempty"`
+	ClusterRoles           []rbac_v1.ClusterRole                `json:"clusterRoles,omitempty"`
+	Nodes                  []core_v1.Node                       `json:"nodes,omitempty"`
 }
 
 //MappedResources returns set of common labels consisting mapped k8s resources.
@@ -45,9 +84,26 @@ type MappedResources struct {
 
 // Mapper hold internal store and workqueue for mapping
 type Mapper struct {
-	queue workqueue.RateLimitingInterface
-	store cache.Store
-	log   Logger
+	queue      workqueue.RateLimitingInterface
+	store      cache.Store
+	workers    int
+	locks      namespaceLocks
+	metrics    *Metrics
+	tracer     trace.Tracer
+	history    *history
+	projection projection
+	redaction  redaction
+	filter     filter
+	log        Logger
+}
+
+//namespaceLocks serializes mapping of resources within a namespace.
+//Mapped resources never span namespaces, so different namespaces are mapped concurrently.
+//Cluster scoped satellites change groups of any namespace, so they are mapped alone.
+type namespaceLocks struct {
+	mu      sync.Mutex
+	locks   map[string]*sync.Mutex
+	cluster sync.RWMutex
 }
 
 //ResourceEvent ...
@@ -63,9 +119,12 @@ type ResourceEvent struct {
 
 //MapResult ...
 type MapResult struct {
-	Key            string
-	Action         string
-	Message        string
+	Key string
+	//Action is one of 'Added', 'Updated', 'Deleted', 'Unchanged' or 'Rejected'.
+	Action  string
+	Message string
+	//RejectReason tells why resource was filtered out when Action is 'Rejected'.
+	RejectReason   string
 	CommonLabel    string
 	DeleteKeys     []string
 	IsMapped       bool
@@ -75,11 +134,25 @@ type MapResult struct {
 
 //MetaIdentifier ...
 type MetaIdentifier struct {
-	IngressIdentifier     IngressSet `json:"ingressIdentifier,omitempty"`
-	ServicesIdentifier    MetaSet    `json:"servicesIdentifier,omitempty"`
-	DeploymentsIdentifier MetaSet    `json:"deploymentsIdentifier,omitempty"`
-	ReplicaSetsIdentifier []ChildSet `json:"replicaSetsIdentifier,omitempty"`
-	PodsIdentifier        []ChildSet `json:"podsIdentifier,omitempty"`
+	IngressIdentifier                IngressSet `json:"ingressIdentifier,omitempty"`
+	ServicesIdentifier               MetaSet    `json:"servicesIdentifier,omitempty"`
+	DeploymentsIdentifier            MetaSet    `json:"deploymentsIdentifier,omitempty"`
+	ReplicaSetsIdentifier            []ChildSet `json:"replicaSetsIdentifier,omitempty"`
+	PodsIdentifier                   []ChildSet `json:"podsIdentifier,omitempty"`
+	ConfigMapsIdentifier             []string   `json:"configMapsIdentifier,omitempty"`
+	SecretsIdentifier                []string   `json:"secretsIdentifier,omitempty"`
+	PersistentVolumeClaimsIdentifier []string   `json:"persistentVolumeClaimsIdentifier,omitempty"`
+	PersistentVolumesIdentifier      []string   `json:"persistentVolumesIdentifier,omitempty"`
+	EndpointsIdentifier              []string   `json:"endpointsIdentifier,omitempty"`
+	EndpointSlicesIdentifier         []string   `json:"endpointSlicesIdentifier,omitempty"`
+	NetworkPoliciesIdentifier        []string   `json:"networkPoliciesIdentifier,omitempty"`
+	PodDisruptionBudgetsIdentifier   []string   `json:"podDisruptionBudgetsIdentifier,omitempty"`
+	ServiceAccountsIdentifier        []string   `json:"serviceAccountsIdentifier,omitempty"`
+	RoleBindingsIdentifier           []string   `json:"roleBindingsIdentifier,omitempty"`
+	ClusterRoleBindingsIdentifier    []string   `json:"clusterRoleBindingsIdentifier,omitempty"`
+	RolesIdentifier                  []string   `json:"rolesIdentifier,omitempty"`
+	ClusterRolesIdentifier           []string   `json:"clusterRolesIdentifier,omitempty"`
+	NodesIdentifier                  []string   `json:"nodesIdentifier,omitempty"`
 }
 
 //IngressSet ...
@@ -104,17 +177,38 @@ type ChildSet struct {
 //MapOptions allows to instantiate new Mapper with custom options
 type MapOptions struct {
 	Logging LoggingOptions
+	//Workers sets number of parallel per-namespace workers used by Map.
+	//0 or 1 keeps single worker mode which processes resources in deterministic order.
+	Workers int
+	//Metrics collects prometheus metrics of mapping when set. See NewMetrics.
+	Metrics *Metrics
+	//TracerProvider creates OpenTelemetry spans for each mapping operation.
+	//Global provider set with otel.SetTracerProvider is used when it is nil.
+	TracerProvider trace.TracerProvider
+	//History records changes of mapped resources for MappedResourceAt and MembershipChanges queries.
+	History HistoryOptions
+	//Projection drops fields of k8s objects before they are written to store.
+	Projection ProjectionOptions
+	//Redaction masks sensitive values of k8s objects. Data of Secrets is masked by default.
+	Redaction RedactionOptions
+	//Filter selects resources which are mapped.
+	Filter FilterOptions
 }
 
 //LoggingOptions ...
 type LoggingOptions struct {
 	Enabled bool
-	//LogLevel sets type of logs viz 'info' or 'debug'.
+	//LogLevel sets type of logs viz 'debug', 'info', 'warn' or 'error'.
+	//It applies to default zap logger only.
 	LogLevel string
+	//Logger routes logs to given logr.Logger. Logging is enabled when it is set.
+	Logger logr.Logger
+	//Handler routes logs to given slog.Handler. Logging is enabled when it is set. It takes precedence over Logger.
+	Handler slog.Handler
 }
 
 //Logger ...
 type Logger struct {
 	enabled bool
-	logger  *zap.SugaredLogger
+	logger  *slog.Logger
 }
@@ -1,9 +1,12 @@
 package kubemap
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
 	apps_v1beta1 "k8s.io/api/apps/v1beta1"
 	apps_v1beta2 "k8s.io/api/apps/v1beta2"
@@ -11,6 +14,9 @@ import (
 	batch_v1 "k8s.io/api/batch/v1"
 	core_v1 "k8s.io/api/core/v1"
 	ext_v1beta1 "k8s.io/api/extensions/v1beta1"
+	networking_v1 "k8s.io/api/networking/v1"
+	policy_v1beta1 "k8s.io/api/policy/v1beta1"
+	rbac_v1 "k8s.io/api/rbac/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
 )
@@ -54,6 +60,26 @@ func objectMetaData(obj interface{}) meta_v1.ObjectMeta {
 		return object.ObjectMeta
 	case *autoscaling_v1.HorizontalPodAutoscaler:
 		return object.ObjectMeta
+	case *core_v1.Endpoints:
+		return object.ObjectMeta
+	case *EndpointSlice:
+		return object.ObjectMeta
+	case *networking_v1.NetworkPolicy:
+		return object.ObjectMeta
+	case *policy_v1beta1.PodDisruptionBudget:
+		return object.ObjectMeta
+	case *core_v1.ServiceAccount:
+		return object.ObjectMeta
+	case *rbac_v1.RoleBinding:
+		return object.ObjectMeta
+	case *rbac_v1.ClusterRoleBinding:
+		return object.ObjectMeta
+	case *rbac_v1.Role:
+		return object.ObjectMeta
+	case *rbac_v1.ClusterRole:
+		return object.ObjectMeta
+	case *core_v1.Node:
+		return object.ObjectMeta
 	}
 	var objectMeta meta_v1.ObjectMeta
 	return objectMeta
@@ -105,8 +131,66 @@ func copyMappedResource(resource MappedResource) MappedResource {
 		copiedMappedResource.Kube.Pods = append(copiedMappedResource.Kube.Pods, *item.DeepCopy())
 	}
 
+	for _, item := range resource.Kube.ConfigMaps {
+		copiedMappedResource.Kube.ConfigMaps = append(copiedMappedResource.Kube.ConfigMaps, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.Secrets {
+		copiedMappedResource.Kube.Secrets = append(copiedMappedResource.Kube.Secrets, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.PersistentVolumeClaims {
+		copiedMappedResource.Kube.PersistentVolumeClaims = append(copiedMappedResource.Kube.PersistentVolumeClaims, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.PersistentVolumes {
+		copiedMappedResource.Kube.PersistentVolumes = append(copiedMappedResource.Kube.PersistentVolumes, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.Endpoints {
+		copiedMappedResource.Kube.Endpoints = append(copiedMappedResource.Kube.Endpoints, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.EndpointSlices {
+		copiedMappedResource.Kube.EndpointSlices = append(copiedMappedResource.Kube.EndpointSlices, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.NetworkPolicies {
+		copiedMappedResource.Kube.NetworkPolicies = append(copiedMappedResource.Kube.NetworkPolicies, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.PodDisruptionBudgets {
+		copiedMappedResource.Kube.PodDisruptionBudgets = append(copiedMappedResource.Kube.PodDisruptionBudgets, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.ServiceAccounts {
+		copiedMappedResource.Kube.ServiceAccounts = append(copiedMappedResource.Kube.ServiceAccounts, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.RoleBindings {
+		copiedMappedResource.Kube.RoleBindings = append(copiedMappedResource.Kube.RoleBindings, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.ClusterRoleBindings {
+		copiedMappedResource.Kube.ClusterRoleBindings = append(copiedMappedResource.Kube.ClusterRoleBindings, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.Roles {
+		copiedMappedResource.Kube.Roles = append(copiedMappedResource.Kube.Roles, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.ClusterRoles {
+		copiedMappedResource.Kube.ClusterRoles = append(copiedMappedResource.Kube.ClusterRoles, *item.DeepCopy())
+	}
+
+	for _, item := range resource.Kube.Nodes {
+		copiedMappedResource.Kube.Nodes = append(copiedMappedResource.Kube.Nodes, *item.DeepCopy())
+	}
+
 	copiedMappedResource.CommonLabel = resource.CommonLabel
 	copiedMappedResource.CurrentType = resource.CurrentType
+	copiedMappedResource.ExternalDependencies = append([]ExternalDependency(nil), resource.ExternalDependencies...)
+	copiedMappedResource.Diagnostics = append([]Diagnostic(nil), resource.Diagnostics...)
 	copiedMappedResource.Namespace = resource.Namespace
 
 	return copiedMappedResource
@@ -140,7 +224,8 @@ func metaResourceKeyFunc(obj interface{}) (string, error) {
 
 	if object.Kube.Services != nil {
 		for _, service := range object.Kube.Services {
-			if service.Spec.Selector != nil {
+			//Nil and empty maps are same key, as decoding stored groups turns empty maps into nil.
+			if len(service.Spec.Selector) > 0 {
 				serviceMeta.MatchLabels = append(serviceMeta.MatchLabels, service.Spec.Selector)
 			}
 			serviceMeta.Names = append(serviceMeta.Names, service.Name)
@@ -149,7 +234,7 @@ func metaResourceKeyFunc(obj interface{}) (string, error) {
 
 	if object.Kube.Deployments != nil {
 		for _, deployment := range object.Kube.Deployments {
-			if deployment.Spec.Selector.MatchLabels != nil {
+			if deployment.Spec.Selector != nil && len(deployment.Spec.Selector.MatchLabels) > 0 {
 				deploymentMeta.MatchLabels = append(deploymentMeta.MatchLabels, deployment.Spec.Selector.MatchLabels)
 			}
 			deploymentMeta.Names = append(deploymentMeta.Names, deployment.Name)
@@ -162,6 +247,7 @@ func metaResourceKeyFunc(obj interface{}) (string, error) {
 
 		for _, replicaSet := range object.Kube.ReplicaSets {
 			rsOwnerReferences = nil
+			rsMatchLables = nil
 
 			if replicaSet.OwnerReferences != nil {
 				for _, ownerReference := range replicaSet.OwnerReferences {
@@ -169,7 +255,7 @@ func metaResourceKeyFunc(obj interface{}) (string, error) {
 				}
 			}
 
-			if replicaSet.Spec.Selector.MatchLabels != nil {
+			if replicaSet.Spec.Selector != nil && len(replicaSet.Spec.Selector.MatchLabels) > 0 {
 				rsMatchLables = replicaSet.Spec.Selector.MatchLabels
 			}
 
@@ -187,6 +273,7 @@ func metaResourceKeyFunc(obj interface{}) (string, error) {
 
 		for _, pod := range object.Kube.Pods {
 			podOwnerReferences = nil
+			podMatchLables = nil
 
 			if pod.OwnerReferences != nil {
 				for _, ownerReference := range pod.OwnerReferences {
@@ -194,7 +281,7 @@ func metaResourceKeyFunc(obj interface{}) (string, error) {
 				}
 			}
 
-			if pod.Labels != nil {
+			if len(pod.Labels) > 0 {
 				podMatchLables = pod.Labels
 			}
 
@@ -206,12 +293,80 @@ func metaResourceKeyFunc(obj interface{}) (string, error) {
 		}
 	}
 
+	var configMapIdentifier, secretIdentifier []string
+	for _, configMap := range object.Kube.ConfigMaps {
+		configMapIdentifier = append(configMapIdentifier, configMap.Name)
+	}
+	for _, secret := range object.Kube.Secrets {
+		secretIdentifier = append(secretIdentifier, secret.Name)
+	}
+
+	var claimIdentifier, volumeIdentifier []string
+	for _, claim := range object.Kube.PersistentVolumeClaims {
+		claimIdentifier = append(claimIdentifier, claim.Name)
+	}
+	for _, volume := range object.Kube.PersistentVolumes {
+		volumeIdentifier = append(volumeIdentifier, volume.Name)
+	}
+
+	var endpointsIdentifier, endpointSliceIdentifier []string
+	for _, endpoints := range object.Kube.Endpoints {
+		endpointsIdentifier = append(endpointsIdentifier, endpoints.Name)
+	}
+	for _, endpointSlice := range object.Kube.EndpointSlices {
+		endpointSliceIdentifier = append(endpointSliceIdentifier, endpointSlice.Name)
+	}
+
+	var networkPolicyIdentifier, budgetIdentifier []string
+	for _, networkPolicy := range object.Kube.NetworkPolicies {
+		networkPolicyIdentifier = append(networkPolicyIdentifier, networkPolicy.Name)
+	}
+	for _, budget := range object.Kube.PodDisruptionBudgets {
+		budgetIdentifier = append(budgetIdentifier, budget.Name)
+	}
+
+	var serviceAccountIdentifier, roleBindingIdentifier, clusterRoleBindingIdentifier, roleIdentifier, clusterRoleIdentifier []string
+	for _, serviceAccount := range object.Kube.ServiceAccounts {
+		serviceAccountIdentifier = append(serviceAccountIdentifier, serviceAccount.Name)
+	}
+	for _, roleBinding := range object.Kube.RoleBindings {
+		roleBindingIdentifier = append(roleBindingIdentifier, roleBinding.Name)
+	}
+	for _, clusterRoleBinding := range object.Kube.ClusterRoleBindings {
+		clusterRoleBindingIdentifier = append(clusterRoleBindingIdentifier, clusterRoleBinding.Name)
+	}
+	for _, role := range object.Kube.Roles {
+		roleIdentifier = append(roleIdentifier, role.Name)
+	}
+	for _, clusterRole := range object.Kube.ClusterRoles {
+		clusterRoleIdentifier = append(clusterRoleIdentifier, clusterRole.Name)
+	}
+
+	var nodeIdentifier []string
+	for _, node := range object.Kube.Nodes {
+		nodeIdentifier = append(nodeIdentifier, node.Name)
+	}
+
 	key := MetaIdentifier{
-		IngressIdentifier:     ingressIdentifier,
-		ServicesIdentifier:    serviceMeta,
-		DeploymentsIdentifier: deploymentMeta,
-		ReplicaSetsIdentifier: rsIdentifier,
-		PodsIdentifier:        podIdentifier,
+		IngressIdentifier:                ingressIdentifier,
+		ServicesIdentifier:               serviceMeta,
+		DeploymentsIdentifier:            deploymentMeta,
+		ReplicaSetsIdentifier:            rsIdentifier,
+		PodsIdentifier:                   podIdentifier,
+		ConfigMapsIdentifier:             configMapIdentifier,
+		SecretsIdentifier:                secretIdentifier,
+		PersistentVolumeClaimsIdentifier: claimIdentifier,
+		PersistentVolumesIdentifier:      volumeIdentifier,
+		EndpointsIdentifier:              endpointsIdentifier,
+		EndpointSlicesIdentifier:         endpointSliceIdentifier,
+		NetworkPoliciesIdentifier:        networkPolicyIdentifier,
+		PodDisruptionBudgetsIdentifier:   budgetIdentifier,
+		ServiceAccountsIdentifier:        serviceAccountIdentifier,
+		RoleBindingsIdentifier:           roleBindingIdentifier,
+		ClusterRoleBindingsIdentifier:    clusterRoleBindingIdentifier,
+		RolesIdentifier:                  roleIdentifier,
+		ClusterRolesIdentifier:           clusterRoleIdentifier,
+		NodesIdentifier:                  nodeIdentifier,
 	}
 
 	jsonKey, _ := json.Marshal(key)
@@ -232,94 +387,300 @@ func getObjectFromStore(key string, store cache.Store) (MappedResource, error) {
 	}
 
 	if exists {
-		return item.(MappedResource), nil
+		//Mappers replace objects of returned mapped resource in place. Do not let them modify the one in store.
+		mappedResource := item.(MappedResource)
+		mappedResource.Kube = Kube{
+			Ingresses:              append([]ext_v1beta1.Ingress(nil), mappedResource.Kube.Ingresses...),
+			Services:               append([]core_v1.Service(nil), mappedResource.Kube.Services...),
+			Deployments:            append([]apps_v1beta2.Deployment(nil), mappedResource.Kube.Deployments...),
+			ReplicaSets:            append([]ext_v1beta1.ReplicaSet(nil), mappedResource.Kube.ReplicaSets...),
+			Pods:                   append([]core_v1.Pod(nil), mappedResource.Kube.Pods...),
+			Events:                 append([]core_v1.Event(nil), mappedResource.Kube.Events...),
+			ConfigMaps:             append([]core_v1.ConfigMap(nil), mappedResource.Kube.ConfigMaps...),
+			Secrets:                append([]core_v1.Secret(nil), mappedResource.Kube.Secrets...),
+			PersistentVolumeClaims: append([]core_v1.PersistentVolumeClaim(nil), mappedResource.Kube.PersistentVolumeClaims...),
+			PersistentVolumes:      append([]core_v1.PersistentVolume(nil), mappedResource.Kube.PersistentVolumes...),
+			Endpoints:              append([]core_v1.Endpoints(nil), mappedResource.Kube.Endpoints...),
+			EndpointSlices:         append([]EndpointSlice(nil), mappedResource.Kube.EndpointSlices...),
+			NetworkPolicies:        append([]networking_v1.NetworkPolicy(nil), mappedResource.Kube.NetworkPolicies...),
+			PodDisruptionBudgets:   append([]policy_v1beta1.PodDisruptionBudget(nil), mappedResource.Kube.PodDisruptionBudgets...),
+			ServiceAccounts:        append([]core_v1.ServiceAccount(nil), mappedResource.Kube.ServiceAccounts...),
+			RoleBindings:           append([]rbac_v1.RoleBinding(nil), mappedResource.Kube.RoleBindings...),
+			ClusterRoleBindings:    append([]rbac_v1.ClusterRoleBinding(nil), mappedResource.Kube.ClusterRoleBindings...),
+			Roles:                  append([]rbac_v1.Role(nil), mappedResource.Kube.Roles...),
+			ClusterRoles:           append([]rbac_v1.ClusterRole(nil), mappedResource.Kube.ClusterRoles...),
+			Nodes:                  append([]core_v1.Node(nil), mappedResource.Kube.Nodes...),
+		}
+		return mappedResource, nil
+	}
+	return MappedResource{}, fmt.Errorf("%w: Object with key %s does not exist in store", ErrKeyNotFound, key)
+}
+
+//getNamespaceKeys returns decoded keys of mapped resources in given namespace.
+//Stores with a namespace index, like BoltStore, are queried directly. Other stores have all keys filtered.
+//Keys which cannot be decoded are skipped and reported in returned error.
+func getNamespaceKeys(namespace string, store cache.Store) ([]string, error) {
+	var keys []string
+	if lister, ok := store.(namespaceKeyLister); ok {
+		keys = lister.ListNamespaceKeys(namespace)
+	} else {
+		keys = store.ListKeys()
+	}
+
+	decodedKeys, err := decodeStoreKeys(keys)
+
+	var namespaceKeys []string
+	for _, key := range decodedKeys {
+		if strings.Split(key, "$")[0] == namespace {
+			namespaceKeys = append(namespaceKeys, key)
+		}
 	}
-	return MappedResource{}, fmt.Errorf("Object with key %s does not exist in store", key)
+
+	return namespaceKeys, err
 }
 
-func (m *Mapper) updateStore(results []MapResult, store cache.Store) error {
+//getAllKeys returns decoded keys of mapped resources in all namespaces.
+//Keys which cannot be decoded are skipped and reported in returned error.
+func getAllKeys(store cache.Store) ([]string, error) {
+	return decodeStoreKeys(store.ListKeys())
+}
+
+//decodeStoreKeys decodes base64 store keys. Keys which are not base64 or lack namespace separator are skipped.
+func decodeStoreKeys(b64Keys []string) ([]string, error) {
+	var keys []string
+	var errs []error
+	for _, b64Key := range b64Keys {
+		key, err := base64.StdEncoding.DecodeString(b64Key)
+		if err == nil && !strings.Contains(string(key), "$") {
+			err = errors.New("missing namespace separator")
+		}
+		if err != nil {
+			errs = append(errs, storeKeyError(b64Key, err))
+			continue
+		}
+		keys = append(keys, string(key))
+	}
+
+	return keys, errors.Join(errs...)
+}
+
+//updateStore writes results to store. Pods of results are annotated with readiness, external dependencies and diagnostics are set,
+//mapped resources are projected and redacted, and results which would not change store are marked 'Unchanged' in place.
+func (m *Mapper) updateStore(ctx context.Context, results []MapResult, store cache.Store) error {
+	externalDependencyResults(results)
+	if err := diagnoseResults(results, store); err != nil {
+		return err
+	}
+	if err := m.projection.applyResults(results); err != nil {
+		return err
+	}
+	//Ready annotations are set by kubemap itself, so projection does not drop them.
+	annotateResults(results)
+	m.redaction.applyResults(results)
+	markUnchangedResults(results, store)
+
 	for _, result := range results {
 		if result.IsMapped && !result.IsStoreUpdated {
-			switch result.Action {
-			case "Added", "Updated":
-				if result.Key != "" {
-					//Update object in store
-					// existingMappedResource, err := getObjectFromStore(result.Key, store)
-					existingMappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(result.Key)), store)
-
-					if err != nil {
-						m.warn(fmt.Sprintf("Error while getting object from store - %v Key - %s", err, result.Key))
-						return err
-					}
+			if err := m.updateStoreResult(ctx, result, store); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
 
-					//Delete exiting resource from store
-					err = store.Delete(existingMappedResource)
-					if err != nil {
-						m.warn(fmt.Sprintf("Error while deleting object from store - %v Key - %s", err, result.Key))
-						return err
-					}
+func (m *Mapper) updateStoreResult(ctx context.Context, result MapResult, store cache.Store) (err error) {
+	_, span := m.startSpan(ctx, "kubemap.updateStore",
+		groupAttribute.String(result.MappedResource.CommonLabel),
+		actionAttribute.String(result.Action),
+		deletedKeysAttribute.Int(len(result.DeleteKeys)),
+	)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	//Mapped resources deleted from store are recorded in history along with result.
+	var removed []MappedResource
+
+	switch result.Action {
+	case "Added", "Updated":
+		if result.Key != "" {
+			//Update object in store
+			// existingMappedResource, err := getObjectFromStore(result.Key, store)
+			existingMappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(result.Key)), store)
+
+			if err != nil {
+				m.warn("Error while getting object from store", "group", result.MappedResource.CommonLabel, "action", result.Action, "key", result.Key, "error", err)
+				return fmt.Errorf("%w: %v", ErrStoreInconsistent, err)
+			}
 
-					//Add new mapped resource to store
-					err = store.Add(result.MappedResource)
-					if err != nil {
-						m.warn(fmt.Sprintf("Error while adding object from store - %v Key - %s", err, result.Key))
-						return err
-					}
-				} else if len(result.DeleteKeys) > 0 {
-					//Needs to delete multiple resources
-					//Update object in store
-					for _, deleteKey := range result.DeleteKeys {
-						// existingMappedResource, err := getObjectFromStore(deleteKey, store)
-						existingMappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(deleteKey)), store)
-						if err != nil {
-							m.warn(fmt.Sprintf("Error while getting object from store - %v Key - %s", err, result.Key))
-							return err
-						}
+			//Delete exiting resource from store
+			err = store.Delete(existingMappedResource)
+			if err != nil {
+				m.warn("Error while deleting object from store", "group", result.MappedResource.CommonLabel, "action", result.Action, "key", result.Key, "error", err)
+				return err
+			}
+			removed = append(removed, existingMappedResource)
 
-						//Delete exiting resource from store
-						err = store.Delete(existingMappedResource)
-						if err != nil {
-							m.warn(fmt.Sprintf("Error while deleting object from store - %v Key - %s", err, result.Key))
-							return err
-						}
-					}
+			//Add new mapped resource to store
+			err = store.Add(result.MappedResource)
+			if err != nil {
+				m.warn("Error while adding object from store", "group", result.MappedResource.CommonLabel, "action", result.Action, "key", result.Key, "error", err)
+				return err
+			}
+		} else if len(result.DeleteKeys) > 0 {
+			//Needs to delete multiple resources
+			//Update object in store
+			for _, deleteKey := range result.DeleteKeys {
+				// existingMappedResource, err := getObjectFromStore(deleteKey, store)
+				existingMappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(deleteKey)), store)
+				if err != nil {
+					m.warn("Error while getting object from store", "group", result.MappedResource.CommonLabel, "action", result.Action, "key", result.Key, "error", err)
+					return fmt.Errorf("%w: %v", ErrStoreInconsistent, err)
+				}
 
-					//Add new mapped resource to store
-					err := store.Add(result.MappedResource)
-					if err != nil {
-						m.warn(fmt.Sprintf("Error while adding object to store - %v Key - %s", err, result.Key))
-						return err
-					}
-				} else {
-					//If key is not present then its new mapped resource.
-					//Add new individual mapped resource to store
-					err := store.Add(result.MappedResource)
-					if err != nil {
-						m.warn(fmt.Sprintf("Error while adding newly mapped object to store - %v Key - %s", err, result.Key))
-						return err
-					}
+				//Delete exiting resource from store
+				err = store.Delete(existingMappedResource)
+				if err != nil {
+					m.warn("Error while deleting object from store", "group", result.MappedResource.CommonLabel, "action", result.Action, "key", result.Key, "error", err)
+					return err
 				}
-			case "Deleted":
-				if result.Key != "" {
-					//Get object from store
-					// existingMappedResource, err := getObjectFromStore(result.Key, store)
-					existingMappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(result.Key)), store)
-					if err != nil {
-						m.warn(fmt.Sprintf("Error while getting object from store - %v Key - %s", err, result.Key))
-						return err
-					}
+				removed = append(removed, existingMappedResource)
+			}
 
-					//Delete existing resource from store
-					err = store.Delete(existingMappedResource)
-					if err != nil {
-						m.warn(fmt.Sprintf("Error while deleting object from store - %v Key - %s", err, result.Key))
-						return err
-					}
+			//Add new mapped resource to store
+			err := store.Add(result.MappedResource)
+			if err != nil {
+				m.warn("Error while adding object to store", "group", result.MappedResource.CommonLabel, "action", result.Action, "key", result.Key, "error", err)
+				return err
+			}
+		} else {
+			//If key is not present then its new mapped resource.
+			//Add new individual mapped resource to store
+			err := store.Add(result.MappedResource)
+			if err != nil {
+				m.warn("Error while adding newly mapped object to store", "group", result.MappedResource.CommonLabel, "action", result.Action, "key", result.Key, "error", err)
+				return err
+			}
+		}
+	case "Deleted":
+		if result.Key != "" {
+			//Get object from store
+			// existingMappedResource, err := getObjectFromStore(result.Key, store)
+			existingMappedResource, err := getObjectFromStore(base64.StdEncoding.EncodeToString([]byte(result.Key)), store)
+			if err != nil {
+				m.warn("Error while getting object from store", "group", result.MappedResource.CommonLabel, "action", result.Action, "key", result.Key, "error", err)
+				return fmt.Errorf("%w: %v", ErrStoreInconsistent, err)
+			}
 
-					m.info(fmt.Sprintf("Object %s with key %s deleted from store", existingMappedResource.CommonLabel, result.Key))
-				}
+			//Delete existing resource from store
+			err = store.Delete(existingMappedResource)
+			if err != nil {
+				m.warn("Error while deleting object from store", "group", result.MappedResource.CommonLabel, "action", result.Action, "key", result.Key, "error", err)
+				return err
 			}
+			removed = append(removed, existingMappedResource)
+
+			m.info("Object deleted from store", "group", existingMappedResource.CommonLabel, "action", result.Action, "key", result.Key)
 		}
 	}
+
+	if result.Action == "Added" || result.Action == "Updated" {
+		m.metrics.storeUpdated([]MappedResource{result.MappedResource}, removed)
+	} else {
+		m.metrics.storeUpdated(nil, removed)
+	}
+	m.history.record(result, removed)
 	return nil
 }
+
+//ingressBackendServiceNames returns unique names of services referred in ingress rules.
+func ingressBackendServiceNames(ingress ext_v1beta1.Ingress) []string {
+	var ingressBackendServices []string
+	for _, ingressRule := range ingress.Spec.Rules {
+		if ingressRule.IngressRuleValue.HTTP != nil {
+			for _, ingressRuleValueHTTPPath := range ingressRule.IngressRuleValue.HTTP.Paths {
+				if ingressRuleValueHTTPPath.Backend.ServiceName != "" {
+					ingressBackendServices = append(ingressBackendServices, ingressRuleValueHTTPPath.Backend.ServiceName)
+				}
+			}
+		}
+	}
+
+	return removeDuplicateStrings(ingressBackendServices)
+}
+
+//isLabelSubset returns true if selector is non empty and all of its labels are present in labels.
+func isLabelSubset(selector map[string]string, labels map[string]string) bool {
+	if len(selector) == 0 {
+		return false
+	}
+
+	for key, value := range selector {
+		if val, ok := labels[key]; !ok || val != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+//isOwnedBy returns true if any of owner references has given name.
+func isOwnedBy(ownerReferences []meta_v1.OwnerReference, name string) bool {
+	for _, ownerReference := range ownerReferences {
+		if ownerReference.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+//hasMemberNamed returns true if any of the mapped k8s resources has given name.
+func hasMemberNamed(mappedResource MappedResource, name string) bool {
+	for _, item := range mappedResource.Kube.Ingresses {
+		if item.Name == name {
+			return true
+		}
+	}
+	for _, item := range mappedResource.Kube.Services {
+		if item.Name == name {
+			return true
+		}
+	}
+	for _, item := range mappedResource.Kube.Deployments {
+		if item.Name == name {
+			return true
+		}
+	}
+	for _, item := range mappedResource.Kube.ReplicaSets {
+		if item.Name == name {
+			return true
+		}
+	}
+	for _, item := range mappedResource.Kube.Pods {
+		if item.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+//defaultCommonLabel picks common label for a mapped resource in order of service, deployment, replica set, ingress and pod names.
+func defaultCommonLabel(mappedResource MappedResource) string {
+	switch {
+	case len(mappedResource.Kube.Services) > 0:
+		return mappedResource.Kube.Services[0].Name
+	case len(mappedResource.Kube.Deployments) > 0:
+		return mappedResource.Kube.Deployments[0].Name
+	case len(mappedResource.Kube.ReplicaSets) > 0:
+		return mappedResource.Kube.ReplicaSets[0].Name
+	case len(mappedResource.Kube.Ingresses) > 0:
+		return mappedResource.Kube.Ingresses[0].Name
+	case len(mappedResource.Kube.Pods) > 0:
+		return mappedResource.Kube.Pods[0].Name
+	}
+
+	return ""
+}